@@ -0,0 +1,276 @@
+// Package bench drives read, write and watch workloads against etcd through
+// an etcdclient.EtcdClient, so a cluster can be sized with the same client
+// code paths applications use, and reports latency percentiles and
+// throughput for every kind of operation
+package bench
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// timestampSize is the length of the timestamp at the start of every
+// value written, watchers use it to measure how late events arrive
+const timestampSize = 19
+
+// Workload describes the load to generate. Zero fields are replaced
+// by the defaults in DefaultWorkload
+type Workload struct {
+	// Prefix is the directory the benchmark writes its keys to.
+	// It is deleted when the benchmark is done
+	Prefix string
+
+	// Keys is how many distinct keys are read and written
+	Keys int
+
+	// ValueSize is the size of every value written, in bytes
+	ValueSize int
+
+	// Clients is how many goroutines make requests concurrently
+	Clients int
+
+	// Duration is how long requests are made for
+	Duration time.Duration
+
+	// ReadRatio is the share of requests that are reads, from 0 to 1.
+	// Reads are only made if it is set, the rest are writes
+	ReadRatio float64
+
+	// Watchers is how many watches on Prefix measure how long
+	// it takes a write to reach a watcher
+	Watchers int
+}
+
+// DefaultWorkload holds the values used for the zero fields of a Workload
+var DefaultWorkload = Workload{
+	Prefix:    "/_bench",
+	Keys:      1000,
+	ValueSize: 256,
+	Clients:   16,
+	Duration:  10 * time.Second,
+}
+
+// Result is what a benchmark measured
+type Result struct {
+	Reads  Latencies `json:"reads"`
+	Writes Latencies `json:"writes"`
+
+	// Watch is the time between a write being sent and a watcher seeing it
+	Watch Latencies `json:"watch"`
+
+	// Errors is how many requests failed, they are not in the latencies
+	Errors int `json:"errors"`
+
+	Elapsed time.Duration `json:"elapsed"`
+}
+
+// Latencies summarizes the latencies of one kind of operation.
+// In JSON the durations are in nanoseconds
+type Latencies struct {
+	Count int `json:"count"`
+
+	// Throughput is how many operations completed per second
+	Throughput float64 `json:"throughput"`
+
+	Min  time.Duration `json:"min"`
+	Mean time.Duration `json:"mean"`
+	P50  time.Duration `json:"p50"`
+	P90  time.Duration `json:"p90"`
+	P99  time.Duration `json:"p99"`
+	Max  time.Duration `json:"max"`
+}
+
+// String formats the latencies on one line
+func (latencies Latencies) String() string {
+	if latencies.Count == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("%v ops, %.1f ops/s, min %v, mean %v, p50 %v, p90 %v, p99 %v, max %v",
+		latencies.Count, latencies.Throughput, latencies.Min, latencies.Mean,
+		latencies.P50, latencies.P90, latencies.P99, latencies.Max)
+}
+
+// Run fills the workload's keys, then makes requests for its duration
+// and measures them. The prefix is deleted when the benchmark is done
+func Run(etcd etcdclient.EtcdClient, workload Workload) (Result, error) {
+	workload = workload.withDefaults()
+	keys := make([]string, workload.Keys)
+	for i := range keys {
+		keys[i] = path.Join(workload.Prefix, strconv.Itoa(i))
+	}
+	defer etcd.DelDir(workload.Prefix)
+
+	if workload.ReadRatio > 0 {
+		kvs := make(map[string]string)
+		for _, key := range keys {
+			kvs[key] = newValue(workload.ValueSize)
+		}
+		if err := etcd.SetMulti(kvs); err != nil {
+			return Result{}, err
+		}
+	}
+
+	var reads, writes, watch recorder
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var watchers sync.WaitGroup
+	for i := 0; i < workload.Watchers; i++ {
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			etcd.WithContext(ctx).WatchEvents(workload.Prefix, 0, func(event etcdclient.Event) {
+				if sent, ok := parseValue(event.Value); ok {
+					watch.add(time.Since(sent))
+				}
+			})
+		}()
+	}
+
+	var errors int
+	var errorsMutex sync.Mutex
+	var clients sync.WaitGroup
+	start := time.Now()
+	deadline := start.Add(workload.Duration)
+
+	for i := 0; i < workload.Clients; i++ {
+		clients.Add(1)
+		go func(seed int64) {
+			defer clients.Done()
+			random := rand.New(rand.NewSource(seed))
+
+			for time.Now().Before(deadline) {
+				key := keys[random.Intn(len(keys))]
+				begin := time.Now()
+
+				var err error
+				if random.Float64() < workload.ReadRatio {
+					_, err = etcd.Get(key)
+					reads.addIfOK(time.Since(begin), err)
+				} else {
+					err = etcd.Set(key, newValue(workload.ValueSize))
+					writes.addIfOK(time.Since(begin), err)
+				}
+
+				if err != nil {
+					errorsMutex.Lock()
+					errors++
+					errorsMutex.Unlock()
+				}
+			}
+		}(start.UnixNano() + int64(i))
+	}
+	clients.Wait()
+	elapsed := time.Since(start)
+
+	// give the watchers a moment to see the last writes
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	watchers.Wait()
+
+	return Result{
+		Reads:   reads.summarize(elapsed),
+		Writes:  writes.summarize(elapsed),
+		Watch:   watch.summarize(elapsed),
+		Errors:  errors,
+		Elapsed: elapsed,
+	}, nil
+}
+
+func (workload Workload) withDefaults() Workload {
+	if workload.Prefix == "" {
+		workload.Prefix = DefaultWorkload.Prefix
+	}
+	if workload.Keys <= 0 {
+		workload.Keys = DefaultWorkload.Keys
+	}
+	if workload.ValueSize < timestampSize {
+		workload.ValueSize = DefaultWorkload.ValueSize
+	}
+	if workload.Clients <= 0 {
+		workload.Clients = DefaultWorkload.Clients
+	}
+	if workload.Duration <= 0 {
+		workload.Duration = DefaultWorkload.Duration
+	}
+	return workload
+}
+
+// newValue returns a value of size bytes that starts with the current time
+func newValue(size int) string {
+	timestamp := fmt.Sprintf("%0*d", timestampSize, time.Now().UnixNano())
+	return timestamp + strings.Repeat("x", size-timestampSize)
+}
+
+// parseValue returns when a value made by newValue was made
+func parseValue(value string) (time.Time, bool) {
+	if len(value) < timestampSize {
+		return time.Time{}, false
+	}
+
+	nanos, err := strconv.ParseInt(value[:timestampSize], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, nanos), true
+}
+
+// recorder collects the latencies of one kind of operation
+type recorder struct {
+	mutex     sync.Mutex
+	latencies []time.Duration
+}
+
+func (recorder *recorder) add(latency time.Duration) {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+	recorder.latencies = append(recorder.latencies, latency)
+}
+
+func (recorder *recorder) addIfOK(latency time.Duration, err error) {
+	if err == nil {
+		recorder.add(latency)
+	}
+}
+
+func (recorder *recorder) summarize(elapsed time.Duration) Latencies {
+	recorder.mutex.Lock()
+	defer recorder.mutex.Unlock()
+
+	latencies := recorder.latencies
+	if len(latencies) == 0 {
+		return Latencies{}
+	}
+	sort.Slice(latencies, func(i, j int) bool {
+		return latencies[i] < latencies[j]
+	})
+
+	var total time.Duration
+	for _, latency := range latencies {
+		total += latency
+	}
+
+	percentile := func(p float64) time.Duration {
+		return latencies[int(p*float64(len(latencies)-1))]
+	}
+
+	return Latencies{
+		Count:      len(latencies),
+		Throughput: float64(len(latencies)) / elapsed.Seconds(),
+		Min:        latencies[0],
+		Mean:       total / time.Duration(len(latencies)),
+		P50:        percentile(0.50),
+		P90:        percentile(0.90),
+		P99:        percentile(0.99),
+		Max:        latencies[len(latencies)-1],
+	}
+}
@@ -0,0 +1,72 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestRun(t *testing.T) {
+	etcd, stop := etcdtest.NewMemory(t)
+	defer stop()
+
+	result, err := Run(etcd, Workload{Prefix: "/bench", Keys: 10, Clients: 2, Duration: 200 * time.Millisecond, ReadRatio: 0.5, Watchers: 1})
+	if err != nil {
+		t.Fatalf("Run returned %v", err)
+	}
+	if result.Reads.Count == 0 || result.Writes.Count == 0 || result.Watch.Count == 0 {
+		t.Errorf("Run measured %v reads, %v writes and %v watch events, expected some of each", result.Reads.Count, result.Writes.Count, result.Watch.Count)
+	}
+	if result.Errors != 0 || result.Elapsed < 200*time.Millisecond {
+		t.Errorf("Run returned %v errors after %v", result.Errors, result.Elapsed)
+	}
+	if keys, err := etcd.Ls("/bench"); err != nil || len(keys) != 0 {
+		t.Errorf("Run left %v, %v in its prefix, expected it deleted", keys, err)
+	}
+}
+
+func TestValueCarriesItsTimestamp(t *testing.T) {
+	before := time.Now()
+	value := newValue(100)
+
+	sent, ok := parseValue(value)
+	if len(value) != 100 || !ok || sent.Before(before) || sent.After(time.Now()) {
+		t.Errorf("newValue returned %v bytes with the time %v, %v", len(value), sent, ok)
+	}
+	for _, value := range []string{"short", "not a timestamp at all"} {
+		if _, ok := parseValue(value); ok {
+			t.Errorf("parseValue(%q) found a timestamp", value)
+		}
+	}
+}
+
+func TestRecorderSummarize(t *testing.T) {
+	var latencies recorder
+	if summary := latencies.summarize(time.Second); summary != (Latencies{}) {
+		t.Errorf("summarize without latencies returned %+v", summary)
+	}
+
+	for i := 100; i >= 1; i-- {
+		latencies.add(time.Duration(i) * time.Millisecond)
+	}
+	expected := Latencies{
+		Count:      100,
+		Throughput: 50,
+		Min:        time.Millisecond,
+		Mean:       50500 * time.Microsecond,
+		P50:        50 * time.Millisecond,
+		P90:        90 * time.Millisecond,
+		P99:        99 * time.Millisecond,
+		Max:        100 * time.Millisecond,
+	}
+	if summary := latencies.summarize(2 * time.Second); summary != expected {
+		t.Errorf("summarize returned %+v, expected %+v", summary, expected)
+	}
+}
+
+func TestWithDefaults(t *testing.T) {
+	if workload := (Workload{ValueSize: 5}).withDefaults(); workload != DefaultWorkload {
+		t.Errorf("withDefaults returned %+v, expected %+v", workload, DefaultWorkload)
+	}
+}
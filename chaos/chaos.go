@@ -0,0 +1,210 @@
+// Package chaos wraps an etcdclient.EtcdClient to inject latency, errors
+// and dropped watch events, so services can be tested against an etcd that
+// misbehaves without breaking a real cluster. Faults follow a random
+// schedule from Config.Seed, the same seed and calls give the same faults
+package chaos
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// ErrInjected is the error failed requests return unless Config.Err is set
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Config describes the faults to inject
+type Config struct {
+	// Seed seeds the schedule of faults
+	Seed int64
+
+	// Latency is the most a request is delayed,
+	// every request waits a random time up to it
+	Latency time.Duration
+
+	// ErrorRate is the share of requests that fail, from 0 to 1
+	ErrorRate float64
+
+	// Err is the error failed requests return, ErrInjected if it is nil
+	Err error
+
+	// DropRate is the share of watch events that are never
+	// passed to the callback, from 0 to 1
+	DropRate float64
+}
+
+// Client injects faults into the reads, writes and watches of the client
+// it wraps: Get, Set, Del, DelDir, MkDir, Ls, LsRecursive,
+// SetWithOptions, WatchRecursive, WatchRecursiveFrom and WatchEvents.
+// Every other method goes straight to the wrapped client
+type Client struct {
+	etcdclient.EtcdClient
+	faults *faults
+}
+
+type faults struct {
+	mutex  sync.Mutex
+	config Config
+	random *rand.Rand
+}
+
+// Wrap returns a client that injects the faults in config into inner
+func Wrap(inner etcdclient.EtcdClient, config Config) *Client {
+	faults := &faults{}
+	faults.set(config)
+	return &Client{EtcdClient: inner, faults: faults}
+}
+
+// SetConfig replaces the faults to inject and restarts the schedule from
+// the new seed. Use the zero Config to stop injecting faults
+func (chaos *Client) SetConfig(config Config) {
+	chaos.faults.set(config)
+}
+
+// Get gets a value in Etcd, unless the request is made to fail
+func (chaos *Client) Get(key string) (string, error) {
+	if err := chaos.faults.request(); err != nil {
+		return "", err
+	}
+	return chaos.EtcdClient.Get(key)
+}
+
+// Set sets a value in Etcd, unless the request is made to fail
+func (chaos *Client) Set(key, value string) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.Set(key, value)
+}
+
+// Del deletes a key from Etcd, unless the request is made to fail
+func (chaos *Client) Del(key string) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.Del(key)
+}
+
+// DelDir deletes a dir from Etcd, unless the request is made to fail
+func (chaos *Client) DelDir(key string) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.DelDir(key)
+}
+
+// MkDir creates an empty etcd directory, unless the request is made to fail
+func (chaos *Client) MkDir(directory string) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.MkDir(directory)
+}
+
+// Ls returns the keys in the directory, unless the request is made to fail
+func (chaos *Client) Ls(directory string) ([]string, error) {
+	if err := chaos.faults.request(); err != nil {
+		return nil, err
+	}
+	return chaos.EtcdClient.Ls(directory)
+}
+
+// LsRecursive returns the keys in the directory, recursively,
+// unless the request is made to fail
+func (chaos *Client) LsRecursive(directory string) ([]string, error) {
+	if err := chaos.faults.request(); err != nil {
+		return nil, err
+	}
+	return chaos.EtcdClient.LsRecursive(directory)
+}
+
+// SetWithOptions sets a value in Etcd if the conditions in opts are
+// met, unless the request is made to fail
+func (chaos *Client) SetWithOptions(key, value string, opts etcdclient.SetOptions) (*etcdclient.Result, error) {
+	if err := chaos.faults.request(); err != nil {
+		return nil, err
+	}
+	return chaos.EtcdClient.SetWithOptions(key, value, opts)
+}
+
+// WatchRecursive watches a directory, dropping some of its changes.
+// This method only returns if there is an error
+func (chaos *Client) WatchRecursive(directory string, onChange etcdclient.OnChangeCallback) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.WatchRecursive(directory, func(key, value string) {
+		if !chaos.faults.drop() {
+			onChange(key, value)
+		}
+	})
+}
+
+// WatchRecursiveFrom watches a directory after the index, dropping some
+// of its changes. This method only returns if there is an error
+func (chaos *Client) WatchRecursiveFrom(directory string, afterIndex uint64, onChange etcdclient.OnIndexedChangeCallback) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.WatchRecursiveFrom(directory, afterIndex, func(key, value string, index uint64) {
+		if !chaos.faults.drop() {
+			onChange(key, value, index)
+		}
+	})
+}
+
+// WatchEvents watches a directory after the index, dropping some of its
+// events. This method only returns if there is an error
+func (chaos *Client) WatchEvents(directory string, afterIndex uint64, onEvent etcdclient.OnEventCallback) error {
+	if err := chaos.faults.request(); err != nil {
+		return err
+	}
+	return chaos.EtcdClient.WatchEvents(directory, afterIndex, func(event etcdclient.Event) {
+		if !chaos.faults.drop() {
+			onEvent(event)
+		}
+	})
+}
+
+// WithContext returns a copy of the client that uses ctx,
+// injecting the same faults from the same schedule
+func (chaos *Client) WithContext(ctx context.Context) etcdclient.EtcdClient {
+	return &Client{EtcdClient: chaos.EtcdClient.WithContext(ctx), faults: chaos.faults}
+}
+
+func (faults *faults) set(config Config) {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	faults.config = config
+	faults.random = rand.New(rand.NewSource(config.Seed))
+}
+
+// request waits for the injected latency and returns
+// an error if the request is made to fail
+func (faults *faults) request() error {
+	faults.mutex.Lock()
+	config := faults.config
+	latency := time.Duration(faults.random.Int63n(int64(config.Latency) + 1))
+	fail := faults.random.Float64() < config.ErrorRate
+	faults.mutex.Unlock()
+
+	time.Sleep(latency)
+	if !fail {
+		return nil
+	}
+	if config.Err != nil {
+		return config.Err
+	}
+	return ErrInjected
+}
+
+// drop returns true if a watch event is dropped
+func (faults *faults) drop() bool {
+	faults.mutex.Lock()
+	defer faults.mutex.Unlock()
+	return faults.random.Float64() < faults.config.DropRate
+}
@@ -0,0 +1,122 @@
+package chaos_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/chaos"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"golang.org/x/net/context"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T) etcdclient.EtcdClient {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	return etcd
+}
+
+// failures returns which of n Gets through the client fail
+func failures(etcd etcdclient.EtcdClient, n int) []bool {
+	failed := make([]bool, n)
+	for i := range failed {
+		_, err := etcd.Get("/chaos/key")
+		failed[i] = err != nil
+	}
+	return failed
+}
+
+func TestTheSeedGivesTheSchedule(t *testing.T) {
+	etcd := dial(t)
+	config := chaos.Config{Seed: 42, ErrorRate: 0.5}
+
+	first := failures(chaos.Wrap(etcd, config), 20)
+	if second := failures(chaos.Wrap(etcd, config), 20); !reflect.DeepEqual(first, second) {
+		t.Errorf("The same seed failed %v then %v, expected the same schedule", first, second)
+	}
+
+	count := 0
+	for _, failed := range first {
+		if failed {
+			count++
+		}
+	}
+	if count == 0 || count == len(first) {
+		t.Errorf("%v of %v requests failed, expected about half", count, len(first))
+	}
+
+	wrapped := chaos.Wrap(etcd, config)
+	failures(wrapped, 5)
+	wrapped.SetConfig(config)
+	if again := failures(wrapped, 20); !reflect.DeepEqual(first, again) {
+		t.Errorf("SetConfig failed %v, expected the schedule to restart from the seed", again)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	etcd := chaos.Wrap(dial(t), chaos.Config{ErrorRate: 1})
+
+	if err := etcd.Set("/chaos/key", "value"); err != chaos.ErrInjected {
+		t.Errorf("Set returned %v, expected ErrInjected", err)
+	}
+	if _, err := etcd.Ls("/chaos"); err != chaos.ErrInjected {
+		t.Errorf("Ls returned %v, expected ErrInjected", err)
+	}
+
+	failed := errors.New("etcd down")
+	etcd.SetConfig(chaos.Config{ErrorRate: 1, Err: failed})
+	if _, err := etcd.Get("/chaos/key"); err != failed {
+		t.Errorf("Get returned %v, expected the configured error", err)
+	}
+	if _, err := etcd.WithContext(context.Background()).Get("/chaos/key"); err != failed {
+		t.Errorf("Get through WithContext returned %v, expected the same faults", err)
+	}
+
+	etcd.SetConfig(chaos.Config{})
+	if err := etcd.Set("/chaos/key", "value"); err != nil {
+		t.Errorf("Set with the zero Config returned %v", err)
+	}
+	if value, err := etcd.Get("/chaos/key"); err != nil || value != "value" {
+		t.Errorf("Get with the zero Config returned %q, %v", value, err)
+	}
+}
+
+func TestLatency(t *testing.T) {
+	etcd := chaos.Wrap(dial(t), chaos.Config{Latency: 20 * time.Millisecond})
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		etcd.Get("/chaos/key")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || elapsed > 10*20*time.Millisecond+time.Second {
+		t.Errorf("10 requests took %v, expected each to wait up to 20ms", elapsed)
+	}
+}
+
+func TestDroppedWatchEvents(t *testing.T) {
+	inner := dial(t)
+	etcd := chaos.Wrap(inner, chaos.Config{DropRate: 1})
+
+	keys := make(chan string, 10)
+	go etcd.WatchRecursive("/chaos", func(key, value string) { keys <- key })
+	time.Sleep(100 * time.Millisecond)
+
+	inner.Set("/chaos/dropped", "value")
+	time.Sleep(100 * time.Millisecond)
+	etcd.SetConfig(chaos.Config{})
+	inner.Set("/chaos/delivered", "value")
+
+	select {
+	case key := <-keys:
+		if key != "/chaos/delivered" {
+			t.Errorf("The watch got %v, expected the first change to be dropped", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The watch did not get the change made without faults")
+	}
+	inner.Close()
+}
@@ -0,0 +1,665 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/bench"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/render"
+	"github.com/octoblu/go-simple-etcd-client/webhook"
+)
+
+type command struct {
+	name        string
+	usage       string
+	description string
+	run         func(etcd *etcdclient.SimpleEtcdClient, args []string) error
+}
+
+var commands = []command{
+	{"get", "get <key>", "print the value of a key", get},
+	{"set", "set <key> <value>", "set the value of a key", set},
+	{"del", "del [--dir | --prefix [--dry-run]] <key>", "delete a key, a directory with --dir, or every key under a prefix in batches with --prefix", del},
+	{"ls", "ls [--recursive] <directory>", "list the keys in a directory", ls},
+	{"stats", "stats <directory>", "print how many keys and bytes a directory uses and its largest keys", stats},
+	{"mkdir", "mkdir [--parents] <directory>", "create an empty directory, and its missing parents with --parents", mkdir},
+	{"export", "export <directory>", "print a JSON backup of a directory", export},
+	{"import", "import [--overwrite] [--dry-run] <directory> [file]", "restore a JSON backup from a file or stdin into a directory", importCmd},
+	{"diff", "diff [--file] <directory> <directory | file>", "print the keys that differ between two directories, or a directory and an export with --file", diff},
+	{"backup", "backup [file]", "write a backup of the whole keyspace to a file or stdout", backup},
+	{"restore", "restore [--overwrite] [file]", "restore a backup from a file or stdin", restore},
+	{"watch", "watch [--exec <command>] <directory>", "print every change in a directory, or run a command with KEY and VALUE set", watch},
+	{"audit", "audit <directory> [file]", "append every change in a directory to a file or stdout as JSON lines", audit},
+	{"webhook", "webhook [--secret <secret>] [--batch <size>] [--retries <count>] <directory> <url>", "post every change in a directory to a url as JSON", webhookCmd},
+	{"sync-to", "sync-to [--watch] <directory> <local-dir>", "write every key in a directory to a file in a local directory", syncTo},
+	{"sync-from", "sync-from <local-dir> <directory>", "set a key in a directory for every file in a local directory", syncFrom},
+	{"env", "env <directory> -- <command> [arguments]", "run a command with the keys in a directory as environment variables", env},
+	{"freeze", "freeze [--ttl <duration>] <prefix> <reason>", "reject every write under a prefix, until the ttl passes or unfreeze is run", freeze},
+	{"unfreeze", "unfreeze <prefix>", "allow writes under a frozen prefix again", unfreeze},
+	{"discovery", "discovery new <size> | discovery status <token | url>", "bootstrap a new cluster of size members with this cluster as its discovery service, or show who registered", discovery},
+	{"shell", "shell", "explore the keys interactively with cd, ls, cat and set, with tab completion and history", shellCmd},
+	{"bench", "bench [--clients <n>] [--duration <duration>] [--keys <n>] [--value-size <bytes>] [--reads <ratio>] [--watchers <n>] [prefix]", "measure the latency and throughput of reads, writes and watches", benchCmd},
+	{"render", "render [--watch] [--check-cmd <command>] [--reload-cmd <command>] <directory> <template> <dest>", "render a Go template with the keys in a directory", renderCmd},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+func get(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("get expects exactly 1 argument, got %v", len(args))
+	}
+
+	value, err := etcd.Get(args[0])
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(keyValue{Key: args[0], Value: value})
+	}
+	fmt.Println(value)
+	return nil
+}
+
+// keyValue is a key and its value as get and watch
+// print them with --output
+type keyValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func set(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("set"); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("set expects exactly 2 arguments, got %v", len(args))
+	}
+	return etcd.Set(args[0], args[1])
+}
+
+func del(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("del", flag.ExitOnError)
+	dir := flags.Bool("dir", false, "delete a directory and everything in it")
+	prefix := flags.Bool("prefix", false, "delete every key under a prefix in batches, reporting progress")
+	dryRun := flags.Bool("dry-run", false, "with --prefix, print what would be deleted without deleting it")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 1 {
+		return fmt.Errorf("del expects exactly 1 argument, got %v", len(args))
+	}
+
+	if *dryRun && !*prefix {
+		return fmt.Errorf("del --dry-run only works with --prefix")
+	}
+	if !*dryRun {
+		if err := noOutput("del without --dry-run"); err != nil {
+			return err
+		}
+	}
+	if *dryRun {
+		return printPlan(etcd.PlanDelPrefix(args[0]))
+	}
+	if *prefix {
+		_, err := etcd.DelPrefix(args[0], etcdclient.DelProgress(reportProgress("deleted")))
+		fmt.Fprintln(os.Stderr)
+		return err
+	}
+	if *dir {
+		return etcd.DelDir(args[0])
+	}
+	return etcd.Del(args[0])
+}
+
+func ls(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("ls", flag.ExitOnError)
+	recursive := flags.Bool("recursive", false, "list the keys in every subdirectory as well")
+	args = parseInterspersed(flags, args)
+
+	directory := "/"
+	if len(args) > 1 {
+		return fmt.Errorf("ls expects at most 1 argument, got %v", len(args))
+	}
+	if len(args) == 1 {
+		directory = args[0]
+	}
+
+	list := etcd.Ls
+	if *recursive {
+		list = etcd.LsRecursive
+	}
+
+	keys, err := list(directory)
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(keys)
+	}
+	for _, key := range keys {
+		fmt.Println(key)
+	}
+	return nil
+}
+
+func stats(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("stats expects exactly 1 argument, got %v", len(args))
+	}
+
+	stats, err := etcd.PrefixStats(args[0])
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(stats)
+	}
+
+	fmt.Printf("keys\t%v\n", stats.Keys)
+	fmt.Printf("dirs\t%v\n", stats.Dirs)
+	fmt.Printf("bytes\t%v\n", stats.ValueBytes)
+	fmt.Printf("depth\t%v\n", stats.MaxDepth)
+	fmt.Println("\nlargest keys:")
+	for _, size := range stats.Largest {
+		fmt.Printf("%v\t%v\n", size.Bytes, size.Key)
+	}
+	return nil
+}
+
+func mkdir(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("mkdir"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("mkdir", flag.ExitOnError)
+	parents := flags.Bool("parents", false, "create missing parent directories, and succeed if the directory exists")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 1 {
+		return fmt.Errorf("mkdir expects exactly 1 argument, got %v", len(args))
+	}
+
+	if *parents {
+		return etcd.MkDirAll(args[0])
+	}
+	return etcd.MkDir(args[0])
+}
+
+func export(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("export expects exactly 1 argument, got %v", len(args))
+	}
+
+	data, err := etcd.Export(args[0])
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(json.RawMessage(data))
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func importCmd(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	overwrite := flags.Bool("overwrite", false, "replace keys that already exist")
+	dryRun := flags.Bool("dry-run", false, "print the changes the import would make without making them")
+	args = parseInterspersed(flags, args)
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("import expects 1 or 2 arguments, got %v", len(args))
+	}
+
+	data, err := readFileOrStdin(args[1:])
+	if err != nil {
+		return err
+	}
+
+	if *dryRun {
+		return printPlan(etcd.PlanImport(args[0], data, *overwrite))
+	}
+	if err := noOutput("import without --dry-run"); err != nil {
+		return err
+	}
+	return etcd.Import(args[0], data, *overwrite)
+}
+
+// printPlan prints every planned change on its own line
+func printPlan(plan etcdclient.Plan, err error) error {
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(plan)
+	}
+	for _, op := range plan {
+		fmt.Println(op)
+	}
+	return nil
+}
+
+// readFileOrStdin reads the file named by the first
+// argument, or stdin if there are no arguments
+func readFileOrStdin(args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(args[0])
+}
+
+func diff(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	file := flags.Bool("file", false, "compare the directory with a file written by export")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 2 {
+		return fmt.Errorf("diff expects exactly 2 arguments, got %v", len(args))
+	}
+
+	var entries []etcdclient.DiffEntry
+	var err error
+	if *file {
+		data, readErr := ioutil.ReadFile(args[1])
+		if readErr != nil {
+			return readErr
+		}
+		entries, err = etcd.DiffExport(args[0], data)
+	} else {
+		entries, err = etcd.Diff(args[0], args[1])
+	}
+	if err != nil {
+		return err
+	}
+
+	if output.text() {
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+	} else if err := output.print(entries); err != nil {
+		return err
+	}
+	if len(entries) > 0 {
+		return exitStatus(1)
+	}
+	return nil
+}
+
+func backup(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("backup"); err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("backup expects at most 1 argument, got %v", len(args))
+	}
+
+	out := os.Stdout
+	if len(args) == 1 {
+		file, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	err := etcd.Backup(out, reportProgress("backed up"))
+	fmt.Fprintln(os.Stderr)
+	return err
+}
+
+func restore(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("restore"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	overwrite := flags.Bool("overwrite", false, "replace keys that already exist")
+	args = parseInterspersed(flags, args)
+
+	if len(args) > 1 {
+		return fmt.Errorf("restore expects at most 1 argument, got %v", len(args))
+	}
+
+	in := os.Stdin
+	if len(args) == 1 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		in = file
+	}
+
+	err := etcd.Restore(in, *overwrite, reportProgress("restored"))
+	fmt.Fprintln(os.Stderr)
+	return err
+}
+
+// reportProgress returns a progress callback that keeps
+// the number of keys done up to date on stderr
+func reportProgress(done string) etcdclient.ProgressFunc {
+	return func(count int) {
+		fmt.Fprintf(os.Stderr, "\r%v %v keys", done, count)
+	}
+}
+
+func watch(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("watch", flag.ExitOnError)
+	execCommand := flags.String("exec", "", "shell command to run on every change, with KEY and VALUE in its environment")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 1 {
+		return fmt.Errorf("watch expects exactly 1 argument, got %v", len(args))
+	}
+
+	if *execCommand != "" {
+		if err := noOutput("watch --exec"); err != nil {
+			return err
+		}
+	}
+	if *execCommand == "" && !output.text() {
+		return etcd.WatchRecursive(args[0], func(key, newValue string) {
+			if err := output.stream(keyValue{Key: key, Value: newValue}); err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		})
+	}
+
+	return etcd.WatchRecursive(args[0], func(key, newValue string) {
+		if *execCommand == "" {
+			fmt.Printf("%v\t%v\n", key, newValue)
+			return
+		}
+
+		if err := runOnChange(*execCommand, key, newValue); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running %v for %v: %v\n", *execCommand, key, err)
+		}
+	})
+}
+
+func audit(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("audit"); err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("audit expects 1 or 2 arguments, got %v", len(args))
+	}
+
+	out := os.Stdout
+	if len(args) == 2 {
+		file, err := os.OpenFile(args[1], os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+	return etcd.AuditWatch(args[0], etcdclient.JSONLinesSink(out))
+}
+
+func webhookCmd(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("webhook"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("webhook", flag.ExitOnError)
+	secret := flags.String("secret", "", "sign every request body with HMAC-SHA256 using this secret")
+	batchSize := flags.Int("batch", 0, "post arrays of up to this many events instead of one request per event")
+	retries := flags.Int("retries", 3, "how many times to retry a failed request")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 2 {
+		return fmt.Errorf("webhook expects exactly 2 arguments, got %v", len(args))
+	}
+
+	hook := webhook.Hook{
+		Prefix:    args[0],
+		URL:       args[1],
+		Secret:    *secret,
+		BatchSize: *batchSize,
+		Retries:   *retries,
+	}
+	return webhook.Forward(etcd, hook, func(err error) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	})
+}
+
+func syncTo(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("sync-to"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("sync-to", flag.ExitOnError)
+	watchChanges := flags.Bool("watch", false, "keep the local directory up to date as keys change")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 2 {
+		return fmt.Errorf("sync-to expects exactly 2 arguments, got %v", len(args))
+	}
+
+	if *watchChanges {
+		return etcd.WatchToDir(args[0], args[1])
+	}
+	return etcd.SyncToDir(args[0], args[1])
+}
+
+func syncFrom(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("sync-from"); err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return fmt.Errorf("sync-from expects exactly 2 arguments, got %v", len(args))
+	}
+	return etcd.SyncFromDir(args[0], args[1])
+}
+
+func env(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("env"); err != nil {
+		return err
+	}
+	if len(args) > 1 && args[1] == "--" {
+		args = append(args[:1], args[2:]...)
+	}
+	if len(args) < 2 {
+		return fmt.Errorf("env expects a directory and a command, got %v arguments", len(args))
+	}
+
+	vars, err := etcd.ExportEnv(args[0])
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[1], args[2:]...)
+	cmd.Env = os.Environ()
+	for name, value := range vars {
+		cmd.Env = append(cmd.Env, name+"="+value)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitStatus(exitErr.ExitCode())
+	}
+	return err
+}
+
+func renderCmd(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("render"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("render", flag.ExitOnError)
+	watchChanges := flags.Bool("watch", false, "render again every time the directory changes")
+	checkCmd := flags.String("check-cmd", "", "shell command that checks the rendered file, {{.src}} is replaced by its path")
+	reloadCmd := flags.String("reload-cmd", "", "shell command to run after the destination is replaced")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 3 {
+		return fmt.Errorf("render expects exactly 3 arguments, got %v", len(args))
+	}
+
+	tmpl := render.Template{
+		Prefix:    args[0],
+		Src:       args[1],
+		Dest:      args[2],
+		CheckCmd:  *checkCmd,
+		ReloadCmd: *reloadCmd,
+	}
+	if !*watchChanges {
+		return render.Render(etcd, tmpl)
+	}
+
+	return render.Watch(etcd, tmpl, func(err error) {
+		fmt.Fprintf(os.Stderr, "Error rendering %v: %v\n", tmpl.Dest, err)
+	})
+}
+
+func freeze(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("freeze"); err != nil {
+		return err
+	}
+	flags := flag.NewFlagSet("freeze", flag.ExitOnError)
+	ttl := flags.Duration("ttl", 0, "lift the freeze after this long, 0 keeps it until unfreeze")
+	args = parseInterspersed(flags, args)
+
+	if len(args) != 2 {
+		return fmt.Errorf("freeze expects exactly 2 arguments, got %v", len(args))
+	}
+	return etcd.FreezePrefix(args[0], args[1], *ttl)
+}
+
+func unfreeze(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("unfreeze"); err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("unfreeze expects exactly 1 argument, got %v", len(args))
+	}
+	return etcd.UnfreezePrefix(args[0])
+}
+
+func discovery(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if len(args) != 2 || args[0] != "new" && args[0] != "status" {
+		return fmt.Errorf("discovery expects new <size> or status <token>")
+	}
+
+	if args[0] == "new" {
+		size, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("Discovery size is not a number: %v", args[1])
+		}
+		token, err := etcd.NewDiscoveryToken(size)
+		if err != nil {
+			return err
+		}
+		if !output.text() {
+			return output.print(discoveryToken{Token: token, URL: etcd.DiscoveryURL(token)})
+		}
+		fmt.Println(etcd.DiscoveryURL(token))
+		return nil
+	}
+
+	status, err := etcd.DiscoveryStatus(args[1])
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(status)
+	}
+
+	fmt.Printf("size\t%v\n", status.Size)
+	fmt.Printf("registered\t%v\n", len(status.Members))
+	for _, member := range status.Members {
+		fmt.Printf("%v\t%v\t%v\n", member.ID, member.Name, strings.Join(member.PeerURLs, ","))
+	}
+	return nil
+}
+
+// discoveryToken is a new discovery token as discovery
+// new prints it with --output
+type discoveryToken struct {
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+func benchCmd(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	flags := flag.NewFlagSet("bench", flag.ExitOnError)
+	clients := flags.Int("clients", bench.DefaultWorkload.Clients, "how many requests to make concurrently")
+	duration := flags.Duration("duration", bench.DefaultWorkload.Duration, "how long to make requests for")
+	keys := flags.Int("keys", bench.DefaultWorkload.Keys, "how many distinct keys to read and write")
+	valueSize := flags.Int("value-size", bench.DefaultWorkload.ValueSize, "the size of every value written, in bytes")
+	reads := flags.Float64("reads", 0, "the share of requests that are reads, from 0 to 1")
+	watchers := flags.Int("watchers", 0, "how many watches measure how long writes take to arrive")
+	args = parseInterspersed(flags, args)
+
+	if len(args) > 1 {
+		return fmt.Errorf("bench expects at most 1 argument, got %v", len(args))
+	}
+
+	workload := bench.Workload{
+		Keys:      *keys,
+		ValueSize: *valueSize,
+		Clients:   *clients,
+		Duration:  *duration,
+		ReadRatio: *reads,
+		Watchers:  *watchers,
+	}
+	if len(args) == 1 {
+		workload.Prefix = args[0]
+	}
+
+	fmt.Fprintf(os.Stderr, "running for %v with %v clients\n", workload.Duration, workload.Clients)
+	result, err := bench.Run(etcd, workload)
+	if err != nil {
+		return err
+	}
+	if !output.text() {
+		return output.print(result)
+	}
+
+	fmt.Printf("reads\t%v\n", result.Reads)
+	fmt.Printf("writes\t%v\n", result.Writes)
+	fmt.Printf("watch\t%v\n", result.Watch)
+	fmt.Printf("errors\t%v\n", result.Errors)
+	fmt.Printf("elapsed\t%v\n", result.Elapsed.Round(time.Millisecond))
+	return nil
+}
+
+// runOnChange runs the shell command with KEY and VALUE
+// added to its environment and waits for it to finish
+func runOnChange(command, key, value string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "KEY="+key, "VALUE="+value)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// parseInterspersed parses flags that may appear before, between
+// or after the positional arguments and returns the positional arguments
+func parseInterspersed(flags *flag.FlagSet, args []string) []string {
+	var positional []string
+
+	for {
+		flags.Parse(args)
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T) *etcdclient.SimpleEtcdClient {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	return etcd.(*etcdclient.SimpleEtcdClient)
+}
+
+// captureStdout returns what run prints to stdout
+func captureStdout(t *testing.T, run func() error) string {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Pipe returned %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	printed := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(reader)
+		printed <- data
+	}()
+	if err := run(); err != nil {
+		t.Errorf("The command returned %v", err)
+	}
+	writer.Close()
+	return string(<-printed)
+}
+
+func TestParseInterspersed(t *testing.T) {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	recursive := flags.Bool("recursive", false, "")
+	name := flags.String("name", "", "")
+
+	args := parseInterspersed(flags, []string{"first", "--recursive", "second", "--name", "value", "third"})
+	if expected := []string{"first", "second", "third"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("parseInterspersed returned %v, expected %v", args, expected)
+	}
+	if !*recursive || *name != "value" {
+		t.Errorf("Parsed --recursive %v and --name %q, expected true and \"value\"", *recursive, *name)
+	}
+}
+
+func TestFindCommand(t *testing.T) {
+	for _, cmd := range commands {
+		found, ok := findCommand(cmd.name)
+		if !ok || found.name != cmd.name {
+			t.Errorf("findCommand(%v) returned %v, %v", cmd.name, found.name, ok)
+		}
+	}
+	if _, ok := findCommand("unknown"); ok {
+		t.Error("findCommand of an unknown command returned a command")
+	}
+}
+
+func TestSetGetLsAndDel(t *testing.T) {
+	etcd := dial(t)
+
+	if err := mkdir(etcd, []string{"/cli"}); err != nil {
+		t.Fatalf("mkdir returned %v", err)
+	}
+	if err := set(etcd, []string{"/cli/key", "value"}); err != nil {
+		t.Fatalf("set returned %v", err)
+	}
+	if printed := captureStdout(t, func() error { return get(etcd, []string{"/cli/key"}) }); printed != "value\n" {
+		t.Errorf("get printed %q, expected \"value\\n\"", printed)
+	}
+	if printed := captureStdout(t, func() error { return ls(etcd, []string{"/cli"}) }); printed != "/cli/key\n" {
+		t.Errorf("ls printed %q, expected \"/cli/key\\n\"", printed)
+	}
+
+	if err := del(etcd, []string{"/cli/key"}); err != nil {
+		t.Fatalf("del returned %v", err)
+	}
+	if err := del(etcd, []string{"/cli", "--dir"}); err != nil {
+		t.Fatalf("del --dir returned %v", err)
+	}
+	if keys, err := etcd.Ls("/"); err != nil || len(keys) != 0 {
+		t.Errorf("Ls after del returned %v, %v, expected no keys", keys, err)
+	}
+}
+
+func TestCommandsCheckTheirArguments(t *testing.T) {
+	calls := map[string]func() error{
+		"get":   func() error { return get(nil, nil) },
+		"set":   func() error { return set(nil, []string{"/key"}) },
+		"del":   func() error { return del(nil, nil) },
+		"ls":    func() error { return ls(nil, []string{"/a", "/b"}) },
+		"mkdir": func() error { return mkdir(nil, nil) },
+		"watch": func() error { return watch(nil, nil) },
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Errorf("%v with the wrong arguments returned no error", name)
+		}
+	}
+}
+
+func TestWatchExecRunsTheCommandWithKeyAndValue(t *testing.T) {
+	etcd := dial(t)
+	file := filepath.Join(t.TempDir(), "changes")
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- watch(etcd, []string{"/watched", "--exec", `echo "$KEY=$VALUE" >> ` + file})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/watched/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, _ := ioutil.ReadFile(file)
+		if string(data) == "/watched/key=value\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The command wrote %q, expected \"/watched/key=value\\n\"", data)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	etcd.Close()
+	<-watched
+}
+
+func TestBackupAndRestoreThroughAFile(t *testing.T) {
+	etcd := dial(t)
+	if err := etcd.Set("/backed/up", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	file := filepath.Join(t.TempDir(), "backup.json")
+
+	if err := backup(etcd, []string{file}); err != nil {
+		t.Fatalf("backup returned %v", err)
+	}
+	restored := dial(t)
+	if err := restore(restored, []string{file}); err != nil {
+		t.Fatalf("restore returned %v", err)
+	}
+	if value, err := restored.Get("/backed/up"); err != nil || value != "value" {
+		t.Errorf("Get of a restored key returned %q, %v, expected \"value\"", value, err)
+	}
+}
+
+func TestDryRunsPrintThePlan(t *testing.T) {
+	etcd := dial(t)
+	if err := etcd.Set("/planned/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	printed := captureStdout(t, func() error { return del(etcd, []string{"--prefix", "--dry-run", "/planned"}) })
+	if expected := "- /planned/key (was \"value\")\n"; printed != expected {
+		t.Errorf("del --dry-run printed %q, expected %q", printed, expected)
+	}
+	if value, _ := etcd.Get("/planned/key"); value != "value" {
+		t.Error("del --dry-run deleted the key")
+	}
+}
+
+func TestDiffExitsWithAStatusWhenThePrefixesDiffer(t *testing.T) {
+	etcd := dial(t)
+	for _, key := range []string{"/a/key", "/b/key"} {
+		if err := etcd.Set(key, "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+	if printed := captureStdout(t, func() error { return diff(etcd, []string{"/a", "/b"}) }); printed != "" {
+		t.Errorf("diff of identical prefixes printed %q", printed)
+	}
+
+	if err := etcd.Set("/b/key", "changed"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	printed := captureStdout(t, func() error {
+		if err := diff(etcd, []string{"/a", "/b"}); err != exitStatus(1) {
+			t.Errorf("diff of different prefixes returned %v, expected exit status 1", err)
+		}
+		return nil
+	})
+	if expected := "~ /key = \"value\" -> \"changed\"\n"; printed != expected {
+		t.Errorf("diff printed %q, expected %q", printed, expected)
+	}
+}
+
+func TestStats(t *testing.T) {
+	etcd := dial(t)
+	if err := etcd.Set("/counted/dir/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	printed := captureStdout(t, func() error { return stats(etcd, []string{"/counted"}) })
+	if expected := "keys\t1\ndirs\t1\nbytes\t5\ndepth\t2\n\nlargest keys:\n5\t/counted/dir/key\n"; printed != expected {
+		t.Errorf("stats printed %q, expected %q", printed, expected)
+	}
+}
+
+func TestFreezeAndUnfreeze(t *testing.T) {
+	etcd := dial(t)
+
+	if err := freeze(etcd, []string{"--ttl", "1h", "/cli", "incident 42"}); err != nil {
+		t.Fatalf("freeze returned %v", err)
+	}
+	if err := set(etcd, []string{"/cli/key", "value"}); !errors.Is(err, etcdclient.ErrFrozen) {
+		t.Errorf("set under the frozen prefix returned %v, expected ErrFrozen", err)
+	}
+	if err := unfreeze(etcd, []string{"/cli"}); err != nil {
+		t.Fatalf("unfreeze returned %v", err)
+	}
+	if err := set(etcd, []string{"/cli/key", "value"}); err != nil {
+		t.Errorf("set after unfreeze returned %v", err)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	etcd := dial(t)
+
+	url := strings.TrimSpace(captureStdout(t, func() error { return discovery(etcd, []string{"new", "2"}) }))
+	token := filepath.Base(url)
+	if url != etcd.DiscoveryURL(token) {
+		t.Fatalf("discovery new printed %q, expected the discovery url", url)
+	}
+	etcd.Set(etcdclient.DiscoveryDir+"/"+token+"/1", "infra1=http://10.0.0.1:2380")
+
+	printed := captureStdout(t, func() error { return discovery(etcd, []string{"status", token}) })
+	if expected := "size\t2\nregistered\t1\n1\tinfra1\thttp://10.0.0.1:2380\n"; printed != expected {
+		t.Errorf("discovery status printed %q, expected %q", printed, expected)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// completeFunc returns the words that the last word of the line, word,
+// can be completed to. first is true if it is the first word of the line
+type completeFunc func(word string, first bool) []string
+
+// lineEditor reads lines from a terminal with tab completion and history.
+// If stdin is not a terminal it reads plain lines
+type lineEditor struct {
+	in       *bufio.Reader
+	out      io.Writer
+	complete completeFunc
+	history  []string
+}
+
+func newLineEditor(complete completeFunc) *lineEditor {
+	return &lineEditor{in: bufio.NewReader(os.Stdin), out: os.Stdout, complete: complete}
+}
+
+// readLine shows the prompt and returns the line that was typed,
+// io.EOF once stdin is closed or Ctrl-D is pressed on an empty line
+func (editor *lineEditor) readLine(prompt string) (string, error) {
+	restore, err := makeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return editor.readPlainLine(prompt)
+	}
+	defer restore()
+
+	line := []rune{}
+	historyIndex := len(editor.history)
+	fmt.Fprint(editor.out, prompt)
+
+	redraw := func() {
+		fmt.Fprintf(editor.out, "\r\033[K%v%v", prompt, string(line))
+	}
+
+	for {
+		key, _, err := editor.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch key {
+		case '\r', '\n':
+			fmt.Fprint(editor.out, "\r\n")
+			editor.remember(string(line))
+			return string(line), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(editor.out, "^C\r\n")
+			return "", nil
+		case 4: // Ctrl-D
+			if len(line) == 0 {
+				fmt.Fprint(editor.out, "\r\n")
+				return "", io.EOF
+			}
+		case 127, '\b':
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+				redraw()
+			}
+		case '\t':
+			line = editor.completeLine(line)
+			redraw()
+		case 27: // escape sequences, only the up and down arrows are handled
+			if next, _, _ := editor.in.ReadRune(); next != '[' {
+				continue
+			}
+			arrow, _, _ := editor.in.ReadRune()
+			switch {
+			case arrow == 'A' && historyIndex > 0:
+				historyIndex--
+				line = []rune(editor.history[historyIndex])
+			case arrow == 'B' && historyIndex < len(editor.history):
+				historyIndex++
+				line = []rune{}
+				if historyIndex < len(editor.history) {
+					line = []rune(editor.history[historyIndex])
+				}
+			}
+			redraw()
+		default:
+			if key >= ' ' {
+				line = append(line, key)
+				fmt.Fprint(editor.out, string(key))
+			}
+		}
+	}
+}
+
+// completeLine completes the last word of the line, as far as all the
+// candidates agree. If that adds nothing, the candidates are listed
+func (editor *lineEditor) completeLine(line []rune) []rune {
+	text := string(line)
+	start := strings.LastIndex(text, " ") + 1
+	word := text[start:]
+
+	candidates := editor.complete(word, strings.TrimSpace(text[:start]) == "")
+	if len(candidates) == 0 {
+		return line
+	}
+
+	common := commonPrefix(candidates)
+	if len(candidates) == 1 && !strings.HasSuffix(common, "/") {
+		common += " "
+	}
+	if common != word {
+		return []rune(text[:start] + common)
+	}
+
+	fmt.Fprintf(editor.out, "\r\n%v\r\n", strings.Join(candidates, "  "))
+	return line
+}
+
+func (editor *lineEditor) readPlainLine(prompt string) (string, error) {
+	fmt.Fprint(editor.out, prompt)
+	line, err := editor.in.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	line = strings.TrimRight(line, "\r\n")
+	editor.remember(line)
+	return line, nil
+}
+
+// remember adds the line to the history, skipping blank
+// lines and repeats of the previous line
+func (editor *lineEditor) remember(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(editor.history) > 0 && editor.history[len(editor.history)-1] == line {
+		return
+	}
+	editor.history = append(editor.history, line)
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, word := range words[1:] {
+		for !strings.HasPrefix(word, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
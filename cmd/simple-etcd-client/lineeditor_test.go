@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommonPrefix(t *testing.T) {
+	for expected, words := range map[string][]string{
+		"app":  {"app"},
+		"app/": {"app/db/", "app/name"},
+		"":     {"cd", "ls"},
+	} {
+		if prefix := commonPrefix(words); prefix != expected {
+			t.Errorf("commonPrefix(%q) returned %q, expected %q", words, prefix, expected)
+		}
+	}
+}
+
+func TestCompleteLine(t *testing.T) {
+	var out bytes.Buffer
+	editor := &lineEditor{out: &out, complete: func(word string, first bool) []string {
+		words := []string{"app/db/", "app/name"}
+		if first {
+			words = []string{"cat"}
+		}
+		var candidates []string
+		for _, candidate := range words {
+			if strings.HasPrefix(candidate, word) {
+				candidates = append(candidates, candidate)
+			}
+		}
+		return candidates
+	}}
+
+	for line, expected := range map[string]string{
+		"ca":        "cat ",
+		"cat a":     "cat app/",
+		"cat app/d": "cat app/db/",
+		"cat x":     "cat x",
+	} {
+		if completed := string(editor.completeLine([]rune(line))); completed != expected {
+			t.Errorf("completeLine(%q) returned %q, expected %q", line, completed, expected)
+		}
+	}
+
+	out.Reset()
+	editor.completeLine([]rune("cat app/"))
+	if listed := out.String(); listed != "\r\napp/db/  app/name\r\n" {
+		t.Errorf("completeLine listed %q, expected the candidates", listed)
+	}
+}
+
+func TestReadPlainLineRemembersTheHistory(t *testing.T) {
+	var out bytes.Buffer
+	editor := &lineEditor{in: bufio.NewReader(strings.NewReader("ls\r\nls\n\ncd app")), out: &out}
+
+	var lines []string
+	for {
+		line, err := editor.readPlainLine("> ")
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("readPlainLine returned %v", err)
+		}
+		lines = append(lines, line)
+	}
+	if expected := []string{"ls", "ls", "", "cd app"}; !reflect.DeepEqual(lines, expected) {
+		t.Errorf("readPlainLine returned %q, expected %q", lines, expected)
+	}
+	if expected := []string{"ls", "cd app"}; !reflect.DeepEqual(editor.history, expected) {
+		t.Errorf("The history is %q, expected %q without blanks and repeats", editor.history, expected)
+	}
+	if out.String() != "> > > > > " {
+		t.Errorf("readPlainLine printed %q, expected a prompt per line", out.String())
+	}
+}
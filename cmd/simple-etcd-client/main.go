@@ -0,0 +1,86 @@
+// Command simple-etcd-client is a small etcdctl built on the etcdclient package
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+const defaultEtcdURI = "http://localhost:2379"
+
+func main() {
+	flags := flag.NewFlagSet("simple-etcd-client", flag.ExitOnError)
+	etcdURI := flags.String("etcd-uri", envOrDefault("ETCD_URI", defaultEtcdURI), "etcd uri to connect to, also read from ETCD_URI")
+	outputFormat := flags.String("output", "text", "print results as "+strings.Join(outputFormats, ", ")+" for scripts")
+	flags.Usage = func() { usage(flags) }
+	flags.Parse(os.Args[1:])
+
+	if !validOutputFormat(*outputFormat) {
+		fmt.Fprintf(os.Stderr, "Unknown output format: %v\n", *outputFormat)
+		usage(flags)
+		os.Exit(1)
+	}
+	output.format = *outputFormat
+
+	if flags.NArg() < 1 {
+		usage(flags)
+		os.Exit(1)
+	}
+
+	cmd, ok := findCommand(flags.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command: %v\n", flags.Arg(0))
+		usage(flags)
+		os.Exit(1)
+	}
+
+	dialed, err := etcdclient.Dial(*etcdURI)
+	if err != nil {
+		fatal(err)
+	}
+	// the commands use methods outside of the EtcdClient interface
+	etcd := dialed.(*etcdclient.SimpleEtcdClient)
+
+	err = cmd.run(etcd, flags.Args()[1:])
+	etcd.Close()
+
+	if exit, ok := err.(exitStatus); ok {
+		os.Exit(int(exit))
+	}
+	if err != nil {
+		fatal(err)
+	}
+}
+
+// exitStatus is returned by commands that want to exit with the
+// status without printing an error, main exits once the client is closed
+type exitStatus int
+
+func (status exitStatus) Error() string {
+	return fmt.Sprintf("exit status %v", int(status))
+}
+
+func usage(flags *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: simple-etcd-client [--etcd-uri <uri>] [--output <format>] <command> [arguments]\n\nCommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-40v %v\n", cmd.usage, cmd.description)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flags.PrintDefaults()
+}
+
+func envOrDefault(name, defaultValue string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+func fatal(err error) {
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+	os.Exit(1)
+}
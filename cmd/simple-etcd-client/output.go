@@ -0,0 +1,352 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// outputFormats are the values --output accepts, text is the
+// format every command printed before --output existed
+var outputFormats = []string{"text", "json", "yaml", "table"}
+
+// output prints the results of the commands in the format set by --output
+var output = &printer{format: "text", out: os.Stdout}
+
+// printer prints results as JSON, YAML or a table. Results are
+// marshaled to JSON first, so they use the json tags of their types
+type printer struct {
+	format string
+	out    io.Writer
+
+	// header is true once a stream printed the table header
+	header bool
+}
+
+func validOutputFormat(format string) bool {
+	for _, valid := range outputFormats {
+		if format == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// noOutput returns an error if --output was set, for the commands
+// that have no result to print in another format
+func noOutput(command string) error {
+	if output.text() {
+		return nil
+	}
+	return fmt.Errorf("%v does not print a result, --output is not supported", command)
+}
+
+// text returns true if the command should print its usual text
+func (printer *printer) text() bool {
+	return printer.format == "text"
+}
+
+// print prints a whole result: a JSON document, a YAML document or a
+// table with a row for every item of a list, or a single row for an object
+func (printer *printer) print(result interface{}) error {
+	if list := reflect.ValueOf(result); list.Kind() == reflect.Slice && list.IsNil() {
+		result = []interface{}{}
+	}
+
+	value, err := toOrdered(result)
+	if err != nil {
+		return err
+	}
+
+	switch printer.format {
+	case "yaml":
+		var buffer bytes.Buffer
+		writeYAML(&buffer, value, 0)
+		_, err = printer.out.Write(buffer.Bytes())
+		return err
+	case "table":
+		rows, ok := value.([]interface{})
+		if !ok {
+			rows = []interface{}{value}
+		}
+		writer := tabwriter.NewWriter(printer.out, 0, 4, 2, ' ', 0)
+		writeTable(writer, rows, true)
+		return writer.Flush()
+	default:
+		encoder := json.NewEncoder(printer.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(result)
+	}
+}
+
+// stream prints one item of a result that never ends, like a watch:
+// a line of JSON, a YAML document or a table row, after the header
+// for the first one
+func (printer *printer) stream(item interface{}) error {
+	value, err := toOrdered(item)
+	if err != nil {
+		return err
+	}
+
+	var buffer bytes.Buffer
+	switch printer.format {
+	case "yaml":
+		buffer.WriteString("---\n")
+		writeYAML(&buffer, value, 0)
+	case "table":
+		writeTable(&buffer, []interface{}{value}, !printer.header)
+		printer.header = true
+	default:
+		data, err := json.Marshal(item)
+		if err != nil {
+			return err
+		}
+		buffer.Write(data)
+		buffer.WriteString("\n")
+	}
+	_, err = printer.out.Write(buffer.Bytes())
+	return err
+}
+
+// object is a JSON object that keeps the order of its keys,
+// so tables and YAML list fields in the order of the struct
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// toOrdered marshals the value to JSON and decodes it into objects,
+// []interface{} and scalars
+func toOrdered(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decodeOrdered(decoder)
+}
+
+func decodeOrdered(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case json.Delim('{'):
+		obj := &object{values: make(map[string]interface{})}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values[key.(string)] = value
+		}
+		_, err := decoder.Token()
+		return obj, err
+	case json.Delim('['):
+		list := make([]interface{}, 0)
+		for decoder.More() {
+			value, err := decodeOrdered(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	default:
+		return token, nil
+	}
+}
+
+// writeTable writes a row for every item, with a column for every field
+// of the objects in the order they first appear. Lists of scalars are
+// written one per line without a header. Nested values are written as JSON
+func writeTable(out io.Writer, rows []interface{}, header bool) {
+	var columns []string
+	seen := make(map[string]bool)
+	for _, row := range rows {
+		obj, ok := row.(*object)
+		if !ok {
+			continue
+		}
+		for _, key := range obj.keys {
+			if !seen[key] {
+				seen[key] = true
+				columns = append(columns, key)
+			}
+		}
+	}
+
+	if header && len(columns) > 0 {
+		names := make([]string, len(columns))
+		for i, column := range columns {
+			names[i] = strings.ToUpper(column)
+		}
+		fmt.Fprintln(out, strings.Join(names, "\t"))
+	}
+
+	for _, row := range rows {
+		obj, ok := row.(*object)
+		if !ok {
+			fmt.Fprintln(out, cell(row))
+			continue
+		}
+
+		cells := make([]string, len(columns))
+		for i, column := range columns {
+			cells[i] = cell(obj.values[column])
+		}
+		fmt.Fprintln(out, strings.Join(cells, "\t"))
+	}
+}
+
+// cell formats a value for a table
+func cell(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if strings.ContainsAny(value, "\t\n") {
+			return strconv.Quote(value)
+		}
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		var buffer bytes.Buffer
+		writeJSON(&buffer, value)
+		return buffer.String()
+	}
+}
+
+// writeJSON writes an ordered value as compact JSON
+func writeJSON(out *bytes.Buffer, value interface{}) {
+	switch value := value.(type) {
+	case *object:
+		out.WriteString("{")
+		for i, key := range value.keys {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			writeJSON(out, key)
+			out.WriteString(":")
+			writeJSON(out, value.values[key])
+		}
+		out.WriteString("}")
+	case []interface{}:
+		out.WriteString("[")
+		for i, item := range value {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			writeJSON(out, item)
+		}
+		out.WriteString("]")
+	default:
+		data, _ := json.Marshal(value)
+		out.Write(data)
+	}
+}
+
+// writeYAML writes an ordered value as a YAML block, indented by indent
+// spaces. It only needs to cover what JSON can hold
+func writeYAML(out *bytes.Buffer, value interface{}, indent int) {
+	prefix := strings.Repeat(" ", indent)
+
+	switch value := value.(type) {
+	case *object:
+		if len(value.keys) == 0 {
+			out.WriteString(prefix + "{}\n")
+			return
+		}
+		for _, key := range value.keys {
+			out.WriteString(prefix + yamlScalar(key) + ":")
+			writeYAMLChild(out, value.values[key], indent)
+		}
+	case []interface{}:
+		if len(value) == 0 {
+			out.WriteString(prefix + "[]\n")
+			return
+		}
+		for _, item := range value {
+			// objects start on the same line as their dash
+			if obj, ok := item.(*object); ok && len(obj.keys) > 0 {
+				var buffer bytes.Buffer
+				writeYAML(&buffer, obj, indent+2)
+				out.WriteString(prefix + "- ")
+				out.Write(buffer.Bytes()[indent+2:])
+				continue
+			}
+			out.WriteString(prefix + "-")
+			writeYAMLChild(out, item, indent)
+		}
+	default:
+		out.WriteString(prefix + yamlScalar(value) + "\n")
+	}
+}
+
+// writeYAMLChild writes the value of a mapping key or list item, on the
+// same line if it is a scalar or empty and indented below it otherwise
+func writeYAMLChild(out *bytes.Buffer, value interface{}, indent int) {
+	switch child := value.(type) {
+	case *object:
+		if len(child.keys) > 0 {
+			out.WriteString("\n")
+			writeYAML(out, child, indent+2)
+			return
+		}
+	case []interface{}:
+		if len(child) > 0 {
+			out.WriteString("\n")
+			writeYAML(out, child, indent+2)
+			return
+		}
+	}
+	out.WriteString(" ")
+	writeYAML(out, value, 0)
+}
+
+// plainYAML matches strings that YAML reads back as the same string without
+// quotes. A leading dot must not be followed by a digit, ".5" is a float
+var plainYAML = regexp.MustCompile(`^([A-Za-z_/]|\.[A-Za-z_/.-])[A-Za-z0-9_/.-]*$`)
+
+func yamlScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		switch strings.ToLower(value) {
+		case "true", "false", "yes", "no", "on", "off", "null", "y", "n", ".inf", ".nan":
+			return strconv.Quote(value)
+		}
+		if plainYAML.MatchString(value) {
+			return value
+		}
+		data, _ := json.Marshal(value)
+		return string(data)
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// scalars holds the strings YAML must quote to read them back as strings
+const scalars = `{
+	"plain": "/config/db.host",
+	"empty": "",
+	"bool": "true",
+	"yes": "Yes",
+	"null": "null",
+	"number": "123",
+	"float": "1.5",
+	"dotFloat": ".5",
+	"dotfile": ".env",
+	"infinity": ".Inf",
+	"space": "has space",
+	"colon": "a: b",
+	"dash": "-dash",
+	"quote": "say \"hi\"",
+	"newline": "two\nlines",
+	"key with space": 1,
+	"real": true,
+	"nothing": null
+}`
+
+// nested holds lists and objects in lists, and empty ones
+const nested = `{
+	"list": [1, "two", [3, [4]], {"a": 1, "b": [{"c": {}}]}],
+	"objects": [{"key": "/a", "value": "1"}, {"key": "/b", "value": ""}],
+	"emptyObject": {},
+	"emptyList": [],
+	"listOfEmpty": [{}, []],
+	"deep": {"inner": {"list": ["x"]}}
+}`
+
+// rows is a list of objects with different fields, as tables print them
+const rows = `[
+	{"key": "/a", "value": "1"},
+	{"key": "/b", "value": "tab\there", "dir": true},
+	{"key": "/c", "nested": {"x": [1, 2]}}
+]`
+
+func TestPrint(t *testing.T) {
+	cases := []struct {
+		name   string
+		format string
+		result string
+	}{
+		{"scalars", "yaml", scalars},
+		{"nested", "yaml", nested},
+		{"nested", "json", nested},
+		{"empty-object", "yaml", `{}`},
+		{"empty-list", "yaml", `[]`},
+		{"rows", "table", rows},
+		{"rows", "yaml", rows},
+		{"object", "table", `{"key": "/a", "value": "1"}`},
+		{"scalars-list", "table", `["/a", "/b"]`},
+	}
+
+	for _, c := range cases {
+		var buffer bytes.Buffer
+		printer := &printer{format: c.format, out: &buffer}
+		if err := printer.print(json.RawMessage(c.result)); err != nil {
+			t.Errorf("print of %v as %v returned %v", c.name, c.format, err)
+			continue
+		}
+		golden(t, "print-"+c.name+"."+c.format, buffer.Bytes())
+	}
+}
+
+func TestPrintOfANilList(t *testing.T) {
+	var buffer bytes.Buffer
+	printer := &printer{format: "json", out: &buffer}
+	var keys []string
+	if err := printer.print(keys); err != nil {
+		t.Fatalf("print returned %v", err)
+	}
+	if buffer.String() != "[]\n" {
+		t.Errorf("print of a nil list wrote %q, expected \"[]\\n\"", buffer.String())
+	}
+}
+
+func TestStream(t *testing.T) {
+	items := []keyValue{{Key: "/a", Value: "1"}, {Key: "/b", Value: "true"}, {Key: "/c", Value: ""}}
+	for _, format := range []string{"json", "yaml", "table"} {
+		var buffer bytes.Buffer
+		printer := &printer{format: format, out: &buffer}
+		for _, item := range items {
+			if err := printer.stream(item); err != nil {
+				t.Fatalf("stream as %v returned %v", format, err)
+			}
+		}
+		golden(t, "stream."+format, buffer.Bytes())
+	}
+}
+
+// golden compares got with testdata/<name>.golden,
+// or rewrites the file with go test -update
+func golden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("Failed to update %v: %v", path, err)
+		}
+		return
+	}
+
+	expected, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %v: %v", path, err)
+	}
+	if !bytes.Equal(got, expected) {
+		t.Errorf("%v differs from %v:\n%s\nexpected:\n%s", name, path, got, expected)
+	}
+}
+
+func TestCommandsWithoutAResultRejectOutput(t *testing.T) {
+	output.format = "json"
+	defer func() { output.format = "text" }()
+
+	// every command must fail before using the client
+	calls := map[string]func() error{
+		"set":          func() error { return set(nil, []string{"/key", "value"}) },
+		"del":          func() error { return del(nil, []string{"/key"}) },
+		"mkdir":        func() error { return mkdir(nil, []string{"/dir"}) },
+		"watch --exec": func() error { return watch(nil, []string{"--exec", "true", "/dir"}) },
+		"audit":        func() error { return audit(nil, []string{"/dir"}) },
+		"webhook":      func() error { return webhookCmd(nil, []string{"/dir", "http://localhost"}) },
+		"import":       func() error { return importCmd(nil, []string{"/dir", "/dev/null"}) },
+		"freeze":       func() error { return freeze(nil, []string{"/dir", "reason"}) },
+		"shell":        func() error { return shellCmd(nil, nil) },
+		"sync-from":    func() error { return syncFrom(nil, []string{"local", "/dir"}) },
+		"render":       func() error { return renderCmd(nil, []string{"/dir", "src", "dest"}) },
+		"env":          func() error { return env(nil, []string{"/dir", "true"}) },
+		"backup":       func() error { return backup(nil, nil) },
+		"restore":      func() error { return restore(nil, nil) },
+		"sync-to":      func() error { return syncTo(nil, []string{"/dir", "local"}) },
+		"unfreeze":     func() error { return unfreeze(nil, []string{"/dir"}) },
+	}
+	for name, call := range calls {
+		if err := call(); err == nil {
+			t.Errorf("%v with --output returned no error", name)
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// historyFile is where the shell keeps its history, in the home directory
+const historyFile = ".simple-etcd-client_history"
+
+// maxHistory is how many lines of history the shell keeps
+const maxHistory = 1000
+
+// shell lets operators explore the key tree interactively
+type shell struct {
+	etcd *etcdclient.SimpleEtcdClient
+	cwd  string
+}
+
+type shellCommand struct {
+	name  string
+	usage string
+	run   func(shell *shell, args []string) error
+}
+
+var shellCommands = []shellCommand{
+	{"cd", "cd [directory]", (*shell).cd},
+	{"ls", "ls [directory]", (*shell).ls},
+	{"cat", "cat <key>", (*shell).cat},
+	{"set", "set <key> <value>", (*shell).set},
+	{"rm", "rm [--dir] <key>", (*shell).rm},
+	{"mkdir", "mkdir <directory>", (*shell).mkdir},
+	{"pwd", "pwd", (*shell).pwd},
+}
+
+func shellCmd(etcd *etcdclient.SimpleEtcdClient, args []string) error {
+	if err := noOutput("shell"); err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return fmt.Errorf("shell expects no arguments, got %v", len(args))
+	}
+
+	shell := &shell{etcd: etcd, cwd: "/"}
+	editor := newLineEditor(shell.complete)
+	editor.history = loadHistory()
+	defer func() { saveHistory(editor.history) }()
+
+	for {
+		line, err := editor.readLine(shell.cwd + "> ")
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args := splitWords(line)
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			printShellHelp()
+			continue
+		}
+
+		cmd, ok := findShellCommand(args[0])
+		if !ok {
+			fmt.Fprintf(os.Stderr, "Unknown command: %v, try help\n", args[0])
+			continue
+		}
+		if err := cmd.run(shell, args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+}
+
+// resolve returns the key the argument names, relative to the current directory
+func (shell *shell) resolve(key string) string {
+	if strings.HasPrefix(key, "/") {
+		return etcdclient.Join(key)
+	}
+	return etcdclient.Join(shell.cwd, key)
+}
+
+func (shell *shell) cd(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("cd expects at most 1 argument, got %v", len(args))
+	}
+
+	directory := "/"
+	if len(args) == 1 {
+		directory = shell.resolve(args[0])
+	}
+	if directory != "/" {
+		response, err := shell.etcd.GetResponse(directory, nil)
+		if err != nil {
+			return err
+		}
+		if !response.Node.Dir {
+			return fmt.Errorf("%v is not a directory", directory)
+		}
+	}
+
+	shell.cwd = directory
+	return nil
+}
+
+func (shell *shell) ls(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("ls expects at most 1 argument, got %v", len(args))
+	}
+
+	directory := shell.cwd
+	if len(args) == 1 {
+		directory = shell.resolve(args[0])
+	}
+
+	names, err := shell.names(directory)
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// names returns the names of the directory's children, sorted, with
+// a trailing slash for subdirectories
+func (shell *shell) names(directory string) ([]string, error) {
+	dirs, err := shell.etcd.LsDirs(directory)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := shell.etcd.LsKeys(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(dirs)+len(keys))
+	for _, dir := range dirs {
+		names = append(names, dir+"/")
+	}
+	return append(names, keys...), nil
+}
+
+func (shell *shell) cat(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("cat expects exactly 1 argument, got %v", len(args))
+	}
+
+	value, err := shell.etcd.Get(shell.resolve(args[0]))
+	if err != nil {
+		return err
+	}
+	fmt.Println(value)
+	return nil
+}
+
+func (shell *shell) set(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("set expects exactly 2 arguments, got %v", len(args))
+	}
+	return shell.etcd.Set(shell.resolve(args[0]), args[1])
+}
+
+func (shell *shell) rm(args []string) error {
+	if len(args) == 2 && args[0] == "--dir" {
+		return shell.etcd.DelDir(shell.resolve(args[1]))
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("rm expects exactly 1 argument, got %v", len(args))
+	}
+	return shell.etcd.Del(shell.resolve(args[0]))
+}
+
+func (shell *shell) mkdir(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("mkdir expects exactly 1 argument, got %v", len(args))
+	}
+	return shell.etcd.MkDirAll(shell.resolve(args[0]))
+}
+
+func (shell *shell) pwd(args []string) error {
+	fmt.Println(shell.cwd)
+	return nil
+}
+
+func findShellCommand(name string) (shellCommand, bool) {
+	for _, cmd := range shellCommands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return shellCommand{}, false
+}
+
+func printShellHelp() {
+	for _, cmd := range shellCommands {
+		fmt.Printf("  %v\n", cmd.usage)
+	}
+	fmt.Println("  help")
+	fmt.Println("  exit")
+}
+
+// complete completes command names for the first word
+// and key paths, backed by Ls, for the others
+func (shell *shell) complete(word string, first bool) []string {
+	var candidates []string
+	if first {
+		for _, cmd := range shellCommands {
+			if strings.HasPrefix(cmd.name, word) {
+				candidates = append(candidates, cmd.name)
+			}
+		}
+		return candidates
+	}
+
+	directory, prefix := shell.cwd, word
+	if index := strings.LastIndex(word, "/"); index >= 0 {
+		directory, prefix = shell.resolve(word[:index+1]), word[index+1:]
+	}
+
+	names, err := shell.names(directory)
+	if err != nil {
+		return nil
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) {
+			candidates = append(candidates, word[:len(word)-len(prefix)]+name)
+		}
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+// splitWords splits the line on spaces, keeping
+// words in double or single quotes together
+func splitWords(line string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	inWord := false
+
+	for _, char := range line {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			word.WriteRune(char)
+		case char == '"' || char == '\'':
+			quote = char
+			inWord = true
+		case char == ' ' || char == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(char)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+func historyPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFile)
+}
+
+// loadHistory reads the history saved by the last shell, if any
+func loadHistory() []string {
+	data, err := ioutil.ReadFile(historyPath())
+	if err != nil {
+		return nil
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+}
+
+// saveHistory writes the last maxHistory lines for the next shell
+func saveHistory(history []string) {
+	if len(history) == 0 || historyPath() == "" {
+		return
+	}
+	if len(history) > maxHistory {
+		history = history[len(history)-maxHistory:]
+	}
+	ioutil.WriteFile(historyPath(), []byte(strings.Join(history, "\n")+"\n"), 0600)
+}
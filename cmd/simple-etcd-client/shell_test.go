@@ -0,0 +1,89 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitWords(t *testing.T) {
+	for line, expected := range map[string][]string{
+		"":                               nil,
+		"  ls  ":                         {"ls"},
+		"set /key value":                 {"set", "/key", "value"},
+		"set /key \"two words\"":         {"set", "/key", "two words"},
+		"set /key 'it \"quoted\"'\tnext": {"set", "/key", "it \"quoted\"", "next"},
+		"set /key ''":                    {"set", "/key", ""},
+	} {
+		if words := splitWords(line); !reflect.DeepEqual(words, expected) {
+			t.Errorf("splitWords(%q) returned %q, expected %q", line, words, expected)
+		}
+	}
+}
+
+func TestShellCommands(t *testing.T) {
+	shell := &shell{etcd: dial(t), cwd: "/"}
+	run := func(line string) error {
+		args := splitWords(line)
+		cmd, ok := findShellCommand(args[0])
+		if !ok {
+			t.Fatalf("findShellCommand(%v) found no command", args[0])
+		}
+		return cmd.run(shell, args[1:])
+	}
+
+	for _, line := range []string{"mkdir app/db", "cd app", "set name 'my app'", "set db/host localhost"} {
+		if err := run(line); err != nil {
+			t.Fatalf("%v returned %v", line, err)
+		}
+	}
+	if printed := captureStdout(t, func() error { return run("pwd") }); printed != "/app\n" {
+		t.Errorf("pwd printed %q, expected /app", printed)
+	}
+	if printed := captureStdout(t, func() error { return run("ls") }); printed != "db/\nname\n" {
+		t.Errorf("ls printed %q, expected the directory then the key", printed)
+	}
+	if printed := captureStdout(t, func() error { return run("cat /app/db/host") }); printed != "localhost\n" {
+		t.Errorf("cat printed %q, expected localhost", printed)
+	}
+
+	for _, line := range []string{"cd name", "cd missing", "cat", "set key", "rm"} {
+		if err := run(line); err == nil {
+			t.Errorf("%v returned no error", line)
+		}
+	}
+	if shell.cwd != "/app" {
+		t.Errorf("The failed cds moved the shell to %v", shell.cwd)
+	}
+
+	for _, line := range []string{"rm name", "rm --dir db", "cd"} {
+		if err := run(line); err != nil {
+			t.Fatalf("%v returned %v", line, err)
+		}
+	}
+	if printed := captureStdout(t, func() error { return run("ls app") }); printed != "" {
+		t.Errorf("ls after rm printed %q, expected nothing", printed)
+	}
+}
+
+func TestShellComplete(t *testing.T) {
+	shell := &shell{etcd: dial(t), cwd: "/"}
+	shell.etcd.Set("/app/db/host", "localhost")
+	shell.etcd.Set("/app/name", "app")
+	shell.etcd.Set("/apps", "2")
+
+	for _, test := range []struct {
+		word     string
+		first    bool
+		expected []string
+	}{
+		{"c", true, []string{"cd", "cat"}},
+		{"a", false, []string{"app/", "apps"}},
+		{"app/", false, []string{"app/db/", "app/name"}},
+		{"/app/n", false, []string{"/app/name"}},
+		{"missing/", false, nil},
+	} {
+		if candidates := shell.complete(test.word, test.first); !reflect.DeepEqual(candidates, test.expected) {
+			t.Errorf("complete(%q, %v) returned %q, expected %q", test.word, test.first, candidates, test.expected)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+// makeRaw is not supported here, the shell falls back to reading
+// whole lines without completion or history navigation
+func makeRaw(fd int) (func(), error) {
+	return nil, errors.New("raw terminals are not supported on this platform")
+}
@@ -0,0 +1,110 @@
+package etcdclient
+
+import (
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// updateRetry is how Update backs off when another
+// client changes the key between the read and the write
+var updateRetry = RetryPolicy{MaxRetries: -1, InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}
+
+// DelAndGet deletes a key from Etcd and returns the value it had.
+// Deleting a missing key returns an empty value
+func (etcdClient *SimpleEtcdClient) DelAndGet(key string) (string, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Delete(etcdClient.ctx, key, nil)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return prevValue(response), nil
+}
+
+// Swap sets a value in Etcd and returns the value it replaced.
+// Swapping a missing key returns an empty value
+func (etcdClient *SimpleEtcdClient) Swap(key, newValue string) (string, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Set(etcdClient.ctx, key, newValue, nil)
+	if err != nil {
+		return "", err
+	}
+	return prevValue(response), nil
+}
+
+// GetOrSet returns the value of the key, or atomically sets it to
+// defaultValue if it does not exist. created is true if the key was set
+func (etcdClient *SimpleEtcdClient) GetOrSet(key, defaultValue string) (string, bool, error) {
+	api := etcdClient.keysAPI()
+
+	for {
+		_, err := api.Set(etcdClient.ctx, key, defaultValue, &client.SetOptions{PrevExist: client.PrevNoExist})
+		if err == nil {
+			return defaultValue, true, nil
+		}
+		if !isNodeExist(err) {
+			return "", false, err
+		}
+
+		response, err := api.Get(etcdClient.ctx, key, nil)
+		if err == nil {
+			return response.Node.Value, false, nil
+		}
+		if !isKeyNotFound(err) {
+			return "", false, err
+		}
+		// the key was deleted between the create and the get, try again
+	}
+}
+
+// Update reads the key, calls fn with its value and writes the value fn
+// returns, only if the key has not changed since it was read. If it has, the
+// update is retried with backoff until it succeeds, fn returns an error or
+// the client is closed. A missing key is passed to fn as an empty value
+func (etcdClient *SimpleEtcdClient) Update(key string, fn func(current string) (string, error)) error {
+	api := etcdClient.keysAPI()
+
+	for attempt := 1; ; attempt++ {
+		current := ""
+		opts := &client.SetOptions{PrevExist: client.PrevNoExist}
+
+		response, err := api.Get(etcdClient.ctx, key, nil)
+		if err != nil && !isKeyNotFound(err) {
+			return err
+		}
+		if err == nil {
+			current = response.Node.Value
+			opts = &client.SetOptions{PrevIndex: response.Node.ModifiedIndex}
+		}
+
+		updated, err := fn(current)
+		if err != nil {
+			return err
+		}
+
+		_, err = api.Set(etcdClient.ctx, key, updated, opts)
+		if err == nil || !isConflict(err) {
+			return err
+		}
+
+		if err := etcdClient.sleep(updateRetry.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// isConflict returns true if a compare and swap failed
+// because another client changed the key
+func isConflict(err error) bool {
+	return isNodeExist(err) || hasErrorCode(err, client.ErrorCodeTestFailed)
+}
+
+func prevValue(response *client.Response) string {
+	if response.PrevNode == nil {
+		return ""
+	}
+	return response.PrevNode.Value
+}
@@ -0,0 +1,158 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDelAndGet(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/atomic/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	prev, err := etcdClient.DelAndGet("/atomic/key")
+	if err != nil || prev != "value" {
+		t.Errorf("DelAndGet returned %q, %v, expected \"value\"", prev, err)
+	}
+	prev, err = etcdClient.DelAndGet("/atomic/key")
+	if err != nil || prev != "" {
+		t.Errorf("DelAndGet of a deleted key returned %q, %v, expected an empty value", prev, err)
+	}
+}
+
+func TestDelAndGetOnlyOneCallerClaimsTheValue(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/atomic/job", "job"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	claimed := make(chan string, 10)
+	var wait sync.WaitGroup
+	for i := 0; i < cap(claimed); i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			if prev, err := etcdClient.DelAndGet("/atomic/job"); err == nil && prev != "" {
+				claimed <- prev
+			}
+		}()
+	}
+	wait.Wait()
+
+	if len(claimed) != 1 {
+		t.Errorf("%v callers claimed the value, expected 1", len(claimed))
+	}
+}
+
+func TestSwap(t *testing.T) {
+	etcdClient := dial(t)
+
+	prev, err := etcdClient.Swap("/atomic/key", "first")
+	if err != nil || prev != "" {
+		t.Errorf("Swap of a missing key returned %q, %v, expected an empty value", prev, err)
+	}
+	prev, err = etcdClient.Swap("/atomic/key", "second")
+	if err != nil || prev != "first" {
+		t.Errorf("Swap returned %q, %v, expected \"first\"", prev, err)
+	}
+	if value, _ := etcdClient.Get("/atomic/key"); value != "second" {
+		t.Errorf("Get after Swap returned %q, expected \"second\"", value)
+	}
+}
+
+func TestGetOrSet(t *testing.T) {
+	etcdClient := dial(t)
+
+	value, created, err := etcdClient.GetOrSet("/atomic/secret", "generated")
+	if err != nil || value != "generated" || !created {
+		t.Errorf("GetOrSet of a missing key returned %q, %v, %v, expected it to be created", value, created, err)
+	}
+	value, created, err = etcdClient.GetOrSet("/atomic/secret", "other")
+	if err != nil || value != "generated" || created {
+		t.Errorf("GetOrSet of an existing key returned %q, %v, %v, expected the existing value", value, created, err)
+	}
+}
+
+func TestGetOrSetCreatesOnce(t *testing.T) {
+	etcdClient := dial(t)
+
+	values := make(chan string, 10)
+	var creates int64
+	var wait sync.WaitGroup
+	for i := 0; i < cap(values); i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			value, created, err := etcdClient.GetOrSet("/atomic/id", fmt.Sprint(i))
+			if err != nil {
+				t.Errorf("GetOrSet returned %v", err)
+			}
+			if created {
+				atomic.AddInt64(&creates, 1)
+			}
+			values <- value
+		}(i)
+	}
+	wait.Wait()
+	close(values)
+
+	if creates != 1 {
+		t.Errorf("%v callers created the key, expected 1", creates)
+	}
+	first := <-values
+	for value := range values {
+		if value != first {
+			t.Errorf("GetOrSet returned both %q and %q", first, value)
+		}
+	}
+}
+
+func TestUpdateCountsEveryConcurrentIncrement(t *testing.T) {
+	etcdClient := dial(t)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			err := etcdClient.Update("/atomic/counter", func(current string) (string, error) {
+				count, _ := strconv.Atoi(current)
+				return strconv.Itoa(count + 1), nil
+			})
+			if err != nil {
+				t.Errorf("Update returned %v", err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	if value, _ := etcdClient.Get("/atomic/counter"); value != "10" {
+		t.Errorf("The counter is %q after 10 concurrent updates, expected \"10\"", value)
+	}
+}
+
+func TestUpdateReturnsTheErrorOfFn(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/atomic/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	failed := errors.New("failed")
+
+	err := etcdClient.Update("/atomic/key", func(current string) (string, error) {
+		if current != "value" {
+			t.Errorf("fn was called with %q, expected \"value\"", current)
+		}
+		return "updated", failed
+	})
+	if err != failed {
+		t.Errorf("Update returned %v, expected the error of fn", err)
+	}
+	if value, _ := etcdClient.Get("/atomic/key"); value != "value" {
+		t.Errorf("Update wrote %q although fn failed", value)
+	}
+}
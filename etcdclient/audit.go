@@ -0,0 +1,98 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// AuditRecord describes a single change seen by AuditWatch
+type AuditRecord struct {
+	// Time is when the change was seen, etcd v2 does
+	// not record when changes are made
+	Time time.Time `json:"time"`
+
+	Action    string `json:"action"`
+	Key       string `json:"key"`
+	PrevValue string `json:"prevValue,omitempty"`
+	Value     string `json:"value,omitempty"`
+	Dir       bool   `json:"dir,omitempty"`
+	Index     uint64 `json:"index"`
+}
+
+// AuditSink stores the records produced by AuditWatch
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// AuditSinkFunc lets a function be used as an AuditSink
+type AuditSinkFunc func(record AuditRecord) error
+
+// Record calls the function
+func (fn AuditSinkFunc) Record(record AuditRecord) error {
+	return fn(record)
+}
+
+// JSONLinesSink returns a sink that writes every record to w as a line
+// of JSON. w can be a file, or a *syslog.Writer to send records to syslog
+func JSONLinesSink(w io.Writer) AuditSink {
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(w)
+
+	return AuditSinkFunc(func(record AuditRecord) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return encoder.Encode(record)
+	})
+}
+
+// AuditWatch records every change under the prefix to the sink, with the
+// previous and new values. Nothing is skipped: if etcd has already cleared
+// the events the watch needs, or the sink fails, the watch stops and the
+// error is returned. The sink is called from the watch, in order, even
+// with WithWatchBuffer or WithWatchWorkers, so no record is dropped.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) AuditWatch(prefix string, sink AuditSink) error {
+	ctx, cancel := context.WithCancel(etcdClient.ctx)
+	defer cancel()
+	watchClient := etcdClient.WithContext(ctx).(*SimpleEtcdClient)
+
+	var mutex sync.Mutex
+	var sinkErr error
+
+	err := watchClient.watchDirect(prefix, 0, false, func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if sinkErr != nil {
+			return
+		}
+
+		if err := sink.Record(newAuditRecord(event)); err != nil {
+			sinkErr = fmt.Errorf("Failed to record %v of %v: %v", event.Action, event.Key, err)
+			cancel()
+		}
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if sinkErr != nil {
+		return sinkErr
+	}
+	return err
+}
+
+func newAuditRecord(event Event) AuditRecord {
+	return AuditRecord{
+		Time:      time.Now(),
+		Action:    event.Action,
+		Key:       event.Key,
+		PrevValue: event.PrevValue,
+		Value:     event.Value,
+		Dir:       event.Dir,
+		Index:     event.Index,
+	}
+}
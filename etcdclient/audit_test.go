@@ -0,0 +1,46 @@
+package etcdclient_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestAuditWatchRecordsEveryChangeWithAWatchBuffer(t *testing.T) {
+	etcdClient := dial(t, etcdclient.WithWatchBuffer(1, etcdclient.BufferCoalesce), etcdclient.WithWatchWorkers(2))
+
+	records := make(chan etcdclient.AuditRecord, 100)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- etcdClient.AuditWatch("/audit", etcdclient.AuditSinkFunc(func(record etcdclient.AuditRecord) error {
+			// a slow sink fills the buffer the events would coalesce in
+			time.Sleep(5 * time.Millisecond)
+			records <- record
+			return nil
+		}))
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	const changes = 10
+	for i := 0; i < changes; i++ {
+		if err := etcdClient.Set("/audit/key", fmt.Sprint(i)); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	for i := 0; i < changes; i++ {
+		select {
+		case record := <-records:
+			if record.Key != "/audit/key" || record.Value != fmt.Sprint(i) {
+				t.Fatalf("Record %v is %v of %v to %q, expected the value %q", i, record.Action, record.Key, record.Value, fmt.Sprint(i))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Got %v records, expected %v", i, changes)
+		}
+	}
+
+	etcdClient.Close()
+	<-watched
+}
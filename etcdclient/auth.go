@@ -0,0 +1,95 @@
+package etcdclient
+
+import (
+	"strings"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// Permission is the access a role is granted on a prefix
+type Permission int
+
+const (
+	// ReadPermission allows reading keys
+	ReadPermission Permission = iota
+
+	// WritePermission allows writing keys
+	WritePermission
+
+	// ReadWritePermission allows reading and writing keys
+	ReadWritePermission
+)
+
+// AddUser creates a user with the password
+func (etcdClient *SimpleEtcdClient) AddUser(username, password string) error {
+	return etcdClient.authRequest("addUser", username, func(ctx context.Context) error {
+		return client.NewAuthUserAPI(etcdClient.etcd).AddUser(ctx, username, password)
+	})
+}
+
+// RemoveUser deletes a user
+func (etcdClient *SimpleEtcdClient) RemoveUser(username string) error {
+	return etcdClient.authRequest("removeUser", username, func(ctx context.Context) error {
+		return client.NewAuthUserAPI(etcdClient.etcd).RemoveUser(ctx, username)
+	})
+}
+
+// GrantUserRoles gives the user the permissions of the roles
+func (etcdClient *SimpleEtcdClient) GrantUserRoles(username string, roles ...string) error {
+	return etcdClient.authRequest("grantUser", username, func(ctx context.Context) error {
+		_, err := client.NewAuthUserAPI(etcdClient.etcd).GrantUser(ctx, username, roles)
+		return err
+	})
+}
+
+// AddRole creates a role without any permissions
+func (etcdClient *SimpleEtcdClient) AddRole(role string) error {
+	return etcdClient.authRequest("addRole", role, func(ctx context.Context) error {
+		return client.NewAuthRoleAPI(etcdClient.etcd).AddRole(ctx, role)
+	})
+}
+
+// RemoveRole deletes a role
+func (etcdClient *SimpleEtcdClient) RemoveRole(role string) error {
+	return etcdClient.authRequest("removeRole", role, func(ctx context.Context) error {
+		return client.NewAuthRoleAPI(etcdClient.etcd).RemoveRole(ctx, role)
+	})
+}
+
+// GrantRoleOnPrefix grants the role the permission on the
+// prefix and every key under it
+func (etcdClient *SimpleEtcdClient) GrantRoleOnPrefix(role, prefix string, permission Permission) error {
+	prefixes := []string{prefix, strings.TrimSuffix(prefix, "/") + "/*"}
+	return etcdClient.authRequest("grantRole", role, func(ctx context.Context) error {
+		_, err := client.NewAuthRoleAPI(etcdClient.etcd).GrantRoleKV(ctx, role, prefixes, client.PermissionType(permission))
+		return err
+	})
+}
+
+// EnableAuth turns on authentication, the root user must exist.
+// Once it is on, clients must connect with WithBasicAuth
+func (etcdClient *SimpleEtcdClient) EnableAuth() error {
+	return etcdClient.authRequest("enableAuth", "", func(ctx context.Context) error {
+		return client.NewAuthAPI(etcdClient.etcd).Enable(ctx)
+	})
+}
+
+// DisableAuth turns off authentication
+func (etcdClient *SimpleEtcdClient) DisableAuth() error {
+	return etcdClient.authRequest("disableAuth", "", func(ctx context.Context) error {
+		return client.NewAuthAPI(etcdClient.etcd).Disable(ctx)
+	})
+}
+
+// authRequest makes a request that changes the users, roles or
+// auth settings of the cluster, which read-only clients refuse
+func (etcdClient *SimpleEtcdClient) authRequest(op, name string, request func(ctx context.Context) error) error {
+	if etcdClient.options.readOnly {
+		return wrapError(op, name, ErrReadOnly)
+	}
+
+	ctx, cancel := etcdClient.withContext(etcdClient.ctx)
+	defer cancel()
+	return wrapError(op, name, request(ctx))
+}
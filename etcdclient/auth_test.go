@@ -0,0 +1,101 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// authServer answers the auth requests that etcdtest does not
+// support, recording them and failing those on refused names
+type authServer struct {
+	mutex    sync.Mutex
+	requests []string
+	refused  string
+}
+
+func (server *authServer) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	body, _ := ioutil.ReadAll(request.Body)
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	server.requests = append(server.requests, strings.TrimSpace(fmt.Sprintf("%v %v %s", request.Method, request.URL.Path, body)))
+
+	if server.refused != "" && strings.HasSuffix(request.URL.Path, "/"+server.refused) {
+		writer.WriteHeader(http.StatusConflict)
+		fmt.Fprint(writer, `{"message": "already exists"}`)
+		return
+	}
+	fmt.Fprint(writer, "{}")
+}
+
+// dialAuth returns a client of a new authServer
+func dialAuth(t *testing.T, opts ...etcdclient.Option) (*authServer, *etcdclient.SimpleEtcdClient) {
+	auth := &authServer{}
+	server := httptest.NewServer(auth)
+	t.Cleanup(server.Close)
+
+	etcdClient, err := etcdclient.Dial(server.URL, opts...)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	t.Cleanup(func() { etcdClient.Close() })
+	return auth, etcdClient.(*etcdclient.SimpleEtcdClient)
+}
+
+func TestAuthAdministration(t *testing.T) {
+	auth, etcdClient := dialAuth(t)
+
+	steps := []error{
+		etcdClient.AddUser("root", "secret"),
+		etcdClient.AddRole("writer"),
+		etcdClient.GrantRoleOnPrefix("writer", "/app/", etcdclient.ReadWritePermission),
+		etcdClient.GrantUserRoles("root", "writer"),
+		etcdClient.EnableAuth(),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatalf("An auth request returned %v", err)
+		}
+	}
+
+	expected := []string{
+		`PUT /v2/auth/users/root {"user":"root","password":"secret","roles":null}`,
+		`PUT /v2/auth/roles/writer {"role":"writer","permissions":{"kv":{"read":null,"write":null}}}`,
+		`PUT /v2/auth/roles/writer {"role":"writer","permissions":{"kv":{"read":null,"write":null}},"grant":{"kv":{"read":["/app/","/app/*"],"write":["/app/","/app/*"]}}}`,
+		`PUT /v2/auth/users/root {"user":"root","roles":null,"grant":["writer"]}`,
+		`PUT /v2/auth/enable`,
+	}
+	if !reflect.DeepEqual(auth.requests, expected) {
+		t.Errorf("The auth requests were\n%v\nexpected\n%v", strings.Join(auth.requests, "\n"), strings.Join(expected, "\n"))
+	}
+}
+
+func TestAuthErrorsRecordTheOperation(t *testing.T) {
+	auth, etcdClient := dialAuth(t)
+	auth.refused = "writer"
+
+	err := etcdClient.AddRole("writer")
+	var wrapped *etcdclient.Error
+	if !errors.As(err, &wrapped) || wrapped.Op != "addRole" || wrapped.Key != "writer" {
+		t.Errorf("AddRole of a refused role returned %#v, expected an Error of the addRole of writer", err)
+	}
+}
+
+func TestAuthWithReadOnly(t *testing.T) {
+	auth, etcdClient := dialAuth(t, etcdclient.WithReadOnly())
+
+	if err := etcdClient.AddUser("root", "secret"); !errors.Is(err, etcdclient.ErrReadOnly) {
+		t.Errorf("AddUser of a read-only client returned %v, expected ErrReadOnly", err)
+	}
+	if len(auth.requests) != 0 {
+		t.Errorf("A read-only client sent %v", auth.requests)
+	}
+}
@@ -0,0 +1,154 @@
+package etcdclient
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Barrier blocks the workers waiting on it until it is released.
+// It is held for as long as its key exists
+type Barrier struct {
+	etcdClient *SimpleEtcdClient
+	key        string
+}
+
+// DoubleBarrier lets count workers enter a computation together and
+// leave it together. Each worker waits in Enter until count workers
+// have entered, and in Leave until every worker has left
+type DoubleBarrier struct {
+	etcdClient *SimpleEtcdClient
+	key        string
+	count      int
+	entry      string
+}
+
+// Barrier returns the barrier stored at key
+func (etcdClient *SimpleEtcdClient) Barrier(key string) *Barrier {
+	return &Barrier{etcdClient: etcdClient, key: key}
+}
+
+// Hold holds the barrier, so workers calling Wait block until it is
+// released. It fails if the barrier is already held
+func (barrier *Barrier) Hold() error {
+	etcdClient := barrier.etcdClient
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, barrier.key, "", &client.SetOptions{PrevExist: client.PrevNoExist})
+	if isNodeExist(err) {
+		return fmt.Errorf("Barrier %v is already held", barrier.key)
+	}
+	return err
+}
+
+// Release releases the barrier, unblocking every waiting worker
+func (barrier *Barrier) Release() error {
+	return barrier.etcdClient.Del(barrier.key)
+}
+
+// Wait blocks until the barrier is not held. It returns immediately
+// if the barrier is not held
+func (barrier *Barrier) Wait() error {
+	return barrier.etcdClient.waitForRemoval(barrier.key)
+}
+
+// DoubleBarrier returns the double barrier for count workers stored in
+// the directory key. Every worker must use its own DoubleBarrier
+func (etcdClient *SimpleEtcdClient) DoubleBarrier(key string, count int) *DoubleBarrier {
+	return &DoubleBarrier{etcdClient: etcdClient, key: key, count: count}
+}
+
+// Enter registers the worker and blocks until count workers have entered
+func (barrier *DoubleBarrier) Enter() error {
+	if barrier.entry != "" {
+		return fmt.Errorf("Already entered double barrier %v", barrier.key)
+	}
+
+	etcdClient := barrier.etcdClient
+	api := etcdClient.keysAPI()
+	response, err := api.CreateInOrder(etcdClient.ctx, barrier.waiters(), "", nil)
+	if err != nil {
+		return err
+	}
+	barrier.entry = response.Node.Key
+
+	// a follower that lags behind could miss the last entrants, and
+	// then no one would create the ready key, so the count needs a quorum
+	entered, err := api.Get(etcdClient.ctx, barrier.waiters(), &client.GetOptions{Quorum: true})
+	if err != nil {
+		return err
+	}
+	if len(entered.Node.Nodes) >= barrier.count {
+		if _, err := api.Set(etcdClient.ctx, barrier.ready(), "", nil); err != nil {
+			return err
+		}
+	}
+
+	_, err = etcdClient.WaitForKey(etcdClient.ctx, barrier.ready())
+	return err
+}
+
+// Leave unregisters the worker and blocks until every worker has left
+func (barrier *DoubleBarrier) Leave() error {
+	if barrier.entry == "" {
+		return fmt.Errorf("Have not entered double barrier %v", barrier.key)
+	}
+
+	etcdClient := barrier.etcdClient
+	if err := etcdClient.Del(barrier.entry); err != nil {
+		return err
+	}
+	barrier.entry = ""
+
+	api := etcdClient.keysAPI()
+	for {
+		response, err := api.Get(etcdClient.ctx, barrier.waiters(), &client.GetOptions{Quorum: true})
+		if err != nil {
+			return err
+		}
+
+		if len(response.Node.Nodes) == 0 {
+			return etcdClient.Del(barrier.ready())
+		}
+
+		watcher := api.Watcher(barrier.waiters(), &client.WatcherOptions{AfterIndex: response.Index, Recursive: true})
+		if _, err := watcher.Next(etcdClient.ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// waiters is the directory holding an entry for every worker
+// that has entered the barrier
+func (barrier *DoubleBarrier) waiters() string {
+	return path.Join(barrier.key, "waiters")
+}
+
+// ready exists while the workers are allowed in
+func (barrier *DoubleBarrier) ready() string {
+	return path.Join(barrier.key, "ready")
+}
+
+// waitForRemoval blocks until the key does not exist
+func (etcdClient *SimpleEtcdClient) waitForRemoval(key string) error {
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, key, nil)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	watcher := api.Watcher(key, &client.WatcherOptions{AfterIndex: response.Index})
+	for {
+		response, err := watcher.Next(etcdClient.ctx)
+		if err != nil {
+			return err
+		}
+
+		if isRemoval(response.Action) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package etcdclient_test
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBarrier(t *testing.T) {
+	etcdClient := dial(t)
+	barrier := etcdClient.Barrier("/barrier")
+
+	if err := barrier.Wait(); err != nil {
+		t.Fatalf("Wait on a barrier that is not held returned %v", err)
+	}
+	if err := barrier.Hold(); err != nil {
+		t.Fatalf("Hold returned %v", err)
+	}
+	if err := etcdClient.Barrier("/barrier").Hold(); err == nil {
+		t.Error("Hold of a barrier that is already held returned no error")
+	}
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- etcdClient.Barrier("/barrier").Wait()
+	}()
+	select {
+	case err := <-waited:
+		t.Fatalf("Wait returned %v while the barrier was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := barrier.Release(); err != nil {
+		t.Fatalf("Release returned %v", err)
+	}
+	select {
+	case err := <-waited:
+		if err != nil {
+			t.Errorf("Wait returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Wait did not return after Release")
+	}
+}
+
+func TestDoubleBarrier(t *testing.T) {
+	etcdClient := dial(t)
+	const workers = 3
+
+	var entered, left int64
+	var wait sync.WaitGroup
+	work := func() {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			barrier := etcdClient.DoubleBarrier("/double", workers)
+			if err := barrier.Enter(); err != nil {
+				t.Errorf("Enter returned %v", err)
+				return
+			}
+			if count := atomic.AddInt64(&entered, 1); atomic.LoadInt64(&left) != 0 {
+				t.Errorf("Worker %v entered after a worker left", count)
+			}
+			if err := barrier.Enter(); err == nil {
+				t.Error("Enter of a double barrier that was already entered returned no error")
+			}
+
+			if err := barrier.Leave(); err != nil {
+				t.Errorf("Leave returned %v", err)
+				return
+			}
+			atomic.AddInt64(&left, 1)
+		}()
+	}
+
+	// the first workers wait in Enter until the last one comes
+	for i := 0; i < workers-1; i++ {
+		work()
+	}
+	time.Sleep(100 * time.Millisecond)
+	if count := atomic.LoadInt64(&entered); count != 0 {
+		t.Errorf("%v workers got past Enter before all %v entered", count, workers)
+	}
+	work()
+	wait.Wait()
+
+	if err := etcdClient.DoubleBarrier("/double", workers).Leave(); err == nil {
+		t.Error("Leave of a double barrier that was not entered returned no error")
+	}
+}
@@ -0,0 +1,160 @@
+package etcdclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/coreos/etcd/client"
+)
+
+// maxConcurrentRequests limits how many requests a batch
+// operation makes to etcd at the same time
+const maxConcurrentRequests = 16
+
+// MultiError is returned by batch operations, it holds
+// the error for every key that failed
+type MultiError map[string]error
+
+// Error lists every key that failed along with its error
+func (multiError MultiError) Error() string {
+	keys := make([]string, 0, len(multiError))
+	for key := range multiError {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	messages := make([]string, len(keys))
+	for i, key := range keys {
+		messages[i] = fmt.Sprintf("%v: %v", key, multiError[key])
+	}
+	return fmt.Sprintf("%v keys failed: %v", len(keys), strings.Join(messages, "; "))
+}
+
+// GetMulti gets many keys concurrently. Missing keys are returned as
+// empty strings, like Get. If any fail, the values that could be read
+// are returned along with a MultiError holding the error for each
+// failed key
+func (etcdClient *SimpleEtcdClient) GetMulti(keys []string) (map[string]string, error) {
+	values := make(map[string]string)
+	var mutex sync.Mutex
+
+	err := etcdClient.forEachKey(keys, func(key string) error {
+		value, err := etcdClient.Get(key)
+		if err != nil {
+			return err
+		}
+
+		mutex.Lock()
+		values[key] = value
+		mutex.Unlock()
+		return nil
+	})
+	return values, err
+}
+
+// SetMulti sets many keys concurrently. Every key is attempted, if any
+// fail a MultiError is returned with the error for each failed key
+func (etcdClient *SimpleEtcdClient) SetMulti(kvs map[string]string) error {
+	return etcdClient.forEachKey(mapKeys(kvs), func(key string) error {
+		return etcdClient.Set(key, kvs[key])
+	})
+}
+
+// SetMultiAllOrNothing sets many keys concurrently. If any key fails,
+// the keys that were set are restored to their previous values and a
+// MultiError is returned. Etcd v2 has no transactions, so other clients
+// can observe the partial write before it is rolled back
+func (etcdClient *SimpleEtcdClient) SetMultiAllOrNothing(kvs map[string]string) error {
+	keys := mapKeys(kvs)
+	previous := make(map[string]*client.Node)
+	var mutex sync.Mutex
+
+	err := etcdClient.forEachKey(keys, func(key string) error {
+		node, err := etcdClient.getNode(key)
+		mutex.Lock()
+		previous[key] = node
+		mutex.Unlock()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	setErr := etcdClient.SetMulti(kvs)
+	if setErr == nil {
+		return nil
+	}
+
+	failed := setErr.(MultiError)
+	var written []string
+	for _, key := range keys {
+		if _, ok := failed[key]; !ok {
+			written = append(written, key)
+		}
+	}
+
+	err = etcdClient.forEachKey(written, func(key string) error {
+		if previous[key] == nil {
+			return etcdClient.Del(key)
+		}
+		return etcdClient.Set(key, previous[key].Value)
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to roll back after %v: %v", setErr, err)
+	}
+	return setErr
+}
+
+// getNode returns the node at key, or nil if it does not exist
+func (etcdClient *SimpleEtcdClient) getNode(key string) (*client.Node, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, key, nil)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return response.Node, nil
+}
+
+// forEachKey calls fn for every key with bounded concurrency and
+// returns a MultiError holding every error fn returned
+func (etcdClient *SimpleEtcdClient) forEachKey(keys []string, fn func(key string) error) error {
+	errs := make(MultiError)
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+	limit := make(chan struct{}, maxConcurrentRequests)
+
+	for _, key := range keys {
+		wait.Add(1)
+		limit <- struct{}{}
+		go func(key string) {
+			defer wait.Done()
+			defer func() { <-limit }()
+
+			if err := fn(key); err != nil {
+				mutex.Lock()
+				errs[key] = err
+				mutex.Unlock()
+			}
+		}(key)
+	}
+	wait.Wait()
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func mapKeys(kvs map[string]string) []string {
+	keys := make([]string, 0, len(kvs))
+	for key := range kvs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
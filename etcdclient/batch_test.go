@@ -0,0 +1,111 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestSetMultiReportsEveryFailedKey(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.MkDir("/batch/dir"); err != nil {
+		t.Fatalf("MkDir returned %v", err)
+	}
+
+	err := etcdClient.SetMulti(map[string]string{"/batch/a": "1", "/batch/b": "2", "/batch/dir": "3"})
+	failed, ok := err.(etcdclient.MultiError)
+	if !ok || len(failed) != 1 || failed["/batch/dir"] == nil {
+		t.Fatalf("SetMulti returned %v, expected a MultiError for /batch/dir", err)
+	}
+	values, _ := etcdClient.GetMulti([]string{"/batch/a", "/batch/b"})
+	if values["/batch/a"] != "1" || values["/batch/b"] != "2" {
+		t.Errorf("SetMulti set %v, expected the other keys to be set", values)
+	}
+}
+
+func TestSetMultiAllOrNothingRollsBack(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/batch/existing", "before"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.MkDir("/batch/dir"); err != nil {
+		t.Fatalf("MkDir returned %v", err)
+	}
+
+	err := etcdClient.SetMultiAllOrNothing(map[string]string{"/batch/existing": "after", "/batch/new": "new", "/batch/dir": "value"})
+	if _, ok := err.(etcdclient.MultiError); !ok {
+		t.Fatalf("SetMultiAllOrNothing returned %v, expected a MultiError", err)
+	}
+	if value, _ := etcdClient.Get("/batch/existing"); value != "before" {
+		t.Errorf("An existing key is %q after the rollback, expected \"before\"", value)
+	}
+	keys, err := etcdClient.Ls("/batch")
+	if err != nil {
+		t.Fatalf("Ls returned %v", err)
+	}
+	for _, key := range keys {
+		if key == "/batch/new" {
+			t.Error("A new key was not deleted by the rollback")
+		}
+	}
+}
+
+func TestSetMultiAllOrNothing(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.SetMultiAllOrNothing(map[string]string{"/batch/a": "1", "/batch/b": "2"}); err != nil {
+		t.Fatalf("SetMultiAllOrNothing returned %v", err)
+	}
+	if value, _ := etcdClient.Get("/batch/b"); value != "2" {
+		t.Errorf("Get returned %q, expected \"2\"", value)
+	}
+}
+
+func TestGetMulti(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/batch/a", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	values, err := etcdClient.GetMulti([]string{"/batch/a", "/batch/missing"})
+	if err != nil {
+		t.Fatalf("GetMulti returned %v", err)
+	}
+	if expected := map[string]string{"/batch/a": "1", "/batch/missing": ""}; !reflect.DeepEqual(values, expected) {
+		t.Errorf("GetMulti returned %v, expected %v", values, expected)
+	}
+}
+
+func TestGetMultiReturnsPartialResults(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	plain, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer plain.Close()
+	encrypted, err := server.Client(etcdclient.WithEncryption(make([]byte, 16)))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer encrypted.Close()
+
+	if err := encrypted.Set("/batch/a", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	// a value that looks encrypted but cannot be decrypted
+	if err := plain.Set("/batch/broken", "aesgcm:broken"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	values, err := encrypted.(*etcdclient.SimpleEtcdClient).GetMulti([]string{"/batch/a", "/batch/broken"})
+	failed, ok := err.(etcdclient.MultiError)
+	if !ok || len(failed) != 1 || failed["/batch/broken"] == nil {
+		t.Fatalf("GetMulti returned %v, expected a MultiError for /batch/broken", err)
+	}
+	if values["/batch/a"] != "1" {
+		t.Errorf("GetMulti returned %v, expected the value that could be read", values)
+	}
+}
@@ -0,0 +1,196 @@
+package etcdclient
+
+import (
+	"fmt"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Bind reads every key under prefix and stores the values in the fields of
+// the struct out points to. A field is read from the key named by its etcd
+// tag, or by the field name if it has no tag, and is skipped if the tag is
+// "-". Struct fields are read from the subdirectory of the same name. String,
+// bool, integer, float and time.Duration fields are supported. Fields whose
+// key does not exist are left unchanged
+func (etcdClient *SimpleEtcdClient) Bind(prefix string, out interface{}) error {
+	target := reflect.ValueOf(out)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("Bind requires a pointer to a struct, got %T", out)
+	}
+
+	values, err := etcdClient.valuesUnder(prefix)
+	if err != nil {
+		return err
+	}
+	return bindStruct(values, "", target.Elem())
+}
+
+// valuesUnder returns every value under prefix, keyed by
+// the key relative to prefix
+func (etcdClient *SimpleEtcdClient) valuesUnder(prefix string) (map[string]string, error) {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Recursive: true}
+	response, err := api.Get(etcdClient.ctx, prefix, options)
+
+	values := make(map[string]string)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return values, nil
+		}
+		return nil, err
+	}
+
+	for _, node := range flattenNodes(response.Node.Nodes) {
+		if !node.Dir {
+			values[strings.TrimPrefix(node.Key, response.Node.Key+"/")] = node.Value
+		}
+	}
+	return values, nil
+}
+
+func bindStruct(values map[string]string, directory string, target reflect.Value) error {
+	targetType := target.Type()
+
+	for i := 0; i < targetType.NumField(); i++ {
+		field := targetType.Field(i)
+		name := field.Tag.Get("etcd")
+		if name == "-" || field.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		key := path.Join(directory, name)
+		fieldValue := target.Field(i)
+
+		if fieldValue.Kind() == reflect.Struct {
+			if err := bindStruct(values, key, fieldValue); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if err := setField(fieldValue, value); err != nil {
+			return fmt.Errorf("Cannot bind %v to field %v: %v", key, field.Name, err)
+		}
+	}
+	return nil
+}
+
+// setField converts value to the type of the field and stores it
+func setField(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
+
+// bindDebounce is how long BindAndWatch waits for a burst
+// of changes to settle before rebinding
+const bindDebounce = 100 * time.Millisecond
+
+// BindAndWatch binds prefix to the struct out points to like Bind, then keeps
+// the struct up to date as keys under prefix change, calling onUpdate after
+// each update. Fields whose key is deleted go back to the value they had before
+// BindAndWatch was called. Updates are made from a background goroutine, so the
+// caller must synchronize access to the struct, for example by copying it in
+// onUpdate. Call stop to stop watching
+func (etcdClient *SimpleEtcdClient) BindAndWatch(prefix string, out interface{}, onUpdate func()) (func(), error) {
+	// changes made after the index and before Bind reads the
+	// prefix are applied again by the watch, none are missed
+	index, err := etcdClient.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	pointer := reflect.ValueOf(out)
+	if pointer.Kind() != reflect.Ptr || pointer.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("BindAndWatch requires a pointer to a struct, got %T", out)
+	}
+
+	// keep the values from before Bind for the keys that get deleted
+	target := pointer.Elem()
+	defaults := reflect.New(target.Type()).Elem()
+	defaults.Set(target)
+
+	if err := etcdClient.Bind(prefix, out); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(etcdClient.ctx)
+	watchClient := etcdClient.WithContext(ctx).(*SimpleEtcdClient)
+
+	etcdClient.goBackground(func() {
+		err := watchClient.WatchRecursiveDebouncedFrom(prefix, index, bindDebounce, func() {
+			values, err := watchClient.valuesUnder(prefix)
+			if err != nil {
+				etcdClient.options.log("rebinding failed", "prefix", prefix, "err", err)
+				return
+			}
+
+			updated := reflect.New(target.Type()).Elem()
+			updated.Set(defaults)
+			if err := bindStruct(values, "", updated); err != nil {
+				etcdClient.options.log("rebinding failed", "prefix", prefix, "err", err)
+				return
+			}
+
+			target.Set(updated)
+			onUpdate()
+		})
+		if ctx.Err() == nil {
+			etcdClient.options.log("bind watch stopped", "prefix", prefix, "err", err)
+		}
+	})
+
+	return cancel, nil
+}
@@ -0,0 +1,118 @@
+package etcdclient_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+type boundConfig struct {
+	Name    string        `etcd:"name"`
+	Workers int           `etcd:"workers"`
+	Timeout time.Duration `etcd:"timeout"`
+	Skipped string        `etcd:"-"`
+	Nested  struct {
+		Enabled bool `etcd:"enabled"`
+	} `etcd:"nested"`
+}
+
+func TestBind(t *testing.T) {
+	etcdClient := dial(t)
+	for key, value := range map[string]string{
+		"/config/name":           "service",
+		"/config/workers":        "4",
+		"/config/timeout":        "2s",
+		"/config/nested/enabled": "true",
+	} {
+		if err := etcdClient.Set(key, value); err != nil {
+			t.Fatalf("Set(%v) returned %v", key, err)
+		}
+	}
+
+	config := boundConfig{Skipped: "kept"}
+	if err := etcdClient.Bind("/config", &config); err != nil {
+		t.Fatalf("Bind returned %v", err)
+	}
+	if config.Name != "service" || config.Workers != 4 || config.Timeout != 2*time.Second || !config.Nested.Enabled || config.Skipped != "kept" {
+		t.Errorf("Bind set %+v", config)
+	}
+
+	if err := etcdClient.Bind("/config", config); err == nil {
+		t.Error("Bind of a struct instead of a pointer returned no error")
+	}
+}
+
+func TestBindConvertsEveryType(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/typed/Untagged")
+	if err := etcdClient.Set("/typed/small", "200"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Set("/typed/ratio", "0.5"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	var config struct {
+		Untagged string
+		Small    uint8   `etcd:"small"`
+		Ratio    float64 `etcd:"ratio"`
+		Missing  string  `etcd:"missing"`
+	}
+	config.Missing = "default"
+	if err := etcdClient.Bind("/typed", &config); err != nil {
+		t.Fatalf("Bind returned %v", err)
+	}
+	if config.Untagged != "value" || config.Small != 200 || config.Ratio != 0.5 || config.Missing != "default" {
+		t.Errorf("Bind set %+v", config)
+	}
+}
+
+func TestBindRejectsValuesOfTheWrongType(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/invalid/workers", "many"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	var config boundConfig
+	if err := etcdClient.Bind("/invalid", &config); err == nil {
+		t.Error("Bind of a value that is not an integer returned no error")
+	}
+}
+
+func TestBindAndWatchRestoresDefaults(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/watched/workers", "8"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	var mutex sync.Mutex
+	updated := make(chan boundConfig, 10)
+	config := boundConfig{Workers: 1}
+	stop, err := etcdClient.BindAndWatch("/watched", &config, func() {
+		mutex.Lock()
+		defer mutex.Unlock()
+		updated <- config
+	})
+	if err != nil {
+		t.Fatalf("BindAndWatch returned %v", err)
+	}
+	defer stop()
+
+	mutex.Lock()
+	if config.Workers != 8 {
+		t.Errorf("BindAndWatch bound %v workers, expected 8", config.Workers)
+	}
+	mutex.Unlock()
+
+	if err := etcdClient.Del("/watched/workers"); err != nil {
+		t.Fatalf("Del returned %v", err)
+	}
+	select {
+	case config := <-updated:
+		if config.Workers != 1 {
+			t.Errorf("Deleting the key set %v workers, expected the default of 1", config.Workers)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("BindAndWatch did not update the struct")
+	}
+}
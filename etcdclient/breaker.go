@@ -0,0 +1,92 @@
+package etcdclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// ErrCircuitOpen is returned instead of making a request while
+// the circuit breaker is open, see WithCircuitBreaker
+var ErrCircuitOpen = errors.New("etcd circuit breaker is open")
+
+// WithCircuitBreaker makes the client fail fast with ErrCircuitOpen after
+// threshold consecutive requests fail to reach etcd. Once cooldown has passed,
+// a single request is let through: if it succeeds, requests flow again,
+// otherwise the breaker stays open for another cooldown. Errors returned by
+// etcd itself, such as a missing key, mean etcd is reachable and do not count.
+// threshold must be at least 1
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(opts *options) {
+		if threshold < 1 {
+			opts.err = fmt.Errorf("WithCircuitBreaker requires a threshold of at least 1, got %v", threshold)
+			return
+		}
+		opts.breaker = &circuitBreaker{threshold: threshold, cooldown: cooldown}
+	}
+}
+
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// allow returns true if a request may be made, and whether it is
+// the single trial request let through once the cooldown has passed
+func (breaker *circuitBreaker) allow() (allowed, trial bool) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if breaker.failures < breaker.threshold {
+		return true, false
+	}
+	if breaker.trial || time.Since(breaker.openedAt) < breaker.cooldown {
+		return false, false
+	}
+
+	breaker.trial = true
+	return true, true
+}
+
+// record updates the breaker with the result of a request, trial
+// is the value allow returned for it. Requests that were already in
+// flight when the breaker opened do not end the trial
+func (breaker *circuitBreaker) record(err error, trial bool) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if trial {
+		breaker.trial = false
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return
+	}
+
+	if !isUnreachable(err) {
+		breaker.failures = 0
+		return
+	}
+
+	breaker.failures++
+	if breaker.failures >= breaker.threshold {
+		breaker.openedAt = time.Now()
+	}
+}
+
+// isUnreachable returns true if the error means etcd could not be reached
+func isUnreachable(err error) bool {
+	if err == nil {
+		return false
+	}
+	_, ok := err.(client.Error)
+	return !ok
+}
@@ -0,0 +1,79 @@
+package etcdclient
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+var errUnreachable = errors.New("connection refused")
+
+func TestCircuitBreakerOpensAfterThreshold(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 2, cooldown: time.Hour}
+
+	for i := 0; i < 2; i++ {
+		allowed, _ := breaker.allow()
+		if !allowed {
+			t.Fatalf("Request %v was not allowed before the threshold", i)
+		}
+		breaker.record(errUnreachable, false)
+	}
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("Request was allowed after threshold failures")
+	}
+}
+
+func TestCircuitBreakerIgnoresEtcdErrors(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Hour}
+
+	breaker.record(client.Error{Code: client.ErrorCodeKeyNotFound}, false)
+	if allowed, _ := breaker.allow(); !allowed {
+		t.Error("An error returned by etcd opened the breaker")
+	}
+}
+
+func TestCircuitBreakerLetsOneTrialThrough(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: time.Millisecond}
+	breaker.record(errUnreachable, false)
+	time.Sleep(5 * time.Millisecond)
+
+	allowed, trial := breaker.allow()
+	if !allowed || !trial {
+		t.Fatalf("allow after the cooldown returned %v, %v, expected a trial", allowed, trial)
+	}
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("A second request was allowed during the trial")
+	}
+
+	// a request sent before the breaker opened must not end the trial
+	breaker.record(errUnreachable, false)
+	time.Sleep(5 * time.Millisecond)
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("A request was allowed after an earlier request ended the trial")
+	}
+
+	breaker.record(nil, true)
+	if allowed, trial := breaker.allow(); !allowed || trial {
+		t.Errorf("allow after a successful trial returned %v, %v, expected the breaker to be closed", allowed, trial)
+	}
+}
+
+func TestCircuitBreakerReopensAfterAFailedTrial(t *testing.T) {
+	breaker := &circuitBreaker{threshold: 1, cooldown: 50 * time.Millisecond}
+	breaker.record(errUnreachable, false)
+	time.Sleep(60 * time.Millisecond)
+
+	_, trial := breaker.allow()
+	breaker.record(errUnreachable, trial)
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("A request was allowed right after the trial failed")
+	}
+}
+
+func TestWithCircuitBreakerRequiresAThreshold(t *testing.T) {
+	if _, err := Dial("http://127.0.0.1:2379", WithCircuitBreaker(0, time.Second)); err == nil {
+		t.Error("Dial with WithCircuitBreaker(0) returned no error")
+	}
+}
@@ -0,0 +1,19 @@
+package etcdclient
+
+import "encoding/base64"
+
+// GetBytes gets a binary value stored with SetBytes.
+// A missing key returns a nil value
+func (etcdClient *SimpleEtcdClient) GetBytes(key string) ([]byte, error) {
+	value, err := etcdClient.Get(key)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
+
+// SetBytes sets a binary value, base64 encoded since
+// the etcd v2 store only holds strings
+func (etcdClient *SimpleEtcdClient) SetBytes(key string, value []byte) error {
+	return etcdClient.Set(key, base64.StdEncoding.EncodeToString(value))
+}
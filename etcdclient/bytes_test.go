@@ -0,0 +1,42 @@
+package etcdclient_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+)
+
+func TestSetBytesAndGetBytes(t *testing.T) {
+	etcdClient := dial(t)
+	value := make([]byte, 256)
+	for i := range value {
+		value[i] = byte(i)
+	}
+
+	if err := etcdClient.SetBytes("/bytes/key", value); err != nil {
+		t.Fatalf("SetBytes returned %v", err)
+	}
+	got, err := etcdClient.GetBytes("/bytes/key")
+	if err != nil || !bytes.Equal(got, value) {
+		t.Errorf("GetBytes returned %v, %v, expected the bytes that were set", got, err)
+	}
+	if stored, _ := etcdClient.Get("/bytes/key"); stored != base64.StdEncoding.EncodeToString(value) {
+		t.Errorf("SetBytes stored %q, expected it base64 encoded", stored)
+	}
+
+	got, err = etcdClient.GetBytes("/bytes/missing")
+	if err != nil || got != nil {
+		t.Errorf("GetBytes of a missing key returned %v, %v, expected nil", got, err)
+	}
+}
+
+func TestGetBytesOfAValueThatIsNotBase64(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/bytes/key", "not base64!"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if _, err := etcdClient.GetBytes("/bytes/key"); err == nil {
+		t.Error("GetBytes of a value that is not base64 returned no error")
+	}
+}
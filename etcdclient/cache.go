@@ -0,0 +1,248 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// CacheRetry is how a cached client waits before reading its prefix
+// again after its watch failed. It retries until it is closed
+var CacheRetry = RetryPolicy{MaxRetries: -1, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// cachedClient serves reads under a prefix from an in-memory
+// snapshot, everything else goes to the inner client
+type cachedClient struct {
+	EtcdClient
+	prefix string
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mutex sync.RWMutex
+	fresh bool
+	nodes map[string]cachedNode
+}
+
+type cachedNode struct {
+	value string
+	dir   bool
+}
+
+// NewCachedClient returns a client that serves Get, Ls and LsRecursive for keys
+// under prefix from an in-memory snapshot, kept fresh by watching the prefix.
+// Keys missing from the snapshot, keys outside the prefix and every write go to
+// inner. Writes made through the client show up in the snapshot once the watch
+// sees them, so a Get right after a Set may return the old value. If the watch
+// fails, every read goes to inner until the prefix is read again and the watch
+// restarted, which is retried with CacheRetry. Close stops the watch and
+// closes inner
+func NewCachedClient(inner EtcdClient, prefix string) (EtcdClient, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cached := &cachedClient{
+		EtcdClient: inner,
+		prefix:     normalizeKey(prefix),
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	index, err := cached.load(inner)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		defer close(cached.done)
+		cached.watch(ctx, inner.WithContext(ctx), index)
+	}()
+	return cached, nil
+}
+
+// load reads the prefix into the snapshot and returns the index to
+// watch it from
+func (cached *cachedClient) load(inner EtcdClient) (uint64, error) {
+	index, err := inner.Index()
+	if err != nil {
+		return 0, err
+	}
+
+	data, err := inner.Export(cached.prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return 0, err
+	}
+
+	nodes := make(map[string]cachedNode)
+	for _, node := range export.Nodes {
+		nodes[path.Join(cached.prefix, node.Key)] = cachedNode{value: node.Value, dir: node.Dir}
+	}
+
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+	cached.nodes = nodes
+	cached.fresh = true
+	return index, nil
+}
+
+// watch applies the changes after the index to the snapshot. When the
+// watch fails, reads go to etcd until the prefix is read again, then the
+// watch restarts, until ctx is done
+func (cached *cachedClient) watch(ctx context.Context, inner EtcdClient, index uint64) {
+	for {
+		inner.WatchEvents(cached.prefix, index, cached.apply)
+
+		cached.mutex.Lock()
+		cached.fresh = false
+		cached.mutex.Unlock()
+
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(CacheRetry.backoff(attempt)):
+			}
+
+			var err error
+			if index, err = cached.load(inner); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// Get gets a value from the snapshot, or from etcd on a miss
+func (cached *cachedClient) Get(key string) (string, error) {
+	cached.mutex.RLock()
+	node, ok := cached.lookup(key)
+	cached.mutex.RUnlock()
+
+	if !ok || node.dir {
+		return cached.EtcdClient.Get(key)
+	}
+	return node.value, nil
+}
+
+// Ls returns the keys in the directory from the snapshot, or from etcd on a miss
+func (cached *cachedClient) Ls(directory string) ([]string, error) {
+	cached.mutex.RLock()
+	defer cached.mutex.RUnlock()
+
+	if node, ok := cached.lookup(directory); !ok || !node.dir {
+		return cached.EtcdClient.Ls(directory)
+	}
+	return cached.children(directory, false), nil
+}
+
+// LsRecursive returns the keys in the directory, recursively, from the
+// snapshot, or from etcd on a miss
+func (cached *cachedClient) LsRecursive(directory string) ([]string, error) {
+	cached.mutex.RLock()
+	defer cached.mutex.RUnlock()
+
+	if node, ok := cached.lookup(directory); !ok || !node.dir {
+		return cached.EtcdClient.LsRecursive(directory)
+	}
+	return cached.children(directory, true), nil
+}
+
+// Close stops watching the prefix and closes the inner client
+func (cached *cachedClient) Close() error {
+	cached.cancel()
+	return cached.EtcdClient.Close()
+}
+
+// Shutdown stops watching the prefix, shuts down the inner client,
+// or closes it if it cannot be shut down, and waits for both until
+// ctx is done
+func (cached *cachedClient) Shutdown(ctx context.Context) error {
+	cached.cancel()
+	if err := shutdown(ctx, cached.EtcdClient); err != nil {
+		return err
+	}
+
+	select {
+	case <-cached.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// lookup returns the node at key if the snapshot has it.
+// The caller must hold the read lock
+func (cached *cachedClient) lookup(key string) (cachedNode, bool) {
+	key = normalizeKey(key)
+	if !cached.fresh {
+		return cachedNode{}, false
+	}
+
+	if key == cached.prefix {
+		return cachedNode{dir: true}, true
+	}
+	if !isAncestor(cached.prefix, key) {
+		return cachedNode{}, false
+	}
+
+	node, ok := cached.nodes[key]
+	return node, ok
+}
+
+// children returns the keys in the directory, sorted the way
+// etcd sorts them. The caller must hold the read lock
+func (cached *cachedClient) children(directory string, recursive bool) []string {
+	directory = normalizeKey(directory)
+	keys := make([]string, 0)
+
+	for key := range cached.nodes {
+		if !isAncestor(directory, key) {
+			continue
+		}
+		if recursive || path.Dir(key) == directory {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+	return keys
+}
+
+// apply updates the snapshot with a change from the watch
+func (cached *cachedClient) apply(event Event) {
+	cached.mutex.Lock()
+	defer cached.mutex.Unlock()
+
+	key := normalizeKey(event.Key)
+	if !event.Removed() {
+		cached.nodes[key] = cachedNode{value: event.Value, dir: event.Dir}
+
+		// etcd creates missing parent directories without an event
+		for parent := path.Dir(key); isAncestor(cached.prefix, parent); parent = path.Dir(parent) {
+			cached.nodes[parent] = cachedNode{dir: true}
+		}
+		return
+	}
+
+	delete(cached.nodes, key)
+	for child := range cached.nodes {
+		if isAncestor(key, child) {
+			delete(cached.nodes, child)
+		}
+	}
+}
+
+// normalizeKey returns the key the way etcd names it,
+// with a leading slash and no trailing slash
+func normalizeKey(key string) string {
+	return path.Clean("/" + strings.Trim(key, "/"))
+}
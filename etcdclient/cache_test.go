@@ -0,0 +1,107 @@
+package etcdclient_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// countingClient counts the Gets that reach the inner client
+type countingClient struct {
+	etcdclient.EtcdClient
+	gets int64
+}
+
+func (counting *countingClient) Get(key string) (string, error) {
+	atomic.AddInt64(&counting.gets, 1)
+	return counting.EtcdClient.Get(key)
+}
+
+// cachedGet returns the value and true if the cache served it
+func cachedGet(t *testing.T, cached etcdclient.EtcdClient, counting *countingClient, key string) (string, bool) {
+	before := atomic.LoadInt64(&counting.gets)
+	value, err := cached.Get(key)
+	if err != nil {
+		t.Fatalf("Get returned %v", err)
+	}
+	return value, atomic.LoadInt64(&counting.gets) == before
+}
+
+func TestCachedClientServesFromTheSnapshot(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/cached/a", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	counting := &countingClient{EtcdClient: etcdClient}
+	cached, err := etcdclient.NewCachedClient(counting, "/cached")
+	if err != nil {
+		t.Fatalf("NewCachedClient returned %v", err)
+	}
+	defer cached.Close()
+
+	if value, hit := cachedGet(t, cached, counting, "/cached/a"); value != "1" || !hit {
+		t.Errorf("Get returned %q, from the cache: %v, expected \"1\" from the cache", value, hit)
+	}
+	if _, hit := cachedGet(t, cached, counting, "/elsewhere"); hit {
+		t.Error("Get outside the prefix was served from the cache")
+	}
+
+	if err := etcdClient.Set("/cached/a", "2"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	waitForCached(t, cached, counting, "/cached/a", "2")
+}
+
+func TestCachedClientResyncsAfterTheWatchFails(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+
+	etcdClient, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	if err := etcdClient.Set("/cached/a", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	counting := &countingClient{EtcdClient: etcdClient}
+	cached, err := etcdclient.NewCachedClient(counting, "/cached")
+	if err != nil {
+		t.Fatalf("NewCachedClient returned %v", err)
+	}
+	defer cached.Close()
+
+	// the watch reconnects after the events it waits from are cleared
+	for i := 0; i <= etcdtest.MemoryHistory; i++ {
+		if err := etcdClient.Set("/elsewhere", "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+	server.DropConnections()
+	// the first request may fail on a dropped connection
+	if err := etcdClient.Set("/cached/a", "2"); err != nil {
+		if err := etcdClient.Set("/cached/a", "2"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+	waitForCached(t, cached, counting, "/cached/a", "2")
+}
+
+// waitForCached waits until the cache serves the value of the key
+func waitForCached(t *testing.T, cached etcdclient.EtcdClient, counting *countingClient, key, expected string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		value, hit := cachedGet(t, cached, counting, key)
+		if value == expected && hit {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Get returned %q, from the cache: %v, expected %q from the cache", value, hit, expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
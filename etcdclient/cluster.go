@@ -0,0 +1,156 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Member describes a member of the etcd cluster
+type Member struct {
+	ID         string
+	Name       string
+	PeerURLs   []string
+	ClientURLs []string
+}
+
+// MemberHealth describes the health of a single cluster member
+type MemberHealth struct {
+	Member  Member
+	Healthy bool
+
+	// Err is the reason the member is unhealthy, nil if it is healthy
+	Err error
+}
+
+// Health describes the health of the etcd cluster
+type Health struct {
+	// Healthy is true if a quorum of members is healthy
+	Healthy bool
+	Members []MemberHealth
+}
+
+// Members returns the members of the etcd cluster
+func (etcdClient *SimpleEtcdClient) Members() ([]Member, error) {
+	api := client.NewMembersAPI(etcdClient.etcd)
+	ctx, cancel := etcdClient.withContext(etcdClient.ctx)
+	defer cancel()
+
+	members, err := api.List(ctx)
+	if err != nil {
+		return nil, wrapError("members", "", err)
+	}
+
+	result := make([]Member, len(members))
+	for i, member := range members {
+		result[i] = newMember(member)
+	}
+	return result, nil
+}
+
+// Leader returns the current leader of the etcd cluster
+func (etcdClient *SimpleEtcdClient) Leader() (Member, error) {
+	api := client.NewMembersAPI(etcdClient.etcd)
+	ctx, cancel := etcdClient.withContext(etcdClient.ctx)
+	defer cancel()
+
+	leader, err := api.Leader(ctx)
+	if err != nil {
+		return Member{}, wrapError("leader", "", err)
+	}
+	return newMember(*leader), nil
+}
+
+// ClusterHealth checks the health endpoint of every member
+// of the etcd cluster
+func (etcdClient *SimpleEtcdClient) ClusterHealth() (Health, error) {
+	members, err := etcdClient.Members()
+	if err != nil {
+		return Health{}, err
+	}
+
+	health := Health{Members: make([]MemberHealth, len(members))}
+	healthy := 0
+	for i, member := range members {
+		err := etcdClient.checkMemberHealth(member)
+		health.Members[i] = MemberHealth{Member: member, Healthy: err == nil, Err: err}
+		if err == nil {
+			healthy++
+		}
+	}
+
+	health.Healthy = healthy > len(members)/2
+	return health, nil
+}
+
+// Endpoints returns the endpoints the client is currently using
+func (etcdClient *SimpleEtcdClient) Endpoints() []string {
+	return etcdClient.etcd.Endpoints()
+}
+
+// autoSync keeps the endpoints in sync with the cluster membership
+// until the client is closed. Failed syncs are retried after interval
+func (etcdClient *SimpleEtcdClient) autoSync(interval time.Duration) {
+	for {
+		err := etcdClient.etcd.AutoSync(etcdClient.root, interval)
+		if etcdClient.root.Err() != nil {
+			return
+		}
+
+		etcdClient.options.log("retrying endpoint sync", "interval", interval, "err", err)
+		if etcdClient.sleep(interval) != nil {
+			return
+		}
+	}
+}
+
+// checkMemberHealth returns nil if any of the member's
+// client urls report it as healthy
+func (etcdClient *SimpleEtcdClient) checkMemberHealth(member Member) error {
+	httpClient := &http.Client{
+		Transport: etcdClient.options.etcd.Transport,
+		Timeout:   client.DefaultRequestTimeout,
+	}
+
+	err := fmt.Errorf("Member has no client urls: %v", member.Name)
+	for _, clientURL := range member.ClientURLs {
+		err = checkHealthEndpoint(httpClient, strings.TrimSuffix(clientURL, "/")+"/health")
+		if err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func checkHealthEndpoint(httpClient *http.Client, healthURL string) error {
+	response, err := httpClient.Get(healthURL)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	var body struct {
+		Health string `json:"health"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return err
+	}
+
+	if body.Health != "true" {
+		return fmt.Errorf("Member reported unhealthy: %v", healthURL)
+	}
+	return nil
+}
+
+func newMember(member client.Member) Member {
+	return Member{
+		ID:         member.ID,
+		Name:       member.Name,
+		PeerURLs:   member.PeerURLs,
+		ClientURLs: member.ClientURLs,
+	}
+}
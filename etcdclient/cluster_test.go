@@ -0,0 +1,80 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestMembersAndLeader(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	etcdClient, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	members, err := etcdClient.Members()
+	if err != nil || len(members) != 1 || members[0].Name != "memory" {
+		t.Fatalf("Members returned %v, %v, expected the memory member", members, err)
+	}
+	if urls := members[0].ClientURLs; len(urls) != 1 || urls[0] != server.URI {
+		t.Errorf("The member has the client urls %v, expected %v", urls, server.URI)
+	}
+
+	leader, err := etcdClient.Leader()
+	if err != nil || leader.ID != "memory" {
+		t.Errorf("Leader returned %v, %v, expected the memory member", leader, err)
+	}
+}
+
+func TestClusterHealth(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	etcdClient, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	health, err := etcdClient.ClusterHealth()
+	if err != nil {
+		t.Fatalf("ClusterHealth returned %v", err)
+	}
+	if !health.Healthy || len(health.Members) != 1 || !health.Members[0].Healthy {
+		t.Errorf("ClusterHealth returned %+v, expected one healthy member", health)
+	}
+
+	server.Stop()
+	if _, err := etcdClient.ClusterHealth(); err == nil {
+		t.Error("ClusterHealth of a stopped server returned no error")
+	}
+}
+
+func TestWithAutoSyncUsesTheMemberURLs(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+
+	// the member advertises 127.0.0.1, the client starts with localhost
+	etcdClient, err := etcdclient.Dial(strings.Replace(server.URI, "127.0.0.1", "localhost", 1), etcdclient.WithAutoSync(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		endpoints := etcdClient.Endpoints()
+		if len(endpoints) == 1 && endpoints[0] == server.URI {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Endpoints returned %v, expected %v", endpoints, server.URI)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,70 @@
+package etcdclient
+
+import "github.com/coreos/etcd/client"
+
+// valueCodec transforms values on their way to and from etcd
+type valueCodec interface {
+	encode(value string) (string, error)
+	decode(value string) (string, error)
+}
+
+// encodeValue runs the value through every codec, in the
+// order they were configured. Empty values are left alone so
+// that they can still be used to refresh TTLs
+func (opts *options) encodeValue(value string) (string, error) {
+	if value == "" {
+		return value, nil
+	}
+
+	for _, codec := range opts.valueCodecs {
+		var err error
+		if value, err = codec.encode(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// decodeValue undoes encodeValue
+func (opts *options) decodeValue(value string) (string, error) {
+	for i := len(opts.valueCodecs) - 1; i >= 0; i-- {
+		var err error
+		if value, err = opts.valueCodecs[i].decode(value); err != nil {
+			return "", err
+		}
+	}
+	return value, nil
+}
+
+// decodeResponse decodes the values of every node in the response
+func (opts *options) decodeResponse(response *client.Response) error {
+	if response == nil || len(opts.valueCodecs) == 0 {
+		return nil
+	}
+
+	if err := opts.decodeNode(response.Node); err != nil {
+		return err
+	}
+	return opts.decodeNode(response.PrevNode)
+}
+
+func (opts *options) decodeNode(node *client.Node) error {
+	if node == nil {
+		return nil
+	}
+
+	if !node.Dir && node.Value != "" {
+		value, err := opts.decodeValue(node.Value)
+		if err != nil {
+			return err
+		}
+		node.Value = value
+	}
+
+	for _, child := range node.Nodes {
+		if err := opts.decodeNode(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
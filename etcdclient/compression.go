@@ -0,0 +1,66 @@
+package etcdclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"strings"
+)
+
+// compressedPrefix marks values that were compressed by WithCompression
+const compressedPrefix = "gzip:"
+
+// WithCompression gzips values longer than threshold bytes before they are
+// written, and transparently decompresses them when they are read. Compressed
+// values are stored base64 encoded with a "gzip:" prefix, so uncompressed
+// values must not start with "gzip:". Values are always compressed before
+// being encrypted by WithEncryption, whatever order the options are given in
+func WithCompression(threshold int) Option {
+	return func(opts *options) {
+		opts.valueCodecs = append([]valueCodec{&compressionCodec{threshold}}, opts.valueCodecs...)
+	}
+}
+
+type compressionCodec struct {
+	threshold int
+}
+
+func (codec *compressionCodec) encode(value string) (string, error) {
+	if len(value) <= codec.threshold {
+		return value, nil
+	}
+
+	var buffer bytes.Buffer
+	writer := gzip.NewWriter(&buffer)
+	if _, err := writer.Write([]byte(value)); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+	return compressedPrefix + base64.StdEncoding.EncodeToString(buffer.Bytes()), nil
+}
+
+func (codec *compressionCodec) decode(value string) (string, error) {
+	if !strings.HasPrefix(value, compressedPrefix) {
+		return value, nil
+	}
+
+	compressed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, compressedPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	decompressed, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(decompressed), nil
+}
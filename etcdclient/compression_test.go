@@ -0,0 +1,74 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// dialWithPlain returns a client made with opts and a client without
+// them of the same etcdtest.MemoryServer, to see what the first stores
+func dialWithPlain(t *testing.T, opts ...etcdclient.Option) (etcdclient.EtcdClient, etcdclient.EtcdClient) {
+	server := etcdtest.StartMemory()
+	t.Cleanup(func() { server.Stop() })
+
+	coded, err := server.Client(opts...)
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	t.Cleanup(func() { coded.Close() })
+	plain, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	t.Cleanup(func() { plain.Close() })
+	return coded, plain
+}
+
+func TestWithCompression(t *testing.T) {
+	compressed, plain := dialWithPlain(t, etcdclient.WithCompression(100))
+	large := strings.Repeat(`{"key": "value"}`, 100)
+
+	if err := compressed.Set("/compressed/large", large); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := compressed.Set("/compressed/small", "small"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if value, err := compressed.Get("/compressed/large"); err != nil || value != large {
+		t.Errorf("Get of a compressed value returned %v, %v, expected the value that was set", len(value), err)
+	}
+	stored, _ := plain.Get("/compressed/large")
+	if !strings.HasPrefix(stored, "gzip:") || len(stored) >= len(large) {
+		t.Errorf("A large value was stored as %q, expected it compressed", stored)
+	}
+	if stored, _ := plain.Get("/compressed/small"); stored != "small" {
+		t.Errorf("A small value was stored as %q, expected it as is", stored)
+	}
+}
+
+func TestWithCompressionAndEncryptionInEitherOrder(t *testing.T) {
+	key := make([]byte, 32)
+	orders := [][]etcdclient.Option{
+		{etcdclient.WithCompression(10), etcdclient.WithEncryption(key)},
+		{etcdclient.WithEncryption(key), etcdclient.WithCompression(10)},
+	}
+	large := strings.Repeat("a", 1000)
+
+	for _, opts := range orders {
+		coded, plain := dialWithPlain(t, opts...)
+		if err := coded.Set("/coded/key", large); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+		if value, err := coded.Get("/coded/key"); err != nil || value != large {
+			t.Errorf("Get returned %v bytes, %v, expected the value that was set", len(value), err)
+		}
+		// compressing first keeps the value small, encrypted data does not compress
+		if stored, _ := plain.Get("/coded/key"); !strings.HasPrefix(stored, "aesgcm:") || len(stored) >= len(large) {
+			t.Errorf("The value was stored as %v bytes starting with %.10q, expected it compressed then encrypted", len(stored), stored)
+		}
+	}
+}
@@ -0,0 +1,325 @@
+package etcdclient_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// These tests share one client between goroutines, run them with
+// go test -race so they catch unsynchronized state
+
+const (
+	concurrentWriters = 8
+	concurrentWrites  = 25
+)
+
+// writeConcurrently sets and reads keys under directory from
+// concurrentWriters goroutines and returns once they are done
+func writeConcurrently(t *testing.T, etcdClient etcdclient.EtcdClient, directory string) {
+	var wait sync.WaitGroup
+	for writer := 0; writer < concurrentWriters; writer++ {
+		wait.Add(1)
+		go func(writer int) {
+			defer wait.Done()
+			for write := 0; write < concurrentWrites; write++ {
+				key := fmt.Sprintf("%v/%v/%v", directory, writer, write)
+				value := fmt.Sprintf("value-%v", write)
+				if err := etcdClient.Set(key, value); err != nil {
+					t.Errorf("Set(%v) returned %v", key, err)
+					return
+				}
+				got, err := etcdClient.Get(key)
+				if err != nil || got != value {
+					t.Errorf("Get(%v) returned %q, %v, expected %q", key, got, err, value)
+					return
+				}
+			}
+		}(writer)
+	}
+	wait.Wait()
+}
+
+// waitForCount waits until count reaches expected
+func waitForCount(t *testing.T, count *int64, expected int64) {
+	deadline := time.Now().Add(10 * time.Second)
+	for atomic.LoadInt64(count) < expected {
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %v events, expected %v", atomic.LoadInt64(count), expected)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestConcurrentSetGetWatch(t *testing.T) {
+	etcdClient := dial(t)
+
+	var events int64
+	watched := make(chan error, 1)
+	go func() {
+		watched <- etcdClient.WatchRecursive("/race", func(key, newValue string) {
+			atomic.AddInt64(&events, 1)
+		})
+	}()
+	// the watch starts after the current index, wait for it to connect
+	time.Sleep(100 * time.Millisecond)
+
+	writeConcurrently(t, etcdClient, "/race")
+	waitForCount(t, &events, concurrentWriters*concurrentWrites)
+
+	etcdClient.Close()
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchRecursive did not return after Close")
+	}
+}
+
+func TestConcurrentWatchWorkers(t *testing.T) {
+	etcdClient := dial(t, etcdclient.WithWatchWorkers(4))
+
+	// a watch after index 0 starts from now, so the index must not be 0
+	if err := etcdClient.Set("/seed", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+
+	// the callback is called from several goroutines, see WithWatchWorkers
+	var mutex sync.Mutex
+	values := make(map[string]string)
+	var events int64
+	var watch sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		watch.Add(1)
+		go func() {
+			defer watch.Done()
+			etcdClient.WatchEvents("/workers", index, func(event etcdclient.Event) {
+				mutex.Lock()
+				values[event.Key] = event.Value
+				mutex.Unlock()
+				atomic.AddInt64(&events, 1)
+			})
+		}()
+	}
+
+	writeConcurrently(t, etcdClient, "/workers")
+	waitForCount(t, &events, 3*concurrentWriters*concurrentWrites)
+
+	mutex.Lock()
+	if len(values) != concurrentWriters*concurrentWrites {
+		t.Errorf("Got %v keys, expected %v", len(values), concurrentWriters*concurrentWrites)
+	}
+	mutex.Unlock()
+
+	if err := etcdClient.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	watch.Wait()
+}
+
+func TestConcurrentOptions(t *testing.T) {
+	recording := &etcdclient.Recording{}
+	etcdClient := dial(t,
+		etcdclient.WithSingleflight(),
+		etcdclient.WithRateLimit(10000, 1000),
+		etcdclient.WithCircuitBreaker(1000, time.Second),
+		etcdclient.WithRequestScheduler(4),
+		etcdclient.WithCompression(16),
+		etcdclient.WithRecorder(recording),
+		etcdclient.WithWatchBuffer(4, etcdclient.BufferCoalesce),
+	)
+
+	var watch sync.WaitGroup
+	watch.Add(1)
+	go func() {
+		defer watch.Done()
+		etcdClient.WatchRecursive("/options", func(key, newValue string) {})
+	}()
+
+	writeConcurrently(t, etcdClient, "/options")
+	if err := etcdClient.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	watch.Wait()
+}
+
+func TestConcurrentCachedClient(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/cached/seed", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	cached, err := etcdclient.NewCachedClient(etcdClient, "/cached")
+	if err != nil {
+		t.Fatalf("NewCachedClient returned %v", err)
+	}
+
+	done := make(chan struct{})
+	var read sync.WaitGroup
+	for i := 0; i < concurrentWriters; i++ {
+		read.Add(1)
+		go func() {
+			defer read.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				cached.Get("/cached/seed")
+				cached.LsRecursive("/cached")
+				time.Sleep(time.Millisecond)
+			}
+		}()
+	}
+
+	writeConcurrently(t, cached, "/cached")
+	close(done)
+	read.Wait()
+
+	if err := cached.(etcdclient.Shutdowner).Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+}
+
+func TestConcurrentWithContext(t *testing.T) {
+	etcdClient := dial(t)
+
+	var wait sync.WaitGroup
+	for i := 0; i < concurrentWriters; i++ {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			writeConcurrently(t, etcdClient.WithContext(ctx), fmt.Sprintf("/contexts/%v", i))
+		}(i)
+	}
+	wait.Wait()
+}
+
+func TestConcurrentNotifier(t *testing.T) {
+	etcdClient := dial(t)
+	notifier := etcdClient.Notifier("/notified")
+
+	var events int64
+	var subscribe sync.WaitGroup
+	subscriptions := make(chan etcdclient.Subscription, concurrentWriters)
+	for i := 0; i < concurrentWriters; i++ {
+		subscribe.Add(1)
+		go func() {
+			defer subscribe.Done()
+			subscriptions <- notifier.Subscribe(func(event etcdclient.Event) {
+				atomic.AddInt64(&events, 1)
+			})
+		}()
+	}
+	subscribe.Wait()
+	close(subscriptions)
+	time.Sleep(100 * time.Millisecond)
+
+	if err := etcdClient.Set("/notified/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	waitForCount(t, &events, concurrentWriters)
+
+	var unsubscribe sync.WaitGroup
+	for subscription := range subscriptions {
+		unsubscribe.Add(1)
+		go func(subscription etcdclient.Subscription) {
+			defer unsubscribe.Done()
+			notifier.Unsubscribe(subscription)
+		}(subscription)
+	}
+	unsubscribe.Wait()
+
+	if err := etcdClient.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+}
+
+func TestConcurrentSemaphore(t *testing.T) {
+	etcdClient := dial(t)
+
+	var holders, most int64
+	var wait sync.WaitGroup
+	for i := 0; i < concurrentWriters; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			semaphore := etcdClient.Semaphore("/semaphore", 2)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			if err := semaphore.Acquire(ctx); err != nil {
+				t.Errorf("Acquire returned %v", err)
+				return
+			}
+
+			current := atomic.AddInt64(&holders, 1)
+			for {
+				seen := atomic.LoadInt64(&most)
+				if current <= seen || atomic.CompareAndSwapInt64(&most, seen, current) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			atomic.AddInt64(&holders, -1)
+
+			if err := semaphore.Release(); err != nil {
+				t.Errorf("Release returned %v", err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	if most > 2 {
+		t.Errorf("Semaphore let %v holders in at once, expected at most 2", most)
+	}
+	if err := etcdClient.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+}
+
+func TestConcurrentClose(t *testing.T) {
+	etcdClient := dial(t)
+
+	var wait sync.WaitGroup
+	for i := 0; i < concurrentWriters; i++ {
+		wait.Add(2)
+		go func(i int) {
+			defer wait.Done()
+			for write := 0; ; write++ {
+				if etcdClient.Set(fmt.Sprintf("/closing/%v/%v", i, write), "value") != nil {
+					return
+				}
+			}
+		}(i)
+		go func() {
+			defer wait.Done()
+			etcdClient.WatchRecursive("/closing", func(key, newValue string) {})
+		}()
+	}
+	etcdClient.Go(func(ctx context.Context) {
+		<-ctx.Done()
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := etcdClient.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	wait.Wait()
+
+	if err := etcdClient.Set("/closing/after", "value"); err == nil {
+		t.Error("Set after Close returned no error")
+	}
+}
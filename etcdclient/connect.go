@@ -0,0 +1,58 @@
+package etcdclient
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// verifyRetry is how DialAndVerify backs off between health checks
+var verifyRetry = RetryPolicy{MaxRetries: -1, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 5 * time.Second}
+
+// DialAndVerify constructs a new EtcdClient like Dial, then checks that etcd
+// can be reached with Ping, retrying with backoff until it succeeds or ctx is
+// done. If ctx is done first, the client is closed and the last error is
+// returned
+func DialAndVerify(ctx context.Context, etcdURI string, opts ...Option) (EtcdClient, error) {
+	etcd, err := Dial(etcdURI, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := etcd.WithContext(ctx).Ping()
+		if err == nil {
+			return etcd, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			etcd.Close()
+			return nil, fmt.Errorf("Failed to reach etcd at %v: %v", etcdURI, err)
+		case <-time.After(verifyRetry.backoff(attempt)):
+		}
+	}
+}
+
+// Connected returns true if the last request the client made reached etcd,
+// even if etcd answered with an error such as a missing key. It is false
+// until the first request
+func (etcdClient *SimpleEtcdClient) Connected() bool {
+	return atomic.LoadInt32(&etcdClient.options.connected) == 1
+}
+
+// recordConnection remembers whether a request reached etcd. Requests
+// cancelled by their context say nothing about the connection
+func (opts *options) recordConnection(err error) {
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return
+	}
+
+	connected := int32(1)
+	if isUnreachable(err) {
+		connected = 0
+	}
+	atomic.StoreInt32(&opts.connected, connected)
+}
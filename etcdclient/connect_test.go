@@ -0,0 +1,91 @@
+package etcdclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"golang.org/x/net/context"
+)
+
+// unavailableProxy forwards to a new etcdtest.MemoryServer, after it
+// answers the first failures requests as if etcd was unavailable
+func unavailableProxy(t *testing.T, failures int64) *httptest.Server {
+	server := etcdtest.StartMemory()
+	t.Cleanup(func() { server.Stop() })
+	target, err := url.Parse(server.URI)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+
+	var requests int64
+	forward := httputil.NewSingleHostReverseProxy(target)
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt64(&requests, 1) <= failures {
+			writer.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		forward.ServeHTTP(writer, request)
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy
+}
+
+func TestDialAndVerifyRetriesUntilEtcdIsReachable(t *testing.T) {
+	proxy := unavailableProxy(t, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	etcdClient, err := etcdclient.DialAndVerify(ctx, proxy.URL)
+	if err != nil {
+		t.Fatalf("DialAndVerify returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	if !etcdClient.(*etcdclient.SimpleEtcdClient).Connected() {
+		t.Error("A verified client is not Connected")
+	}
+}
+
+func TestDialAndVerifyGivesUpWhenItsContextIsDone(t *testing.T) {
+	proxy := unavailableProxy(t, 1000)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if etcdClient, err := etcdclient.DialAndVerify(ctx, proxy.URL); err == nil {
+		etcdClient.Close()
+		t.Error("DialAndVerify of an unavailable etcd returned no error")
+	}
+}
+
+func TestConnected(t *testing.T) {
+	server := etcdtest.StartMemory()
+	etcd, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcd.Close()
+	etcdClient := etcd.(*etcdclient.SimpleEtcdClient)
+
+	if etcdClient.Connected() {
+		t.Error("Connected is true before the first request")
+	}
+	if _, err := etcdClient.Get("/missing"); err != nil {
+		t.Fatalf("Get returned %v", err)
+	}
+	if !etcdClient.Connected() {
+		t.Error("Connected is false after etcd answered that a key is missing")
+	}
+
+	server.Stop()
+	etcdClient.Get("/missing")
+	if etcdClient.Connected() {
+		t.Error("Connected is true after a request to a stopped server")
+	}
+}
@@ -0,0 +1,59 @@
+package etcdclient
+
+import (
+	"fmt"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Copy copies a key, or a directory and everything in it, from src to dst.
+// Keys that already exist in dst are overwritten. dst cannot be src or
+// inside it
+func (etcdClient *SimpleEtcdClient) Copy(src, dst string) error {
+	_, err := etcdClient.copy(src, dst)
+	return err
+}
+
+// Move copies a key, or a directory and everything in it, from src to dst,
+// then deletes src. Keys that already exist in dst are overwritten.
+// dst cannot be src or inside it
+func (etcdClient *SimpleEtcdClient) Move(src, dst string) error {
+	dir, err := etcdClient.copy(src, dst)
+	if err != nil {
+		return err
+	}
+
+	if dir {
+		return etcdClient.DelDir(src)
+	}
+	return etcdClient.Del(src)
+}
+
+// copy copies src to dst and returns true if src is a directory
+func (etcdClient *SimpleEtcdClient) copy(src, dst string) (bool, error) {
+	if Join(src) == Join(dst) || isAncestor(Join(src), Join(dst)) {
+		return false, fmt.Errorf("Cannot copy %v to %v, the destination is the source or inside it", src, dst)
+	}
+
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, src, nil)
+	if err != nil {
+		return false, err
+	}
+
+	node := response.Node
+	if !node.Dir {
+		_, err = api.Set(etcdClient.ctx, dst, node.Value, &client.SetOptions{TTL: node.TTLDuration()})
+		return false, err
+	}
+
+	data, err := etcdClient.Export(src)
+	if err != nil {
+		return true, err
+	}
+
+	if err := etcdClient.MkDir(dst); err != nil {
+		return true, err
+	}
+	return true, etcdClient.Import(dst, data, true)
+}
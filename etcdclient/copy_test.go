@@ -0,0 +1,49 @@
+package etcdclient_test
+
+import "testing"
+
+func TestCopyAndMove(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/src/dir/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if err := etcdClient.Copy("/src", "/copied"); err != nil {
+		t.Fatalf("Copy returned %v", err)
+	}
+	if err := etcdClient.Move("/src", "/moved"); err != nil {
+		t.Fatalf("Move returned %v", err)
+	}
+
+	for key, expected := range map[string]string{"/copied/dir/key": "value", "/moved/dir/key": "value", "/src/dir/key": ""} {
+		if value, err := etcdClient.Get(key); err != nil || value != expected {
+			t.Errorf("Get(%v) returned %q, %v, expected %q", key, value, err, expected)
+		}
+	}
+}
+
+func TestCopyAndMoveRejectADestinationInsideTheSource(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/src/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	for _, dst := range []string{"/src", "/src/", "/src/nested", "/src/key/below"} {
+		if err := etcdClient.Copy("/src", dst); err == nil {
+			t.Errorf("Copy to %v returned no error", dst)
+		}
+		if err := etcdClient.Move("/src", dst); err == nil {
+			t.Errorf("Move to %v returned no error", dst)
+		}
+	}
+	if err := etcdClient.Copy("/src/key", "/src/key"); err == nil {
+		t.Error("Copy of a key to itself returned no error")
+	}
+
+	if value, err := etcdClient.Get("/src/key"); err != nil || value != "value" {
+		t.Errorf("Get returned %q, %v after the rejected moves, expected \"value\"", value, err)
+	}
+	if keys, err := etcdClient.LsRecursive("/src"); err != nil || len(keys) != 1 {
+		t.Errorf("LsRecursive returned %v, %v, expected only /src/key", keys, err)
+	}
+}
@@ -0,0 +1,149 @@
+package etcdclient
+
+import (
+	"sync/atomic"
+
+	"github.com/coreos/etcd/client"
+)
+
+// DefaultDelBatchSize is how many keys DelPrefix deletes
+// between progress reports, unless DelBatchSize is used
+const DefaultDelBatchSize = 100
+
+// DelOption configures DelPrefix
+type DelOption func(*delOptions)
+
+type delOptions struct {
+	dryRun    bool
+	batchSize int
+	progress  ProgressFunc
+	onKey     func(key string)
+}
+
+// DelDryRun makes DelPrefix list and count the keys
+// it would delete without deleting anything
+func DelDryRun() DelOption {
+	return func(opts *delOptions) {
+		opts.dryRun = true
+	}
+}
+
+// DelBatchSize sets how many keys DelPrefix deletes, concurrently,
+// before reporting progress and moving on to the next batch
+func DelBatchSize(size int) DelOption {
+	return func(opts *delOptions) {
+		opts.batchSize = size
+	}
+}
+
+// DelProgress makes DelPrefix call progress with the
+// number of keys deleted so far after every batch
+func DelProgress(progress ProgressFunc) DelOption {
+	return func(opts *delOptions) {
+		opts.progress = progress
+	}
+}
+
+// DelOnKey makes DelPrefix call fn with every key it deletes,
+// or would delete with DelDryRun, before the key is deleted
+func DelOnKey(fn func(key string)) DelOption {
+	return func(opts *delOptions) {
+		opts.onKey = fn
+	}
+}
+
+// DelPrefix deletes every key under the prefix in batches, then the
+// directories that are left empty, and returns how many keys were
+// deleted. Unlike DelDir it never makes a single recursive delete, so
+// a mistake can be caught with DelDryRun and a long cleanup can be
+// followed with DelProgress. If a batch fails, the keys deleted so far
+// are counted and a MultiError is returned. Keys that expire or are deleted
+// by someone else before their batch are skipped and not counted.
+// Directories that get new keys while DelPrefix runs are kept
+func (etcdClient *SimpleEtcdClient) DelPrefix(prefix string, opts ...DelOption) (int, error) {
+	delOpts := delOptions{batchSize: DefaultDelBatchSize}
+	for _, opt := range opts {
+		opt(&delOpts)
+	}
+	if delOpts.batchSize <= 0 {
+		delOpts.batchSize = DefaultDelBatchSize
+	}
+
+	var keys, dirs []string
+	err := etcdClient.walkNodes(prefix, func(node *client.Node) error {
+		if node.Dir {
+			dirs = append(dirs, node.Key)
+		} else {
+			keys = append(keys, node.Key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	api := etcdClient.keysAPI()
+	deleted := 0
+	for start := 0; start < len(keys); start += delOpts.batchSize {
+		end := start + delOpts.batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+
+		if delOpts.onKey != nil {
+			for _, key := range batch {
+				delOpts.onKey(key)
+			}
+		}
+
+		if delOpts.dryRun {
+			deleted += len(batch)
+		} else {
+			var gone int64
+			err := etcdClient.forEachKey(batch, func(key string) error {
+				_, err := api.Delete(etcdClient.ctx, key, nil)
+				if isKeyNotFound(err) {
+					atomic.AddInt64(&gone, 1)
+					return nil
+				}
+				return err
+			})
+			if err != nil {
+				return deleted + len(batch) - int(gone) - len(err.(MultiError)), err
+			}
+			deleted += len(batch) - int(gone)
+		}
+
+		if delOpts.progress != nil {
+			delOpts.progress(deleted)
+		}
+	}
+
+	if delOpts.dryRun {
+		return deleted, nil
+	}
+
+	// walkNodes lists parents before their children, so
+	// deleting in reverse empties every directory first
+	dirs = append([]string{prefix}, dirs...)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := etcdClient.delEmptyDir(dirs[i]); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
+// delEmptyDir deletes the directory if it is empty. Directories that are
+// gone, were replaced by a key, got new keys or are the root are left alone
+func (etcdClient *SimpleEtcdClient) delEmptyDir(directory string) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Delete(etcdClient.ctx, directory, &client.DeleteOptions{Dir: true})
+	for _, code := range []int{client.ErrorCodeKeyNotFound, client.ErrorCodeNotDir, client.ErrorCodeDirNotEmpty, client.ErrorCodeRootROnly} {
+		if hasErrorCode(err, code) {
+			return nil
+		}
+	}
+	return err
+}
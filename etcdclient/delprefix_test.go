@@ -0,0 +1,67 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// setKeys sets every key to "value"
+func setKeys(t *testing.T, etcdClient *etcdclient.SimpleEtcdClient, keys ...string) {
+	for _, key := range keys {
+		if err := etcdClient.Set(key, "value"); err != nil {
+			t.Fatalf("Set(%v) returned %v", key, err)
+		}
+	}
+}
+
+func TestDelPrefix(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/prefix/a", "/prefix/b", "/prefix/dir/c", "/prefix/dir/deeper/d", "/kept")
+
+	var dryRun []string
+	deleted, err := etcdClient.DelPrefix("/prefix", etcdclient.DelDryRun(), etcdclient.DelOnKey(func(key string) {
+		dryRun = append(dryRun, key)
+	}))
+	if err != nil || deleted != 4 {
+		t.Fatalf("DelPrefix with DelDryRun returned %v, %v, expected 4 keys", deleted, err)
+	}
+	if len(dryRun) != 4 {
+		t.Errorf("DelOnKey was called with %v, expected the 4 keys", dryRun)
+	}
+	if keys, _ := etcdClient.LsRecursive("/prefix"); len(keys) != 6 {
+		t.Errorf("DelPrefix with DelDryRun deleted keys, %v are left", keys)
+	}
+
+	var progress []int
+	deleted, err = etcdClient.DelPrefix("/prefix", etcdclient.DelBatchSize(3), etcdclient.DelProgress(func(count int) {
+		progress = append(progress, count)
+	}))
+	if err != nil || deleted != 4 {
+		t.Fatalf("DelPrefix returned %v, %v, expected 4 keys", deleted, err)
+	}
+	if expected := []int{3, 4}; !reflect.DeepEqual(progress, expected) {
+		t.Errorf("DelPrefix reported %v, expected %v", progress, expected)
+	}
+	if keys, err := etcdClient.Ls("/"); err != nil || !reflect.DeepEqual(keys, []string{"/kept"}) {
+		t.Errorf("Ls returned %v, %v after DelPrefix, expected only /kept", keys, err)
+	}
+}
+
+func TestDelPrefixSkipsKeysDeletedMeanwhile(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/prefix/a", "/prefix/b", "/prefix/c")
+
+	deleted, err := etcdClient.DelPrefix("/prefix", etcdclient.DelOnKey(func(key string) {
+		if key == "/prefix/a" {
+			etcdClient.Del("/prefix/b")
+		}
+	}))
+	if err != nil || deleted != 2 {
+		t.Errorf("DelPrefix returned %v, %v, expected 2 keys", deleted, err)
+	}
+	if keys, _ := etcdClient.LsRecursive("/prefix"); len(keys) != 0 {
+		t.Errorf("%v are left after DelPrefix", keys)
+	}
+}
@@ -0,0 +1,137 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// DiffType is how a key differs between the two sides of a Diff
+type DiffType string
+
+const (
+	// DiffAdded keys only exist on the second side
+	DiffAdded DiffType = "added"
+
+	// DiffRemoved keys only exist on the first side
+	DiffRemoved DiffType = "removed"
+
+	// DiffChanged keys exist on both sides with different
+	// values, or are a directory on one side only
+	DiffChanged DiffType = "changed"
+)
+
+// DiffEntry is a key that differs between the two sides of a Diff
+type DiffEntry struct {
+	Type DiffType `json:"type"`
+
+	// Key is relative to the prefixes that were compared
+	Key string `json:"key"`
+
+	// A and B are the nodes on each side, nil if the key is missing there
+	A *ExportedNode `json:"a,omitempty"`
+	B *ExportedNode `json:"b,omitempty"`
+}
+
+// String describes the difference as a line of a diff
+func (entry DiffEntry) String() string {
+	switch entry.Type {
+	case DiffAdded:
+		return fmt.Sprintf("+ %v = %v", entry.Key, describeValue(entry.B))
+	case DiffRemoved:
+		return fmt.Sprintf("- %v = %v", entry.Key, describeValue(entry.A))
+	default:
+		return fmt.Sprintf("~ %v = %v -> %v", entry.Key, describeValue(entry.A), describeValue(entry.B))
+	}
+}
+
+// Diff compares every key and directory under prefixA with the ones
+// under prefixB, by their path relative to the prefix, and returns the
+// keys that were added, removed or changed from A to B, sorted by key
+func (etcdClient *SimpleEtcdClient) Diff(prefixA, prefixB string) ([]DiffEntry, error) {
+	a, err := etcdClient.exportNodes(prefixA)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := etcdClient.exportNodes(prefixB)
+	if err != nil {
+		return nil, err
+	}
+	return diffNodes(a, b), nil
+}
+
+// DiffExport compares every key and directory under the prefix with a
+// document produced by Export, such as a backup kept on disk. Keys only
+// in the document are added, keys only in etcd are removed
+func (etcdClient *SimpleEtcdClient) DiffExport(prefix string, data []byte) ([]DiffEntry, error) {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	a, err := etcdClient.exportNodes(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return diffNodes(a, export.Nodes), nil
+}
+
+// exportNodes returns the nodes Export would produce for the directory
+func (etcdClient *SimpleEtcdClient) exportNodes(directory string) ([]ExportedNode, error) {
+	data, err := etcdClient.Export(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+	return export.Nodes, nil
+}
+
+func diffNodes(a, b []ExportedNode) []DiffEntry {
+	nodesA := make(map[string]*ExportedNode)
+	nodesB := make(map[string]*ExportedNode)
+	var keys []string
+
+	for i := range a {
+		nodesA[normalizeKey(a[i].Key)] = &a[i]
+		keys = append(keys, normalizeKey(a[i].Key))
+	}
+	for i := range b {
+		key := normalizeKey(b[i].Key)
+		nodesB[key] = &b[i]
+		if _, ok := nodesA[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return compareKeys(keys[i], keys[j]) < 0
+	})
+
+	entries := make([]DiffEntry, 0)
+	for _, key := range keys {
+		nodeA, nodeB := nodesA[key], nodesB[key]
+
+		switch {
+		case nodeA == nil:
+			entries = append(entries, DiffEntry{Type: DiffAdded, Key: key, B: nodeB})
+		case nodeB == nil:
+			entries = append(entries, DiffEntry{Type: DiffRemoved, Key: key, A: nodeA})
+		case nodeA.Dir != nodeB.Dir || nodeA.Value != nodeB.Value:
+			entries = append(entries, DiffEntry{Type: DiffChanged, Key: key, A: nodeA, B: nodeB})
+		}
+	}
+	return entries
+}
+
+// describeValue quotes the value of a key, directories have no value
+func describeValue(node *ExportedNode) string {
+	if node.Dir {
+		return "(directory)"
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
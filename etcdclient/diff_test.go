@@ -0,0 +1,67 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// diffLines describes every entry as a line of a diff, or the error
+func diffLines(entries []etcdclient.DiffEntry, err error) []string {
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	lines := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		lines = append(lines, entry.String())
+	}
+	return lines
+}
+
+func TestDiff(t *testing.T) {
+	etcdClient := dial(t)
+	values := map[string]string{
+		"/a/same":    "same",
+		"/a/changed": "1",
+		"/a/removed": "gone",
+		"/a/kind":    "key",
+		"/b/same":    "same",
+		"/b/changed": "2",
+		"/b/added":   "new",
+		"/b/kind/x":  "dir",
+	}
+	for key, value := range values {
+		if err := etcdClient.Set(key, value); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	expected := []string{
+		`+ /added = "new"`,
+		`~ /changed = "1" -> "2"`,
+		`~ /kind = "key" -> (directory)`,
+		`+ /kind/x = "dir"`,
+		`- /removed = "gone"`,
+	}
+	if lines := diffLines(etcdClient.Diff("/a", "/b")); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Diff returned\n%q\nexpected\n%q", lines, expected)
+	}
+	if lines := diffLines(etcdClient.Diff("/a", "/a")); len(lines) != 0 {
+		t.Errorf("Diff of a prefix with itself returned %q", lines)
+	}
+}
+
+func TestDiffExport(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/live/kept", "/live/removed")
+	data := []byte(`{"directory": "/backup", "nodes": [{"key": "/kept", "value": "value"}, {"key": "/added", "value": "new"}]}`)
+
+	expected := []string{`+ /added = "new"`, `- /removed = "value"`}
+	if lines := diffLines(etcdClient.DiffExport("/live", data)); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("DiffExport returned %q, expected %q", lines, expected)
+	}
+	if _, err := etcdClient.DiffExport("/live", []byte("not json")); err == nil {
+		t.Error("DiffExport of a document that is not JSON returned no error")
+	}
+}
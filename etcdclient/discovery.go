@@ -0,0 +1,164 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// DiscoveryDir is the directory the etcd discovery protocol keeps
+// the clusters being bootstrapped in
+const DiscoveryDir = "/_etcd/registry"
+
+// discoverySizeKey is the key, in the directory of a token,
+// that holds the size of the cluster
+const discoverySizeKey = "_config/size"
+
+// Discovery describes a cluster being bootstrapped with the
+// etcd discovery protocol
+type Discovery struct {
+	Token string `json:"token"`
+
+	// Size is how many members the cluster starts with
+	Size int `json:"size"`
+
+	// Members are the members that registered so far
+	Members []DiscoveredMember `json:"members"`
+}
+
+// Full returns true once Size members registered, members
+// that join later are not part of the initial cluster
+func (discovery Discovery) Full() bool {
+	return len(discovery.Members) >= discovery.Size
+}
+
+// DiscoveredMember is a member that registered with the discovery token
+type DiscoveredMember struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	PeerURLs []string `json:"peerURLs"`
+}
+
+// NewDiscoveryToken registers a new cluster of size members in
+// DiscoveryDir, making this cluster the discovery service for it.
+// Start every member of the new cluster with DiscoveryURL(token)
+// as its --discovery flag
+func (etcdClient *SimpleEtcdClient) NewDiscoveryToken(size int) (string, error) {
+	if size < 1 {
+		return "", fmt.Errorf("Discovery size must be at least 1, got %v", size)
+	}
+
+	token, err := newSessionID()
+	if err != nil {
+		return "", err
+	}
+
+	api := etcdClient.keysAPI()
+	_, err = api.Create(etcdClient.ctx, path.Join(DiscoveryDir, token, discoverySizeKey), strconv.Itoa(size))
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// DiscoveryURL returns the url the members of the cluster
+// registered with the token use to find each other
+func (etcdClient *SimpleEtcdClient) DiscoveryURL(token string) string {
+	endpoint := strings.TrimSuffix(etcdClient.Endpoints()[0], "/")
+	return endpoint + "/v2/keys" + path.Join(DiscoveryDir, token)
+}
+
+// DiscoveryStatus returns the size of the cluster registered with the
+// token and the members that registered so far. The token can also be
+// the url of another discovery service, like https://discovery.etcd.io
+func (etcdClient *SimpleEtcdClient) DiscoveryStatus(token string) (Discovery, error) {
+	if strings.HasPrefix(token, "http://") || strings.HasPrefix(token, "https://") {
+		return etcdClient.remoteDiscoveryStatus(token)
+	}
+
+	api := etcdClient.keysAPI()
+	directory := path.Join(DiscoveryDir, token)
+	response, err := api.Get(etcdClient.ctx, directory, &client.GetOptions{Sort: true})
+	if err != nil {
+		return Discovery{}, err
+	}
+
+	// _config is hidden, so it is left out of the directory
+	size, err := api.Get(etcdClient.ctx, path.Join(directory, discoverySizeKey), nil)
+	if err != nil {
+		return Discovery{}, err
+	}
+	return newDiscovery(token, size.Node, response.Node)
+}
+
+// remoteDiscoveryStatus reads the discovery url, which
+// answers like the keys api of etcd
+func (etcdClient *SimpleEtcdClient) remoteDiscoveryStatus(discoveryURL string) (Discovery, error) {
+	discoveryURL = strings.TrimSuffix(discoveryURL, "/")
+	node, err := etcdClient.remoteDiscoveryNode(discoveryURL + "?sorted=true")
+	if err != nil {
+		return Discovery{}, err
+	}
+	size, err := etcdClient.remoteDiscoveryNode(discoveryURL + "/" + discoverySizeKey)
+	if err != nil {
+		return Discovery{}, err
+	}
+	return newDiscovery(path.Base(discoveryURL), size, node)
+}
+
+// remoteDiscoveryNode returns the node a discovery service answers with
+func (etcdClient *SimpleEtcdClient) remoteDiscoveryNode(nodeURL string) (*client.Node, error) {
+	response, err := ctxhttp.Get(etcdClient.ctx, nil, nodeURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Discovery service %v returned %v", nodeURL, response.Status)
+	}
+
+	var body client.Response
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Node == nil {
+		return nil, fmt.Errorf("Discovery service %v returned no node", nodeURL)
+	}
+	return body.Node, nil
+}
+
+// newDiscovery reads the size from its node and a member from every key
+// of the token's directory, whose value is "name=peerURL" for each of the
+// member's peer urls, separated by commas
+func newDiscovery(token string, sizeNode, node *client.Node) (Discovery, error) {
+	size, err := strconv.Atoi(sizeNode.Value)
+	if err != nil {
+		return Discovery{}, fmt.Errorf("Discovery size is not a number: %v", sizeNode.Value)
+	}
+	discovery := Discovery{Token: token, Size: size, Members: make([]DiscoveredMember, 0)}
+
+	for _, child := range node.Nodes {
+		if child.Dir || strings.HasPrefix(path.Base(child.Key), "_") {
+			continue
+		}
+
+		member := DiscoveredMember{ID: path.Base(child.Key)}
+		for _, peer := range strings.Split(child.Value, ",") {
+			name, peerURL, ok := strings.Cut(peer, "=")
+			if !ok {
+				continue
+			}
+			member.Name = name
+			member.PeerURLs = append(member.PeerURLs, peerURL)
+		}
+		discovery.Members = append(discovery.Members, member)
+	}
+	return discovery, nil
+}
@@ -0,0 +1,74 @@
+package etcdclient_test
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// register registers a member with the token, like etcd does on start
+func register(t *testing.T, etcdClient *etcdclient.SimpleEtcdClient, token, id, value string) {
+	t.Helper()
+	if err := etcdClient.Set(etcdclient.DiscoveryDir+"/"+token+"/"+id, value); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+}
+
+func TestDiscovery(t *testing.T) {
+	etcdClient := dial(t)
+
+	if _, err := etcdClient.NewDiscoveryToken(0); err == nil {
+		t.Error("NewDiscoveryToken(0) returned no error")
+	}
+	token, err := etcdClient.NewDiscoveryToken(2)
+	if err != nil || token == "" {
+		t.Fatalf("NewDiscoveryToken returned %q, %v", token, err)
+	}
+	if url := etcdClient.DiscoveryURL(token); url != etcdClient.Endpoints()[0]+"/v2/keys"+etcdclient.DiscoveryDir+"/"+token {
+		t.Errorf("DiscoveryURL returned %v", url)
+	}
+
+	status, err := etcdClient.DiscoveryStatus(token)
+	if err != nil || status.Token != token || status.Size != 2 || len(status.Members) != 0 || status.Full() {
+		t.Fatalf("DiscoveryStatus of a new token returned %+v, %v", status, err)
+	}
+
+	register(t, etcdClient, token, "1", "infra1=http://10.0.0.1:2380")
+	register(t, etcdClient, token, "2", "infra2=http://10.0.0.2:2380,infra2=http://10.0.1.2:2380")
+	status, err = etcdClient.DiscoveryStatus(token)
+	expected := []etcdclient.DiscoveredMember{
+		{ID: "1", Name: "infra1", PeerURLs: []string{"http://10.0.0.1:2380"}},
+		{ID: "2", Name: "infra2", PeerURLs: []string{"http://10.0.0.2:2380", "http://10.0.1.2:2380"}},
+	}
+	if err != nil || status.Size != 2 || !reflect.DeepEqual(status.Members, expected) || !status.Full() {
+		t.Errorf("DiscoveryStatus returned %+v, %v, expected both members", status, err)
+	}
+
+	if _, err := etcdClient.DiscoveryStatus("missing"); err == nil {
+		t.Error("DiscoveryStatus of a missing token returned no error")
+	}
+}
+
+func TestDiscoveryStatusOfAnotherService(t *testing.T) {
+	service := dial(t)
+	token, err := service.NewDiscoveryToken(3)
+	if err != nil {
+		t.Fatalf("NewDiscoveryToken returned %v", err)
+	}
+	register(t, service, token, "1", "infra1=http://10.0.0.1:2380")
+
+	etcdClient := dial(t)
+	status, err := etcdClient.DiscoveryStatus(service.DiscoveryURL(token) + "/")
+	if err != nil || status.Token != token || status.Size != 3 || len(status.Members) != 1 || status.Members[0].Name != "infra1" {
+		t.Errorf("DiscoveryStatus of the url returned %+v, %v", status, err)
+	}
+
+	server := httptest.NewServer(nil)
+	defer server.Close()
+	if _, err := etcdClient.DiscoveryStatus(server.URL + "/missing"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("DiscoveryStatus of a url that is not a discovery service returned %v", err)
+	}
+}
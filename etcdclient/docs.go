@@ -0,0 +1,59 @@
+package etcdclient
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/coreos/etcd/client"
+)
+
+// PutDoc stores the document as the key id under the prefix, marshaled
+// with the codec for the key, and returns its version. The version is the
+// key's ModifiedIndex, pass it to UpdateDoc to only overwrite this version
+func (etcdClient *SimpleEtcdClient) PutDoc(prefix, id string, doc interface{}) (uint64, error) {
+	return etcdClient.writeDoc(prefix, id, doc, nil)
+}
+
+// GetDoc unmarshals the document stored as the key id under the prefix
+// into the value out points to and returns its version. A missing document
+// returns an error matching ErrKeyNotFound
+func (etcdClient *SimpleEtcdClient) GetDoc(prefix, id string, out interface{}) (uint64, error) {
+	key := path.Join(prefix, id)
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, key, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := etcdClient.CodecFor(key).Unmarshal([]byte(response.Node.Value), out); err != nil {
+		return 0, fmt.Errorf("Failed to decode %v: %v", key, err)
+	}
+	return response.Node.ModifiedIndex, nil
+}
+
+// UpdateDoc stores the document like PutDoc, only if the stored document
+// is still at version, and returns the new version. A version of 0 only
+// creates the document if it does not exist. If another client changed the
+// document first, the error matches ErrConflict and nothing is written
+func (etcdClient *SimpleEtcdClient) UpdateDoc(prefix, id string, version uint64, doc interface{}) (uint64, error) {
+	opts := &client.SetOptions{PrevIndex: version}
+	if version == 0 {
+		opts = &client.SetOptions{PrevExist: client.PrevNoExist}
+	}
+	return etcdClient.writeDoc(prefix, id, doc, opts)
+}
+
+func (etcdClient *SimpleEtcdClient) writeDoc(prefix, id string, doc interface{}, opts *client.SetOptions) (uint64, error) {
+	key := path.Join(prefix, id)
+	data, err := etcdClient.CodecFor(key).Marshal(doc)
+	if err != nil {
+		return 0, fmt.Errorf("Failed to encode %v: %v", key, err)
+	}
+
+	api := etcdClient.keysAPI()
+	response, err := api.Set(etcdClient.ctx, key, string(data), opts)
+	if err != nil {
+		return 0, err
+	}
+	return response.Node.ModifiedIndex, nil
+}
@@ -0,0 +1,59 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+type device struct {
+	Name   string
+	Online bool
+}
+
+func TestPutDocAndGetDoc(t *testing.T) {
+	etcdClient := dial(t)
+
+	version, err := etcdClient.PutDoc("/devices", "42", device{"thermostat", true})
+	if err != nil || version == 0 {
+		t.Fatalf("PutDoc returned %v, %v", version, err)
+	}
+	var doc device
+	if got, err := etcdClient.GetDoc("/devices", "42", &doc); err != nil || got != version || doc != (device{"thermostat", true}) {
+		t.Errorf("GetDoc returned %+v at version %v, %v, expected the document at version %v", doc, got, err, version)
+	}
+	if _, err := etcdClient.GetDoc("/devices", "missing", &doc); !errors.Is(err, etcdclient.ErrKeyNotFound) {
+		t.Errorf("GetDoc of a missing document returned %v, expected ErrKeyNotFound", err)
+	}
+
+	etcdClient.Set("/devices/invalid", "not json")
+	if _, err := etcdClient.GetDoc("/devices", "invalid", &doc); err == nil {
+		t.Error("GetDoc of a document that is not JSON returned no error")
+	}
+}
+
+func TestUpdateDoc(t *testing.T) {
+	etcdClient := dial(t)
+
+	version, err := etcdClient.UpdateDoc("/devices", "42", 0, device{Name: "created"})
+	if err != nil {
+		t.Fatalf("UpdateDoc of a new document returned %v", err)
+	}
+	if _, err := etcdClient.UpdateDoc("/devices", "42", 0, device{Name: "created again"}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("UpdateDoc with version 0 of an existing document returned %v, expected ErrConflict", err)
+	}
+
+	updated, err := etcdClient.UpdateDoc("/devices", "42", version, device{Name: "updated"})
+	if err != nil || updated <= version {
+		t.Fatalf("UpdateDoc at the current version returned %v, %v", updated, err)
+	}
+	if _, err := etcdClient.UpdateDoc("/devices", "42", version, device{Name: "stale"}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("UpdateDoc at a stale version returned %v, expected ErrConflict", err)
+	}
+
+	var doc device
+	if got, _ := etcdClient.GetDoc("/devices", "42", &doc); got != updated || doc.Name != "updated" {
+		t.Errorf("GetDoc returned %+v at version %v, expected the update at version %v", doc, got, updated)
+	}
+}
@@ -0,0 +1,72 @@
+package etcdclient
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// encryptedPrefix marks values that were encrypted by WithEncryption
+const encryptedPrefix = "aesgcm:"
+
+// ErrDecryptionFailed is returned when reading a value that cannot be
+// decrypted, usually because it was encrypted with a different key
+var ErrDecryptionFailed = errors.New("etcd value could not be decrypted, the encryption key is probably wrong")
+
+// WithEncryption encrypts values with AES-GCM before they are written and
+// transparently decrypts them when they are read. The key must be 16, 24 or
+// 32 bytes long. Encrypted values are stored base64 encoded with an "aesgcm:"
+// prefix, values without the prefix are read as is. Every write uses a new
+// nonce, so conditional writes on the previous value never match an
+// encrypted value
+func WithEncryption(key []byte) Option {
+	return func(opts *options) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			opts.err = err
+			return
+		}
+
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			opts.err = err
+			return
+		}
+		opts.valueCodecs = append(opts.valueCodecs, &encryptionCodec{gcm})
+	}
+}
+
+type encryptionCodec struct {
+	gcm cipher.AEAD
+}
+
+func (codec *encryptionCodec) encode(value string) (string, error) {
+	nonce := make([]byte, codec.gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := codec.gcm.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (codec *encryptionCodec) decode(value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return value, nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < codec.gcm.NonceSize() {
+		return "", ErrDecryptionFailed
+	}
+
+	nonceSize := codec.gcm.NonceSize()
+	opened, err := codec.gcm.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+	if err != nil {
+		return "", ErrDecryptionFailed
+	}
+	return string(opened), nil
+}
@@ -0,0 +1,62 @@
+package etcdclient_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestWithEncryption(t *testing.T) {
+	encrypted, plain := dialWithPlain(t, etcdclient.WithEncryption(bytes.Repeat([]byte{1}, 32)))
+
+	if err := encrypted.Set("/secret", "password"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value, err := encrypted.Get("/secret"); err != nil || value != "password" {
+		t.Errorf("Get returned %q, %v, expected \"password\"", value, err)
+	}
+	stored, _ := plain.Get("/secret")
+	if !strings.HasPrefix(stored, "aesgcm:") || strings.Contains(stored, "password") {
+		t.Errorf("The value was stored as %q, expected it encrypted", stored)
+	}
+
+	// values written without encryption are read as is
+	if err := plain.Set("/public", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value, err := encrypted.Get("/public"); err != nil || value != "value" {
+		t.Errorf("Get of a value that is not encrypted returned %q, %v", value, err)
+	}
+}
+
+func TestWithEncryptionWithTheWrongKey(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	writer, err := server.Client(etcdclient.WithEncryption(bytes.Repeat([]byte{1}, 16)))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer writer.Close()
+	reader, err := server.Client(etcdclient.WithEncryption(bytes.Repeat([]byte{2}, 16)))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer reader.Close()
+
+	if err := writer.Set("/secret", "password"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if _, err := reader.Get("/secret"); !errors.Is(err, etcdclient.ErrDecryptionFailed) {
+		t.Errorf("Get with the wrong key returned %v, expected ErrDecryptionFailed", err)
+	}
+}
+
+func TestWithEncryptionRequiresAValidKey(t *testing.T) {
+	if _, err := etcdclient.Dial("http://127.0.0.1:2379", etcdclient.WithEncryption([]byte("short"))); err == nil {
+		t.Error("Dial with a 5 byte encryption key returned no error")
+	}
+}
@@ -0,0 +1,49 @@
+package etcdclient
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ExportEnv returns every key in the directory, recursively, as an
+// environment variable: the key relative to the directory in upper case,
+// with every character that is not a letter or a digit replaced by an
+// underscore, so "/app/db/host" under "/app" becomes DB_HOST. Keys that
+// become the same name, like "/app/db-host" and "/app/db/host", return an
+// error naming both
+func (etcdClient *SimpleEtcdClient) ExportEnv(directory string) (map[string]string, error) {
+	values, err := etcdClient.valuesUnder(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	relatives := make([]string, 0, len(values))
+	for relative := range values {
+		relatives = append(relatives, relative)
+	}
+	sort.Strings(relatives)
+
+	env := make(map[string]string, len(values))
+	keys := make(map[string]string, len(values))
+	for _, relative := range relatives {
+		name := envName(relative)
+		if other, ok := keys[name]; ok {
+			return nil, fmt.Errorf("Keys %v and %v are both exported as %v", Join(directory, other), Join(directory, relative), name)
+		}
+		keys[name] = relative
+		env[name] = values[relative]
+	}
+	return env, nil
+}
+
+// envName converts a relative key into an environment variable name
+func envName(key string) string {
+	return strings.Map(func(char rune) rune {
+		if char > unicode.MaxASCII || !(unicode.IsLetter(char) || unicode.IsDigit(char)) {
+			return '_'
+		}
+		return unicode.ToUpper(char)
+	}, strings.Trim(key, "/"))
+}
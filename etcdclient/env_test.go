@@ -0,0 +1,50 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportEnv(t *testing.T) {
+	etcdClient := dial(t)
+	for key, value := range map[string]string{
+		"/app/db/host":    "localhost",
+		"/app/api.key":    "secret",
+		"/app/Mixed-Case": "1",
+		"/app/café":       "2",
+		"/other/key":      "3",
+	} {
+		if err := etcdClient.Set(key, value); err != nil {
+			t.Fatalf("Set(%v) returned %v", key, err)
+		}
+	}
+
+	env, err := etcdClient.ExportEnv("/app")
+	if err != nil {
+		t.Fatalf("ExportEnv returned %v", err)
+	}
+	expected := map[string]string{"DB_HOST": "localhost", "API_KEY": "secret", "MIXED_CASE": "1", "CAF_": "2"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("ExportEnv returned %v, expected %v", env, expected)
+	}
+}
+
+func TestExportEnvRejectsKeysWithTheSameName(t *testing.T) {
+	etcdClient := dial(t)
+	for _, key := range []string{"/app/db-host", "/app/db/host"} {
+		if err := etcdClient.Set(key, "value"); err != nil {
+			t.Fatalf("Set(%v) returned %v", key, err)
+		}
+	}
+
+	_, err := etcdClient.ExportEnv("/app")
+	if err == nil {
+		t.Fatal("ExportEnv returned no error")
+	}
+	for _, expected := range []string{"/app/db-host", "/app/db/host", "DB_HOST"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("The error %q does not mention %v", err, expected)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package etcdclient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+var (
+	// ErrKeyNotFound means the key does not exist
+	ErrKeyNotFound = errors.New("key not found")
+
+	// ErrNotDir means the key is a key/value where a directory was expected
+	ErrNotDir = errors.New("not a directory")
+
+	// ErrTimeout means etcd did not respond in time
+	ErrTimeout = errors.New("request timed out")
+
+	// ErrConnRefused means etcd refused the connection
+	ErrConnRefused = errors.New("connection refused")
+
+	// ErrReadOnly is returned instead of writing when the
+	// client was created with WithReadOnly
+	ErrReadOnly = errors.New("client is read-only")
+
+	// ErrConflict means a conditional write failed because
+	// the key did not have the expected value or index
+	ErrConflict = errors.New("key was changed")
+
+	// ErrFrozen means a write was rejected because
+	// the key is under a prefix frozen by FreezePrefix
+	ErrFrozen = errors.New("prefix is frozen")
+)
+
+// Error is returned by every request the client makes, it records the
+// operation and key that failed. Use errors.Is with ErrKeyNotFound,
+// ErrNotDir, ErrConflict, ErrTimeout or ErrConnRefused to check why it
+// failed, or errors.As to get the underlying client.Error
+type Error struct {
+	Op  string
+	Key string
+	Err error
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("etcdclient: %v %v: %v", err.Op, err.Key, err.Err)
+}
+
+// Unwrap returns the underlying error
+func (err *Error) Unwrap() error {
+	return err.Err
+}
+
+// Is reports whether the error matches one of the sentinel errors
+func (err *Error) Is(target error) bool {
+	switch target {
+	case ErrKeyNotFound:
+		return hasErrorCode(err.Err, client.ErrorCodeKeyNotFound)
+	case ErrNotDir:
+		return hasErrorCode(err.Err, client.ErrorCodeNotDir)
+	case ErrConflict:
+		return isConflict(err.Err)
+	case ErrTimeout:
+		return anyClusterError(err.Err, isTimeout)
+	case ErrConnRefused:
+		return anyClusterError(err.Err, isConnRefused)
+	}
+	return false
+}
+
+// ConflictError is returned by MkDirAll when a segment of the path
+// is a key with a value where a directory was needed
+type ConflictError struct {
+	// Path is the directory that was being created
+	Path string
+
+	// Key is the segment of Path that is a key/value
+	Key string
+}
+
+func (err *ConflictError) Error() string {
+	return fmt.Sprintf("etcdclient: mkdir %v: %v is not a directory", err.Path, err.Key)
+}
+
+// Is reports whether the target is ErrNotDir
+func (err *ConflictError) Is(target error) bool {
+	return target == ErrNotDir
+}
+
+// FrozenError is returned instead of writing a key
+// under a prefix frozen by FreezePrefix
+type FrozenError struct {
+	// Key is the key that was being written
+	Key string
+
+	Freeze
+}
+
+func (err *FrozenError) Error() string {
+	return fmt.Sprintf("%v is frozen by %v since %v: %v", err.Key, err.Prefix, err.FrozenAt.Format(time.RFC3339), err.Reason)
+}
+
+// Is reports whether the target is ErrFrozen
+func (err *FrozenError) Is(target error) bool {
+	return target == ErrFrozen
+}
+
+func wrapError(op, key string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &Error{Op: op, Key: key, Err: err}
+}
+
+// etcdError returns the client.Error the error wraps, if any
+func etcdError(err error) (client.Error, bool) {
+	var etcdErr client.Error
+	ok := errors.As(err, &etcdErr)
+	return etcdErr, ok
+}
+
+func hasErrorCode(err error, code int) bool {
+	etcdErr, ok := etcdError(err)
+	return ok && etcdErr.Code == code
+}
+
+func isKeyNotFound(err error) bool {
+	return hasErrorCode(err, client.ErrorCodeKeyNotFound)
+}
+
+func isNodeExist(err error) bool {
+	return hasErrorCode(err, client.ErrorCodeNodeExist)
+}
+
+// anyClusterError returns true if check is true for the error or,
+// if etcd was unreachable, for the error of any of its endpoints
+func anyClusterError(err error, check func(error) bool) bool {
+	var clusterErr *client.ClusterError
+	if !errors.As(err, &clusterErr) {
+		return check(err)
+	}
+
+	for _, endpointErr := range clusterErr.Errors {
+		if check(endpointErr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTimeout returns true if the request ran out of time. The etcd client
+// reports a HeaderTimeoutPerRequest with an error of its own, not a net.Error
+func isTimeout(err error) bool {
+	var netErr net.Error
+	if err == context.DeadlineExceeded || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return true
+	}
+	return strings.HasSuffix(err.Error(), "exceeded header timeout")
+}
+
+func isConnRefused(err error) bool {
+	return errors.Is(err, syscall.ECONNREFUSED)
+}
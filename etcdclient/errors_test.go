@@ -0,0 +1,77 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"golang.org/x/net/context"
+)
+
+func TestErrorsRecordTheOperationAndKey(t *testing.T) {
+	etcdClient := dial(t)
+
+	_, err := etcdClient.GetResponse("/missing", nil)
+	if !errors.Is(err, etcdclient.ErrKeyNotFound) {
+		t.Errorf("GetResponse of a missing key returned %v, expected ErrKeyNotFound", err)
+	}
+	var wrapped *etcdclient.Error
+	if !errors.As(err, &wrapped) || wrapped.Op != "get" || wrapped.Key != "/missing" {
+		t.Errorf("GetResponse returned %#v, expected an Error of the get of /missing", err)
+	}
+	var etcdErr client.Error
+	if !errors.As(err, &etcdErr) || etcdErr.Code != client.ErrorCodeKeyNotFound {
+		t.Errorf("GetResponse returned %v, expected it to wrap the client.Error", err)
+	}
+
+	if err := etcdClient.Set("/file", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Set("/file/key", "value"); !errors.Is(err, etcdclient.ErrNotDir) {
+		t.Errorf("Set under a key returned %v, expected ErrNotDir", err)
+	}
+}
+
+func TestErrConnRefused(t *testing.T) {
+	server := etcdtest.StartMemory()
+	etcdClient, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+	server.Stop()
+
+	if err := etcdClient.Set("/key", "value"); !errors.Is(err, etcdclient.ErrConnRefused) {
+		t.Errorf("Set on a stopped server returned %v, expected ErrConnRefused", err)
+	}
+}
+
+func TestErrTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	etcdClient, err := etcdclient.Dial(server.URL, etcdclient.WithHeaderTimeoutPerRequest(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	if err := etcdClient.Set("/key", "value"); !errors.Is(err, etcdclient.ErrTimeout) {
+		t.Errorf("Set on a server that does not answer returned %v, expected ErrTimeout", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := etcdClient.WithContext(ctx).Set("/key", "value"); !errors.Is(err, etcdclient.ErrTimeout) {
+		t.Errorf("Set past the deadline of its context returned %v, expected ErrTimeout", err)
+	}
+}
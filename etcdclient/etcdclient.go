@@ -2,26 +2,90 @@ package etcdclient
 
 import (
 	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/coreos/etcd/client"
 	"golang.org/x/net/context"
 )
 
-// EtcdClient interface lets your Get/Set from Etcd
-type EtcdClient interface {
-	// Del deletes a key from Etcd
-	Del(key string) error
-
-	// DelDir deletes a dir from Etcd
-	DelDir(key string) error
-
+// KeyReader reads keys from etcd
+type KeyReader interface {
 	// Get gets a value in Etcd
 	Get(key string) (string, error)
 
+	// GetLinearizable gets a value in Etcd with a quorum read, so it
+	// always sees the latest committed value, including a Set that
+	// just returned. Get may return a stale value from a follower
+	GetLinearizable(key string) (string, error)
+
+	// GetMulti gets many keys concurrently. Missing keys are returned as
+	// empty strings, like Get. If any fail, the values that could be read
+	// are returned along with a MultiError holding the error for each
+	// failed key
+	GetMulti(keys []string) (map[string]string, error)
+
+	// GetBytes gets a binary value stored with SetBytes.
+	// A missing key returns a nil value
+	GetBytes(key string) ([]byte, error)
+
+	// Index returns the current etcd index
+	Index() (uint64, error)
+}
+
+// KeyWriter writes and deletes keys in etcd
+type KeyWriter interface {
 	// Set sets a value in Etcd
 	Set(key, value string) error
 
+	// Del deletes a key from Etcd
+	Del(key string) error
+
+	// DelAndGet deletes a key from Etcd and returns the value it had.
+	// Deleting a missing key returns an empty value
+	DelAndGet(key string) (string, error)
+
+	// Swap sets a value in Etcd and returns the value it replaced.
+	// Swapping a missing key returns an empty value
+	Swap(key, newValue string) (string, error)
+
+	// GetOrSet returns the value of the key, or atomically sets it to
+	// defaultValue if it does not exist. created is true if the key was set
+	GetOrSet(key, defaultValue string) (value string, created bool, err error)
+
+	// SetWithOptions sets a value in Etcd if the conditions in opts are met
+	SetWithOptions(key, value string, opts SetOptions) (*Result, error)
+
+	// SetBytes sets a binary value, base64 encoded since
+	// the etcd v2 store only holds strings
+	SetBytes(key string, value []byte) error
+
+	// SetMulti sets many keys concurrently. Every key is attempted, if any
+	// fail a MultiError is returned with the error for each failed key
+	SetMulti(kvs map[string]string) error
+
+	// SetMultiAllOrNothing sets many keys concurrently. If any key fails,
+	// the keys that were set are restored to their previous values and a
+	// MultiError is returned. Etcd v2 has no transactions, so other clients
+	// can observe the partial write before it is rolled back
+	SetMultiAllOrNothing(kvs map[string]string) error
+
+	// RefreshTTL extends the ttl of an existing key without changing
+	// its value or firing watch events
+	RefreshTTL(key string, ttl time.Duration) error
+}
+
+// DirManager lists and manages etcd directories
+type DirManager interface {
+	// MkDir creates an empty etcd directory
+	MkDir(directory string) error
+
+	// DelDir deletes a dir from Etcd
+	DelDir(key string) error
+
 	// UpdateDirWithTTL updates a directory with a ttl value
 	UpdateDirWithTTL(key string, ttl time.Duration) error
 
@@ -31,42 +95,368 @@ type EtcdClient interface {
 	// LsRecursive returns all the keys available in the directory, recursively
 	LsRecursive(directory string) ([]string, error)
 
-	// MkDir creates an empty etcd directory
-	MkDir(directory string) error
+	// LsRecursivePaged returns up to limit keys in the directory, recursively,
+	// starting after the key after. Pass an empty after for the first page and
+	// the page's Next for the following pages
+	LsRecursivePaged(directory string, limit int, after string) (LsPage, error)
+
+	// WalkRecursive calls fn for every key and directory in the directory,
+	// recursively. If fn returns an error the walk stops and the error is
+	// returned
+	WalkRecursive(directory string, fn WalkFunc) error
+
+	// LsMatching returns all the keys in the directory, recursively, that match
+	// the pattern. The pattern is matched like in WatchRecursiveFiltered
+	LsMatching(directory, pattern string) ([]string, error)
 
+	// LsKeys returns the names of the keys in the directory, relative to
+	// the directory. Subdirectories are left out
+	LsKeys(directory string) ([]string, error)
+
+	// LsDirs returns the names of the subdirectories in the directory,
+	// relative to the directory. Keys are left out
+	LsDirs(directory string) ([]string, error)
+
+	// Copy copies a key, or a directory and everything in it, from src to dst.
+	// Keys that already exist in dst are overwritten
+	Copy(src, dst string) error
+
+	// Move copies a key, or a directory and everything in it, from src to dst,
+	// then deletes src. Keys that already exist in dst are overwritten
+	Move(src, dst string) error
+
+	// Export returns a JSON document with every key, value, directory
+	// and TTL in the directory, recursively
+	Export(directory string) ([]byte, error)
+
+	// Import writes a document produced by Export into the directory.
+	// Existing keys are only replaced if overwrite is true
+	Import(directory string, data []byte, overwrite bool) error
+}
+
+// Watcher watches etcd for changes
+type Watcher interface {
 	// WatchRecursive watches a directory and calls the callback everytime something changes.
 	// The callback is called with the key of the thing that changed along with the value
 	// that the thing was changed to.
 	// This method only returns if there is an error
 	WatchRecursive(directory string, onChangeCallback OnChangeCallback) error
+
+	// WatchRecursiveFrom watches a directory for changes after the given index and
+	// calls the callback for each change along with the index of the change. The
+	// index can be stored and passed back in later to resume without missing events.
+	// Etcd only keeps a limited history, so if the index has been cleared an
+	// error is returned and the caller must resync.
+	// This method only returns if there is an error
+	WatchRecursiveFrom(directory string, afterIndex uint64, onChangeCallback OnIndexedChangeCallback) error
+
+	// WatchEvents watches a directory for changes after the given index and
+	// calls the callback with every change. Like WatchRecursiveFrom, an error
+	// is returned if the index has been cleared.
+	// This method only returns if there is an error
+	WatchEvents(directory string, afterIndex uint64, onEvent OnEventCallback) error
+
+	// WatchRecursiveFiltered watches a directory like WatchRecursive, but only calls
+	// the callback for keys matching the pattern. The pattern is matched against the
+	// key relative to the directory, using the syntax of path.Match.
+	// This method only returns if there is an error
+	WatchRecursiveFiltered(directory, pattern string, onChangeCallback OnChangeCallback) error
+
+	// WatchPrefixes watches every prefix and calls the callback everytime something
+	// changes in any of them. The callback is never called concurrently. If any of
+	// the watches fails, the others are stopped and the error is returned.
+	// This method only returns if there is an error
+	WatchPrefixes(prefixes []string, onChangeCallback OnChangeCallback) error
+
+	// WatchRecursiveDebounced watches a directory and calls the callback once for
+	// every burst of changes. The callback is called window after the first change
+	// of a burst, any changes within that window are coalesced into the same call.
+	// This method only returns if there is an error
+	WatchRecursiveDebounced(directory string, window time.Duration, onChange func()) error
+
+	// SyncWatch calls the callback for every key currently in the directory, then
+	// watches the directory and calls the callback everytime something changes.
+	// No changes are missed between listing the directory and watching it.
+	// This method only returns if there is an error
+	SyncWatch(directory string, onChangeCallback OnChangeCallback) error
+
+	// WaitForKey blocks until the key exists and returns its value.
+	// This method only returns if the key exists, there is an error
+	// or the context is done
+	WaitForKey(ctx context.Context, key string) (string, error)
+
+	// WaitForValue blocks until the key is set to the expected value.
+	// This method only returns if the value matches, there is an error
+	// or the context is done
+	WaitForValue(ctx context.Context, key, expected string) error
+}
+
+// Admin inspects the etcd cluster
+type Admin interface {
+	// Ping performs a round trip to the cluster and returns
+	// an error if etcd could not be reached
+	Ping() error
+
+	// IsHealthy returns true if Ping succeeds
+	IsHealthy() bool
+
+	// Members returns the members of the etcd cluster
+	Members() ([]Member, error)
+
+	// Leader returns the current leader of the etcd cluster
+	Leader() (Member, error)
+
+	// ClusterHealth checks the health endpoint of every member
+	// of the etcd cluster
+	ClusterHealth() (Health, error)
+
+	// Endpoints returns the endpoints the client is currently using
+	Endpoints() []string
+}
+
+// EtcdClient interface lets your Get/Set from Etcd. It is the union of
+// the smaller interfaces, so consumers can depend on only what they use
+type EtcdClient interface {
+	KeyReader
+	KeyWriter
+	DirManager
+	Watcher
+	Admin
+
+	// NewSession creates a session directory that expires after ttl
+	// unless it is refreshed, and starts refreshing it
+	NewSession(ttl time.Duration) (*Session, error)
+
+	// Bind reads every key under prefix and stores the values in the fields of
+	// the struct out points to, see SimpleEtcdClient.Bind for how keys map to
+	// fields
+	Bind(prefix string, out interface{}) error
+
+	// BindAndWatch binds prefix to the struct out points to like Bind, then keeps
+	// the struct up to date as keys under prefix change, calling onUpdate after
+	// each update. Call stop to stop watching
+	BindAndWatch(prefix string, out interface{}, onUpdate func()) (stop func(), err error)
+
+	// Raw returns the underlying etcd client, for anything this package
+	// does not expose
+	Raw() client.Client
+
+	// RawKeysAPI returns the KeysAPI the client uses. Requests made
+	// through it are still reported to the client's hooks
+	RawKeysAPI() client.KeysAPI
+
+	// GetResponse gets a key in Etcd and returns the full response
+	GetResponse(key string, opts *client.GetOptions) (*client.Response, error)
+
+	// SetResponse sets a key in Etcd and returns the full response
+	SetResponse(key, value string, opts *client.SetOptions) (*client.Response, error)
+
+	// WithContext returns a client that makes its requests with ctx, so
+	// they are cancelled with ctx and carry its values, such as a trace.
+	// The returned client shares its connections with this one
+	WithContext(ctx context.Context) EtcdClient
+
+	// Close cancels any in-flight requests and watches and closes idle
+	// connections. The client cannot be used after being closed
+	Close() error
 }
 
 // OnChangeCallback is used for passing callbacks to
 // WatchRecursive
 type OnChangeCallback func(key, newValue string)
 
-// SimpleEtcdClient implements EtcdClient
+// OnIndexedChangeCallback is used for passing callbacks to
+// WatchRecursiveFrom
+type OnIndexedChangeCallback func(key, newValue string, index uint64)
+
+const srvScheme = "srv://"
+
+const unixScheme = "unix://"
+
+// unixEndpoint is the endpoint used for unix sockets, the
+// transport dials the socket whatever the host is
+const unixEndpoint = "http://unix"
+
+// SimpleEtcdClient implements EtcdClient. It is safe for concurrent use, one
+// client can be shared by every goroutine: its fields are never changed after
+// Dial, the state kept by options such as the rate limiter, circuit breaker
+// and singleflight is guarded by their own locks, and every watch keeps its
+// index locally. Watch callbacks are called from the goroutine running the
+// watch, one event at a time, unless WithWatchBuffer or WithWatchWorkers is
+// used, see their documentation
 type SimpleEtcdClient struct {
-	etcd client.Client
+	etcd    client.Client
+	options *options
+	ctx     context.Context
+	root    context.Context
+	cancel  context.CancelFunc
+
+	// background tracks the goroutines the client runs on its
+	// own, such as heartbeats, so Shutdown can wait for them
+	background *sync.WaitGroup
 }
 
-// Dial constructs a new EtcdClient
-func Dial(etcdURI string) (EtcdClient, error) {
-	etcd, err := client.New(client.Config{
-		Endpoints: []string{etcdURI},
-	})
+// Dial constructs a new EtcdClient. If etcdURI is of the form
+// "srv://<domain>" the endpoints are discovered via DNS SRV records.
+// If it is of the form "unix://<path>" the client connects to the
+// unix socket at path
+func Dial(etcdURI string, opts ...Option) (EtcdClient, error) {
+	config := &options{
+		etcd: client.Config{
+			Endpoints: []string{etcdURI},
+			Transport: newTransport(),
+		},
+		freezes: &freezeCache{},
+	}
+	if strings.HasPrefix(etcdURI, srvScheme) {
+		config.srvDomain = strings.TrimPrefix(etcdURI, srvScheme)
+	}
+	if strings.HasPrefix(etcdURI, unixScheme) {
+		config.etcd.Endpoints = []string{unixEndpoint}
+		config.etcd.Transport = newUnixTransport(strings.TrimPrefix(etcdURI, unixScheme))
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.err != nil {
+		return nil, config.err
+	}
+	if config.proxy != nil && config.replay == nil {
+		transport, ok := config.etcd.Transport.(*http.Transport)
+		if !ok {
+			return nil, fmt.Errorf("WithProxy requires an *http.Transport, got %T", config.etcd.Transport)
+		}
+		transport.Proxy = http.ProxyURL(config.proxy)
+	}
+	config.wrapTransport()
+
+	if config.fallback != nil {
+		if err := config.fallback.load(); err != nil {
+			return nil, fmt.Errorf("Failed to load fallback snapshot %v: %v", config.fallback.path, err)
+		}
+	}
+
+	if config.srvDomain != "" {
+		endpoints, err := client.NewSRVDiscover().Discover(config.srvDomain)
+		if err != nil {
+			return nil, err
+		}
+		config.etcd.Endpoints = endpoints
+	}
+
+	etcd, err := client.New(config.etcd)
 	if err != nil {
 		return nil, err
 	}
-	return &SimpleEtcdClient{etcd}, nil
+
+	if config.failover != nil {
+		if err := config.failover.connect(config.etcd); err != nil {
+			return nil, err
+		}
+	}
+
+	root, cancel := context.WithCancel(context.Background())
+	etcdClient := &SimpleEtcdClient{etcd, config, root, root, cancel, &sync.WaitGroup{}}
+
+	if config.fallback != nil {
+		etcdClient.goBackground(func() {
+			config.fallback.run(etcdClient)
+		})
+	}
+
+	if config.autoSync > 0 {
+		etcdClient.goBackground(func() {
+			etcdClient.autoSync(config.autoSync)
+		})
+	}
+	return etcdClient, nil
+}
+
+// WithContext returns a client that makes its requests with ctx, so
+// they are cancelled with ctx and carry its values, such as a trace.
+// The returned client shares its connections with this one
+func (etcdClient *SimpleEtcdClient) WithContext(ctx context.Context) EtcdClient {
+	withContext := *etcdClient
+	withContext.ctx = ctx
+	return &withContext
+}
+
+// Close cancels any in-flight requests and watches and closes idle
+// connections, including those of clients returned by WithContext.
+// The client cannot be used after being closed
+func (etcdClient *SimpleEtcdClient) Close() error {
+	etcdClient.cancel()
+
+	if transport, ok := etcdClient.options.etcd.Transport.(idleConnectionCloser); ok {
+		transport.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Shutdown closes the client and waits for the goroutines it started in
+// the background, such as session heartbeats and BindAndWatch watches, to
+// stop. If ctx is done first, its error is returned
+func (etcdClient *SimpleEtcdClient) Shutdown(ctx context.Context) error {
+	etcdClient.Close()
+
+	stopped := make(chan struct{})
+	go func() {
+		etcdClient.background.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Go runs fn in a goroutine Shutdown waits for, so packages built on the
+// client can run watches and heartbeats that Shutdown stops as well. ctx
+// is done once the client is closed, fn must return then. If the client
+// is already closed, fn is not run
+func (etcdClient *SimpleEtcdClient) Go(fn func(ctx context.Context)) {
+	etcdClient.goBackground(func() {
+		fn(etcdClient.root)
+	})
+}
+
+// Shutdowner is implemented by clients that can wait for the
+// goroutines they run in the background, like SimpleEtcdClient
+type Shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// shutdown shuts down the client, or closes it if it is not a Shutdowner
+func shutdown(ctx context.Context, etcdClient EtcdClient) error {
+	if shutdowner, ok := etcdClient.(Shutdowner); ok {
+		return shutdowner.Shutdown(ctx)
+	}
+	return etcdClient.Close()
+}
+
+// goBackground runs fn in a goroutine Shutdown waits for. fn must
+// return once the client is closed
+func (etcdClient *SimpleEtcdClient) goBackground(fn func()) {
+	if etcdClient.root.Err() != nil {
+		return
+	}
+
+	etcdClient.background.Add(1)
+	go func() {
+		defer etcdClient.background.Done()
+		fn()
+	}()
 }
 
 // Del deletes a key from Etcd
 func (etcdClient *SimpleEtcdClient) Del(key string) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	_, err := api.Delete(context.Background(), key, nil)
+	api := etcdClient.keysAPI()
+	_, err := api.Delete(etcdClient.ctx, key, nil)
 	if err != nil {
-		if client.IsKeyNotFound(err) {
+		if isKeyNotFound(err) {
 			return nil
 		}
 	}
@@ -75,10 +465,10 @@ func (etcdClient *SimpleEtcdClient) Del(key string) error {
 
 // DelDir deletes a dir from Etcd
 func (etcdClient *SimpleEtcdClient) DelDir(key string) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	_, err := api.Delete(context.Background(), key, &client.DeleteOptions{Dir: true, Recursive: true})
+	api := etcdClient.keysAPI()
+	_, err := api.Delete(etcdClient.ctx, key, &client.DeleteOptions{Dir: true, Recursive: true})
 	if err != nil {
-		if client.IsKeyNotFound(err) {
+		if isKeyNotFound(err) {
 			return nil
 		}
 	}
@@ -87,10 +477,25 @@ func (etcdClient *SimpleEtcdClient) DelDir(key string) error {
 
 // Get gets a value in Etcd
 func (etcdClient *SimpleEtcdClient) Get(key string) (string, error) {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	response, err := api.Get(context.Background(), key, nil)
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, key, nil)
 	if err != nil {
-		if client.IsKeyNotFound(err) {
+		if isKeyNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return response.Node.Value, nil
+}
+
+// GetLinearizable gets a value in Etcd with a quorum read, so it
+// always sees the latest committed value, including a Set that
+// just returned. Get may return a stale value from a follower
+func (etcdClient *SimpleEtcdClient) GetLinearizable(key string) (string, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, key, &client.GetOptions{Quorum: true})
+	if err != nil {
+		if isKeyNotFound(err) {
 			return "", nil
 		}
 		return "", err
@@ -100,26 +505,53 @@ func (etcdClient *SimpleEtcdClient) Get(key string) (string, error) {
 
 // Set sets a value in Etcd
 func (etcdClient *SimpleEtcdClient) Set(key, value string) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	_, err := api.Set(context.Background(), key, value, nil)
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, key, value, nil)
 	return err
 }
 
 // UpdateDirWithTTL updates a directory with a ttl value
 func (etcdClient *SimpleEtcdClient) UpdateDirWithTTL(key string, ttl time.Duration) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	_, err := api.Set(context.Background(), key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevExist})
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevExist})
+	return err
+}
+
+// EnsureDirWithTTL creates the directory with a ttl value if it does not
+// exist, or updates its ttl if it does. If the directory is created or
+// expires between the two, it tries again
+func (etcdClient *SimpleEtcdClient) EnsureDirWithTTL(key string, ttl time.Duration) error {
+	api := etcdClient.keysAPI()
+
+	for {
+		_, err := api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevNoExist})
+		if !isNodeExist(err) {
+			return err
+		}
+
+		_, err = api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevExist})
+		if !isKeyNotFound(err) {
+			return err
+		}
+	}
+}
+
+// RefreshTTL extends the ttl of an existing key without changing
+// its value or firing watch events
+func (etcdClient *SimpleEtcdClient) RefreshTTL(key string, ttl time.Duration) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Refresh: true, PrevExist: client.PrevExist})
 	return err
 }
 
 // Ls returns all the keys available in the directory
 func (etcdClient *SimpleEtcdClient) Ls(directory string) ([]string, error) {
-	api := client.NewKeysAPI(etcdClient.etcd)
+	api := etcdClient.keysAPI()
 	options := &client.GetOptions{Sort: true, Recursive: false}
-	response, err := api.Get(context.Background(), directory, options)
+	response, err := api.Get(etcdClient.ctx, directory, options)
 
 	if err != nil {
-		if client.IsKeyNotFound(err) {
+		if isKeyNotFound(err) {
 			return make([]string, 0), nil
 		}
 		return make([]string, 0), err
@@ -130,12 +562,12 @@ func (etcdClient *SimpleEtcdClient) Ls(directory string) ([]string, error) {
 
 // LsRecursive returns all the keys available in the directory, recursively
 func (etcdClient *SimpleEtcdClient) LsRecursive(directory string) ([]string, error) {
-	api := client.NewKeysAPI(etcdClient.etcd)
+	api := etcdClient.keysAPI()
 	options := &client.GetOptions{Sort: true, Recursive: true}
-	response, err := api.Get(context.Background(), directory, options)
+	response, err := api.Get(etcdClient.withDefaultPriority(PriorityBackground).ctx, directory, options)
 
 	if err != nil {
-		if client.IsKeyNotFound(err) {
+		if isKeyNotFound(err) {
 			return make([]string, 0), nil
 		}
 		return make([]string, 0), err
@@ -146,20 +578,53 @@ func (etcdClient *SimpleEtcdClient) LsRecursive(directory string) ([]string, err
 
 // MkDir creates an empty etcd directory
 func (etcdClient *SimpleEtcdClient) MkDir(directory string) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	results, err := api.Get(context.Background(), directory, nil)
+	api := etcdClient.keysAPI()
+	results, err := api.Get(etcdClient.ctx, directory, nil)
 
-	if err != nil && !client.IsKeyNotFound(err) {
+	if err != nil && !isKeyNotFound(err) {
 		return err
 	}
 
-	if err != nil && client.IsKeyNotFound(err) {
-		_, err = api.Set(context.Background(), directory, "", &client.SetOptions{Dir: true, PrevExist: client.PrevIgnore})
+	if err != nil && isKeyNotFound(err) {
+		_, err = api.Set(etcdClient.ctx, directory, "", &client.SetOptions{Dir: true, PrevExist: client.PrevIgnore})
 		return err
 	}
 
 	if !results.Node.Dir {
-		return fmt.Errorf("Refusing to overwrite key/value with a directory: %v", directory)
+		return wrapError("mkdir", directory, ErrNotDir)
+	}
+	return nil
+}
+
+// MkDirAll creates the directory along with any missing parents, like
+// os.MkdirAll. It does nothing if the directory already exists. If the path
+// or one of its parents is a key with a value, a *ConflictError naming it
+// is returned
+func (etcdClient *SimpleEtcdClient) MkDirAll(directory string) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, directory, "", &client.SetOptions{Dir: true, PrevExist: client.PrevNoExist})
+	if err == nil {
+		return nil
+	}
+	if !isNodeExist(err) && !hasErrorCode(err, client.ErrorCodeNotDir) {
+		return err
+	}
+
+	// find the first segment that is not a directory, etcd does not say
+	segments := Split(directory)
+	for i := range segments {
+		key := Join(segments[:i+1]...)
+		node, err := etcdClient.getNode(key)
+		if err != nil {
+			return err
+		}
+		if node == nil {
+			// removed since the write failed, try again
+			return etcdClient.MkDirAll(directory)
+		}
+		if !node.Dir {
+			return &ConflictError{Path: directory, Key: key}
+		}
 	}
 	return nil
 }
@@ -169,21 +634,304 @@ func (etcdClient *SimpleEtcdClient) MkDir(directory string) error {
 // that the thing was changed to.
 // This method only returns if there is an error
 func (etcdClient *SimpleEtcdClient) WatchRecursive(directory string, onChange OnChangeCallback) error {
-	api := client.NewKeysAPI(etcdClient.etcd)
-	afterIndex := uint64(0)
+	return etcdClient.watchRecursive(directory, 0, true, keyValueEvents(onChange))
+}
+
+// WatchRecursiveFrom watches a directory for changes after the given index and
+// calls the callback for each change along with the index of the change. The
+// index can be stored and passed back in later to resume without missing events.
+// Etcd only keeps a limited history, so if the index has been cleared an
+// error is returned and the caller must resync.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchRecursiveFrom(directory string, afterIndex uint64, onChange OnIndexedChangeCallback) error {
+	return etcdClient.watchRecursive(directory, afterIndex, false, indexedEvents(onChange))
+}
+
+// WatchEvents watches a directory for changes after the given index and
+// calls the callback with every change. Like WatchRecursiveFrom, an error
+// is returned if the index has been cleared.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchEvents(directory string, afterIndex uint64, onEvent OnEventCallback) error {
+	return etcdClient.watchRecursive(directory, afterIndex, false, onEvent)
+}
+
+// Index returns the current etcd index
+func (etcdClient *SimpleEtcdClient) Index() (uint64, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, "/", nil)
+	if err != nil {
+		return 0, err
+	}
+	return response.Index, nil
+}
+
+// SyncWatch calls the callback for every key currently in the directory, then
+// watches the directory and calls the callback everytime something changes.
+// No changes are missed between listing the directory and watching it.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) SyncWatch(directory string, onChange OnChangeCallback) error {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Sort: true, Recursive: true}
+	response, err := api.Get(etcdClient.ctx, directory, options)
+
+	if err != nil {
+		etcdErr, ok := etcdError(err)
+		if !ok || etcdErr.Code != client.ErrorCodeKeyNotFound {
+			return err
+		}
+		return etcdClient.watchRecursive(directory, etcdErr.Index, false, keyValueEvents(onChange))
+	}
+
+	for _, node := range flattenNodes(response.Node.Nodes) {
+		onChange(node.Key, node.Value)
+	}
+
+	return etcdClient.watchRecursive(directory, response.Index, false, keyValueEvents(onChange))
+}
+
+// watchRecursive watches the directory starting after afterIndex. If
+// skipCleared is true, the watch jumps ahead to the current index when
+// afterIndex has been cleared from the etcd event history, otherwise
+// the error is returned. Events go through the buffers and workers
+// of WithWatchBuffer and WithWatchWorkers
+func (etcdClient *SimpleEtcdClient) watchRecursive(directory string, afterIndex uint64, skipCleared bool, onEvent OnEventCallback) error {
+	onEvent, stop := etcdClient.options.dispatch(directory, onEvent)
+	defer stop()
+
+	return etcdClient.watchDirect(directory, afterIndex, skipCleared, onEvent)
+}
+
+// watchDirect is watchRecursive calling onEvent from the watch
+// itself, every event is delivered in order and none are dropped
+func (etcdClient *SimpleEtcdClient) watchDirect(directory string, afterIndex uint64, skipCleared bool, onEvent OnEventCallback) error {
+	api := etcdClient.keysAPI()
+	retryPolicy := etcdClient.options.watchRetry
+	attempt := 0
+
+	// a watch from now would miss the changes made while the
+	// watchdog reconnects it, so it starts from the current index
+	if afterIndex == 0 && etcdClient.options.watchdog > 0 {
+		if index, err := etcdClient.Index(); err == nil {
+			afterIndex = index
+		}
+	}
 
 	for {
 		watcher := api.Watcher(directory, &client.WatcherOptions{Recursive: true, AfterIndex: afterIndex})
-		response, err := watcher.Next(context.Background())
+		response, stalled, err := etcdClient.nextEvent(watcher)
+		if stalled {
+			etcdClient.options.log("watch made no progress, reconnecting", "directory", directory, "afterIndex", afterIndex, "period", etcdClient.options.watchdog)
+			if etcdClient.options.metrics != nil {
+				etcdClient.options.metrics.ObserveWatchReconnect(directory)
+			}
+			continue
+		}
 		if err != nil {
-			if shouldIgnoreError(err) {
+			index, cleared := eventIndexCleared(err)
+			if cleared && skipCleared {
+				etcdClient.options.log("skipping cleared watch events", "directory", directory, "afterIndex", afterIndex, "index", index)
+				afterIndex = index
 				continue
 			}
-			return err
+
+			if etcdClient.stopped() {
+				return err
+			}
+
+			if etcdClient.options.onWatchError != nil {
+				etcdClient.options.onWatchError(err)
+			}
+
+			attempt++
+			if cleared || !retryPolicy.shouldRetry(attempt) {
+				return err
+			}
+
+			backoff := retryPolicy.backoff(attempt)
+			etcdClient.options.log("retrying watch", "directory", directory, "attempt", attempt, "backoff", backoff, "err", err)
+			if err := etcdClient.sleep(backoff); err != nil {
+				return err
+			}
+
+			if etcdClient.options.metrics != nil {
+				etcdClient.options.metrics.ObserveWatchReconnect(directory)
+			}
+			continue
 		}
+		attempt = 0
+
+		afterIndex = response.Node.ModifiedIndex
+		onEvent(newEvent(response))
+	}
+}
+
+// nextEvent waits for the next event of the watcher. With
+// WithWatchWatchdog, stalled is true if there was none in the period
+func (etcdClient *SimpleEtcdClient) nextEvent(watcher client.Watcher) (*client.Response, bool, error) {
+	period := etcdClient.options.watchdog
+	if period <= 0 {
+		response, err := watcher.Next(etcdClient.ctx)
+		return response, false, err
+	}
+
+	ctx, cancel := context.WithTimeout(etcdClient.ctx, period)
+	defer cancel()
+
+	response, err := watcher.Next(ctx)
+	stalled := err != nil && ctx.Err() == context.DeadlineExceeded && !etcdClient.stopped()
+	return response, stalled, err
+}
+
+// Ping performs a round trip to the cluster and returns
+// an error if etcd could not be reached
+func (etcdClient *SimpleEtcdClient) Ping() error {
+	ctx, cancel := context.WithTimeout(etcdClient.ctx, client.DefaultRequestTimeout)
+	defer cancel()
+
+	api := etcdClient.keysAPI()
+	_, err := api.Get(ctx, "/", nil)
+	return err
+}
 
+// IsHealthy returns true if Ping succeeds
+func (etcdClient *SimpleEtcdClient) IsHealthy() bool {
+	return etcdClient.Ping() == nil
+}
+
+// WaitForKey blocks until the key exists and returns its value.
+// This method only returns if the key exists, there is an error
+// or the context is done
+func (etcdClient *SimpleEtcdClient) WaitForKey(ctx context.Context, key string) (string, error) {
+	var value string
+	err := etcdClient.waitFor(ctx, key, func(node *client.Node) bool {
+		value = node.Value
+		return true
+	})
+	return value, err
+}
+
+// WaitForValue blocks until the key is set to the expected value.
+// This method only returns if the value matches, there is an error
+// or the context is done
+func (etcdClient *SimpleEtcdClient) WaitForValue(ctx context.Context, key, expected string) error {
+	return etcdClient.waitFor(ctx, key, func(node *client.Node) bool {
+		return node.Value == expected
+	})
+}
+
+// waitFor calls match with the current node, then with every change to
+// the node, until match returns true
+func (etcdClient *SimpleEtcdClient) waitFor(ctx context.Context, key string, match func(node *client.Node) bool) error {
+	api := etcdClient.keysAPI()
+	afterIndex := uint64(0)
+
+	response, err := api.Get(ctx, key, nil)
+	if err != nil {
+		etcdErr, ok := etcdError(err)
+		if !ok || etcdErr.Code != client.ErrorCodeKeyNotFound {
+			return err
+		}
+		afterIndex = etcdErr.Index
+	} else {
+		if match(response.Node) {
+			return nil
+		}
 		afterIndex = response.Index
-		onChange(response.Node.Key, response.Node.Value)
+	}
+
+	watcher := api.Watcher(key, &client.WatcherOptions{AfterIndex: afterIndex})
+	for {
+		response, err := watcher.Next(ctx)
+		if err != nil {
+			return err
+		}
+
+		if isRemoval(response.Action) {
+			continue
+		}
+
+		if match(response.Node) {
+			return nil
+		}
+	}
+}
+
+// withContext returns a context that is done when either ctx
+// is done or the client is closed
+func (etcdClient *SimpleEtcdClient) withContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	merged, cancel := context.WithCancel(ctx)
+	if ctx == etcdClient.root {
+		return merged, cancel
+	}
+
+	go func() {
+		select {
+		case <-etcdClient.root.Done():
+			cancel()
+		case <-merged.Done():
+		}
+	}()
+	return merged, cancel
+}
+
+// stopped returns true if the client has been closed
+// or its context is done
+func (etcdClient *SimpleEtcdClient) stopped() bool {
+	return etcdClient.ctx.Err() != nil || etcdClient.root.Err() != nil
+}
+
+// sleep waits for the duration, returning early with an error
+// if the client is closed or its context is done
+func (etcdClient *SimpleEtcdClient) sleep(duration time.Duration) error {
+	select {
+	case <-etcdClient.ctx.Done():
+		return etcdClient.ctx.Err()
+	case <-etcdClient.root.Done():
+		return etcdClient.root.Err()
+	case <-time.After(duration):
+		return nil
+	}
+}
+
+func isRemoval(action string) bool {
+	switch action {
+	default:
+		return false
+	case "delete", "compareAndDelete", "expire":
+		return true
+	}
+}
+
+type idleConnectionCloser interface {
+	CloseIdleConnections()
+}
+
+// newTransport returns a transport configured like client.DefaultTransport,
+// so Close can release its connections without affecting other clients
+func newTransport() *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		Dial:                newDialer().Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// newUnixTransport returns a transport that sends every
+// request to the unix socket, whatever its host
+func newUnixTransport(socket string) *http.Transport {
+	dialer := newDialer()
+	transport := newTransport()
+	transport.Proxy = nil
+	transport.Dial = func(network, address string) (net.Conn, error) {
+		return dialer.Dial("unix", socket)
+	}
+	return transport
+}
+
+func newDialer() *net.Dialer {
+	return &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
 	}
 }
 
@@ -201,11 +949,32 @@ func nodesToStringSlice(nodes client.Nodes) []string {
 	return keys
 }
 
-func shouldIgnoreError(err error) bool {
-	switch err := err.(type) {
-	default:
-		return false
-	case *client.Error:
-		return err.Code == client.ErrorCodeEventIndexCleared
+func flattenNodes(nodes client.Nodes) client.Nodes {
+	var flattened client.Nodes
+
+	for _, node := range nodes {
+		flattened = append(flattened, node)
+		flattened = append(flattened, flattenNodes(node.Nodes)...)
+	}
+
+	return flattened
+}
+
+// eventIndexCleared returns the current etcd index and true if
+// the error is an ErrorCodeEventIndexCleared
+func eventIndexCleared(err error) (uint64, bool) {
+	etcdErr, ok := etcdError(err)
+	return etcdErr.Index, ok && etcdErr.Code == client.ErrorCodeEventIndexCleared
+}
+
+func keyValueEvents(onChange OnChangeCallback) OnEventCallback {
+	return func(event Event) {
+		onChange(event.Key, event.Value)
+	}
+}
+
+func indexedEvents(onChange OnIndexedChangeCallback) OnEventCallback {
+	return func(event Event) {
+		onChange(event.Key, event.Value, event.Index)
 	}
 }
@@ -0,0 +1,679 @@
+package etcdclient_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"golang.org/x/net/context"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T, opts ...etcdclient.Option) *etcdclient.SimpleEtcdClient {
+	etcd, stop := etcdtest.NewMemory(t, opts...)
+	t.Cleanup(stop)
+	return etcd.(*etcdclient.SimpleEtcdClient)
+}
+
+// setLater sets the key after a short delay, so the caller is
+// already waiting or watching when it changes. The test waits
+// for the Set before the client is closed
+func setLater(t *testing.T, etcdClient *etcdclient.SimpleEtcdClient, key, value string) {
+	done := make(chan struct{})
+	t.Cleanup(func() { <-done })
+	go func() {
+		defer close(done)
+		time.Sleep(50 * time.Millisecond)
+		if err := etcdClient.Set(key, value); err != nil {
+			t.Errorf("Set(%v) returned %v", key, err)
+		}
+	}()
+}
+
+func TestWaitForKey(t *testing.T) {
+	etcdClient := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	setLater(t, etcdClient, "/wait/key", "value")
+	value, err := etcdClient.WaitForKey(ctx, "/wait/key")
+	if err != nil || value != "value" {
+		t.Errorf("WaitForKey returned %q, %v, expected \"value\"", value, err)
+	}
+
+	// an existing key returns right away
+	value, err = etcdClient.WaitForKey(ctx, "/wait/key")
+	if err != nil || value != "value" {
+		t.Errorf("WaitForKey of an existing key returned %q, %v", value, err)
+	}
+}
+
+func TestWaitForValue(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/wait/key", "starting"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	setLater(t, etcdClient, "/wait/key", "ready")
+	if err := etcdClient.WaitForValue(ctx, "/wait/key", "ready"); err != nil {
+		t.Errorf("WaitForValue returned %v", err)
+	}
+}
+
+func TestWaitForKeyStopsWithTheContext(t *testing.T) {
+	etcdClient := dial(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := etcdClient.WaitForKey(ctx, "/never"); err == nil {
+		t.Error("WaitForKey of a missing key returned no error once the context was done")
+	}
+}
+
+// receive returns the next value sent on the channel, failing
+// the test if there is none within 5 seconds
+func receive(t *testing.T, values <-chan string) string {
+	t.Helper()
+	select {
+	case value := <-values:
+		return value
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for a change")
+		return ""
+	}
+}
+
+func TestSyncWatchReplaysExistingKeys(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/sync/existing", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	changes := make(chan string, 10)
+	go etcdClient.SyncWatch("/sync", func(key, newValue string) {
+		changes <- key + "=" + newValue
+	})
+
+	if change := receive(t, changes); change != "/sync/existing=1" {
+		t.Errorf("SyncWatch replayed %v, expected /sync/existing=1", change)
+	}
+	setLater(t, etcdClient, "/sync/new", "2")
+	if change := receive(t, changes); change != "/sync/new=2" {
+		t.Errorf("SyncWatch saw %v, expected /sync/new=2", change)
+	}
+}
+
+func TestSyncWatchOfAMissingDirectory(t *testing.T) {
+	etcdClient := dial(t)
+
+	changes := make(chan string, 10)
+	go etcdClient.SyncWatch("/missing", func(key, newValue string) {
+		changes <- key + "=" + newValue
+	})
+	setLater(t, etcdClient, "/missing/key", "value")
+	if change := receive(t, changes); change != "/missing/key=value" {
+		t.Errorf("SyncWatch saw %v, expected /missing/key=value", change)
+	}
+}
+
+func TestWatchRecursiveFromResumesAfterTheIndex(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/resume/first", "1"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+	// made while nothing watches, then seen by resuming from index
+	if err := etcdClient.Set("/resume/second", "2"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	changes := make(chan string, 10)
+	go etcdClient.WatchRecursiveFrom("/resume", index, func(key, newValue string, changeIndex uint64) {
+		if changeIndex <= index {
+			t.Errorf("Got the change %v at %v, before the index %v", key, changeIndex, index)
+		}
+		changes <- key
+	})
+	if key := receive(t, changes); key != "/resume/second" {
+		t.Errorf("WatchRecursiveFrom saw %v, expected /resume/second", key)
+	}
+}
+
+func TestWatchRecursiveFromAClearedIndex(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/cleared/key", "0"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+	for i := 0; i <= etcdtest.MemoryHistory; i++ {
+		if err := etcdClient.Set("/cleared/key", "1"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	err = etcdClient.WatchRecursiveFrom("/cleared", index, func(key, newValue string, index uint64) {})
+	var etcdErr client.Error
+	if !errors.As(err, &etcdErr) || etcdErr.Code != client.ErrorCodeEventIndexCleared {
+		t.Errorf("WatchRecursiveFrom returned %v, expected the index to be cleared", err)
+	}
+}
+
+func TestCloseStopsWatchesAndRequests(t *testing.T) {
+	etcdClient := dial(t)
+	withContext := etcdClient.WithContext(context.Background())
+
+	watched := make(chan error, 2)
+	go func() {
+		watched <- etcdClient.WatchRecursive("/closed", func(key, newValue string) {})
+	}()
+	go func() {
+		watched <- withContext.WatchRecursive("/closed", func(key, newValue string) {})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := etcdClient.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-watched:
+		case <-time.After(5 * time.Second):
+			t.Fatal("A watch did not return after Close")
+		}
+	}
+
+	if err := etcdClient.Set("/closed/key", "value"); err == nil {
+		t.Error("Set after Close returned no error")
+	}
+	if _, err := withContext.Get("/closed/key"); err == nil {
+		t.Error("Get from a WithContext client after Close returned no error")
+	}
+}
+
+func TestPingAndIsHealthy(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	etcdClient, err := server.Client(etcdclient.WithHeaderTimeoutPerRequest(time.Second))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+
+	if err := etcdClient.Ping(); err != nil {
+		t.Errorf("Ping returned %v", err)
+	}
+	if !etcdClient.IsHealthy() {
+		t.Error("IsHealthy returned false")
+	}
+
+	server.Stop()
+	if err := etcdClient.Ping(); err == nil {
+		t.Error("Ping of a stopped server returned no error")
+	}
+	if etcdClient.IsHealthy() {
+		t.Error("IsHealthy of a stopped server returned true")
+	}
+}
+
+func TestDialWithAnSRVDomainWithoutRecords(t *testing.T) {
+	// .invalid never resolves, so there are no records to connect to
+	if _, err := etcdclient.Dial("srv://example.invalid"); err == nil {
+		t.Error("Dial of a domain without SRV records returned no error")
+	}
+}
+
+func TestRefreshTTLDoesNotNotifyWatches(t *testing.T) {
+	etcdClient := dial(t)
+	result, err := etcdClient.SetWithOptions("/refresh/key", "value", etcdclient.SetOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+
+	if err := etcdClient.RefreshTTL("/refresh/key", time.Hour); err != nil {
+		t.Fatalf("RefreshTTL returned %v", err)
+	}
+	if err := etcdClient.Set("/refresh/other", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	keys := make(chan string, 10)
+	go etcdClient.WatchEvents("/refresh", result.ModifiedIndex, func(event etcdclient.Event) {
+		keys <- event.Key
+	})
+	if key := receive(t, keys); key != "/refresh/other" {
+		t.Errorf("The first event after the Set is for %v, expected the refresh not to fire one", key)
+	}
+
+	if value, err := etcdClient.Get("/refresh/key"); err != nil || value != "value" {
+		t.Errorf("Get after RefreshTTL returned %q, %v, expected the value kept", value, err)
+	}
+	if err := etcdClient.RefreshTTL("/refresh/missing", time.Hour); !errors.Is(err, etcdclient.ErrKeyNotFound) {
+		t.Errorf("RefreshTTL of a missing key returned %v, expected ErrKeyNotFound", err)
+	}
+}
+
+func TestEnsureDirWithTTL(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.UpdateDirWithTTL("/ensured", time.Second); err == nil {
+		t.Error("UpdateDirWithTTL of a missing directory returned no error")
+	}
+	if err := etcdClient.EnsureDirWithTTL("/ensured", time.Second); err != nil {
+		t.Fatalf("EnsureDirWithTTL of a missing directory returned %v", err)
+	}
+	if err := etcdClient.Set("/ensured/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.EnsureDirWithTTL("/ensured", time.Hour); err != nil {
+		t.Fatalf("EnsureDirWithTTL of an existing directory returned %v", err)
+	}
+
+	time.Sleep(2 * time.Second)
+	if value, err := etcdClient.Get("/ensured/key"); err != nil || value != "value" {
+		t.Errorf("Get returned %q, %v, expected the directory kept with the new ttl", value, err)
+	}
+
+	etcdClient.Set("/ensured/key", "value")
+	if err := etcdClient.EnsureDirWithTTL("/ensured/key", time.Hour); err == nil {
+		t.Error("EnsureDirWithTTL of a key with a value returned no error")
+	}
+}
+
+func TestEnsureDirWithTTLConcurrently(t *testing.T) {
+	etcdClient := dial(t)
+
+	errs := make(chan error, 10)
+	for i := 0; i < cap(errs); i++ {
+		go func() {
+			errs <- etcdClient.EnsureDirWithTTL("/ensured", time.Hour)
+		}()
+	}
+	for i := 0; i < cap(errs); i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("EnsureDirWithTTL returned %v", err)
+		}
+	}
+}
+
+func TestMkDirAll(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.MkDirAll("/mkdir/a/b/c"); err != nil {
+		t.Fatalf("MkDirAll returned %v", err)
+	}
+	if err := etcdClient.MkDirAll("/mkdir/a/b/c"); err != nil {
+		t.Errorf("MkDirAll of an existing directory returned %v", err)
+	}
+	if keys, err := etcdClient.Ls("/mkdir/a/b"); err != nil || !reflect.DeepEqual(keys, []string{"/mkdir/a/b/c"}) {
+		t.Errorf("Ls returned %v, %v, expected the created directory", keys, err)
+	}
+
+	etcdClient.Set("/mkdir/a/key", "value")
+	for _, directory := range []string{"/mkdir/a/key", "/mkdir/a/key/b/c"} {
+		err := etcdClient.MkDirAll(directory)
+		var conflict *etcdclient.ConflictError
+		if !errors.As(err, &conflict) || conflict.Path != directory || conflict.Key != "/mkdir/a/key" {
+			t.Errorf("MkDirAll(%v) returned %v, expected a ConflictError naming /mkdir/a/key", directory, err)
+		}
+		if !errors.Is(err, etcdclient.ErrNotDir) {
+			t.Errorf("MkDirAll(%v) returned %v, expected it to match ErrNotDir", directory, err)
+		}
+	}
+}
+
+// recordedGets returns the urls of the gets under directory in the recording
+func recordedGets(recording *etcdclient.Recording, directory string) []string {
+	var urls []string
+	for _, interaction := range recording.Interactions {
+		if interaction.Method == "GET" && strings.Contains(interaction.URL, "/v2/keys"+directory) {
+			urls = append(urls, interaction.URL)
+		}
+	}
+	return urls
+}
+
+func TestGetLinearizableMakesAQuorumRead(t *testing.T) {
+	recording := &etcdclient.Recording{}
+	etcdClient := dial(t, etcdclient.WithRecorder(recording))
+	if err := etcdClient.Set("/quorum/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if value, err := etcdClient.GetLinearizable("/quorum/key"); err != nil || value != "value" {
+		t.Errorf("GetLinearizable returned %q, %v, expected \"value\"", value, err)
+	}
+	etcdClient.Get("/quorum/key")
+	etcdClient.Close()
+
+	urls := recordedGets(recording, "/quorum")
+	if len(urls) != 2 || !strings.Contains(urls[0], "quorum=true") || strings.Contains(urls[1], "quorum=true") {
+		t.Errorf("Got the requests %v, expected only GetLinearizable to be a quorum read", urls)
+	}
+}
+
+func TestWithQuorumReads(t *testing.T) {
+	recording := &etcdclient.Recording{}
+	etcdClient := dial(t, etcdclient.WithQuorumReads(), etcdclient.WithRecorder(recording))
+	if err := etcdClient.Set("/quorum/dir/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	keys, err := etcdClient.LsRecursive("/quorum")
+	if expected := []string{"/quorum/dir", "/quorum/dir/key"}; err != nil || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("LsRecursive returned %v, %v, expected %v", keys, err, expected)
+	}
+	etcdClient.Close()
+
+	urls := recordedGets(recording, "/quorum")
+	if len(urls) == 0 {
+		t.Fatal("No gets were recorded")
+	}
+	for _, url := range urls {
+		if !strings.Contains(url, "quorum=true") || !strings.Contains(url, "recursive=true") {
+			t.Errorf("Got the request %v, expected a recursive quorum read", url)
+		}
+	}
+}
+
+// fakeReader is a KeyReader backed by a map, the kind of fake
+// the smaller interfaces let consumers write
+type fakeReader map[string]string
+
+func (reader fakeReader) Get(key string) (string, error)             { return reader[key], nil }
+func (reader fakeReader) GetLinearizable(key string) (string, error) { return reader[key], nil }
+func (reader fakeReader) GetBytes(key string) ([]byte, error)        { return []byte(reader[key]), nil }
+func (reader fakeReader) Index() (uint64, error)                     { return 0, nil }
+func (reader fakeReader) GetMulti(keys []string) (map[string]string, error) {
+	values := make(map[string]string)
+	for _, key := range keys {
+		values[key] = reader[key]
+	}
+	return values, nil
+}
+
+func TestEtcdClientIsTheUnionOfTheSmallerInterfaces(t *testing.T) {
+	var etcd etcdclient.EtcdClient = dial(t)
+	cached, err := etcdclient.NewCachedClient(etcd, "/cached")
+	if err != nil {
+		t.Fatalf("NewCachedClient returned %v", err)
+	}
+	defer cached.Close()
+
+	for _, implementation := range []etcdclient.EtcdClient{etcd, cached} {
+		var (
+			_ etcdclient.KeyReader  = implementation
+			_ etcdclient.KeyWriter  = implementation
+			_ etcdclient.DirManager = implementation
+			_ etcdclient.Watcher    = implementation
+			_ etcdclient.Admin      = implementation
+		)
+		if _, ok := implementation.(etcdclient.Shutdowner); !ok {
+			t.Errorf("%T is not a Shutdowner", implementation)
+		}
+	}
+
+	if err := etcd.Set("/config/name", "real"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	readers := map[string]etcdclient.KeyReader{"real": etcd, "fake": fakeReader{"/config/name": "fake"}}
+	for expected, reader := range readers {
+		if values, err := reader.GetMulti([]string{"/config/name"}); err != nil || values["/config/name"] != expected {
+			t.Errorf("GetMulti of the %v reader returned %v, %v", expected, values, err)
+		}
+	}
+}
+
+func TestWithReadOnlyRefusesWrites(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	writer, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer writer.Close()
+	if err := writer.Set("/readonly/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	reader, err := server.Client(etcdclient.WithReadOnly())
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer reader.Close()
+
+	if value, err := reader.Get("/readonly/key"); err != nil || value != "value" {
+		t.Errorf("Get of a read-only client returned %q, %v", value, err)
+	}
+	writes := map[string]func() error{
+		"Set":    func() error { return reader.Set("/readonly/key", "changed") },
+		"Del":    func() error { return reader.Del("/readonly/key") },
+		"DelDir": func() error { return reader.DelDir("/readonly") },
+		"MkDir":  func() error { return reader.MkDir("/readonly/dir") },
+		"Swap": func() error {
+			_, err := reader.(*etcdclient.SimpleEtcdClient).Swap("/readonly/key", "changed")
+			return err
+		},
+		"AddUser": func() error { return reader.(*etcdclient.SimpleEtcdClient).AddUser("user", "password") },
+	}
+	for name, write := range writes {
+		if err := write(); !errors.Is(err, etcdclient.ErrReadOnly) {
+			t.Errorf("%v of a read-only client returned %v, expected ErrReadOnly", name, err)
+		}
+	}
+	if value, _ := writer.Get("/readonly/key"); value != "value" {
+		t.Errorf("The key is %q after the refused writes, expected \"value\"", value)
+	}
+}
+
+// countingTransport counts the requests it sends
+type countingTransport struct {
+	*http.Transport
+	requests int64
+}
+
+func (transport *countingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&transport.requests, 1)
+	return transport.Transport.RoundTrip(request)
+}
+
+func TestWithTransport(t *testing.T) {
+	transport := &countingTransport{Transport: &http.Transport{MaxIdleConnsPerHost: 100}}
+	etcdClient := dial(t, etcdclient.WithTransport(transport))
+
+	if err := etcdClient.Set("/transport/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if atomic.LoadInt64(&transport.requests) == 0 {
+		t.Error("The request was not sent with the transport")
+	}
+}
+
+func TestWithHeaderTimeoutPerRequestDoesNotApplyToWatches(t *testing.T) {
+	etcdClient := dial(t, etcdclient.WithHeaderTimeoutPerRequest(50*time.Millisecond))
+
+	values := make(chan string, 1)
+	go etcdClient.WatchRecursive("/timeout", func(key, newValue string) {
+		values <- newValue
+	})
+	// wait past the timeout before the change
+	time.Sleep(200 * time.Millisecond)
+	if err := etcdClient.Set("/timeout/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value := receive(t, values); value != "value" {
+		t.Errorf("The watch got %q, expected \"value\"", value)
+	}
+}
+
+func TestDialAUnixSocket(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	target, err := url.Parse(server.URI)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+
+	// the memory server listens on tcp, so proxy the socket to it
+	socket := filepath.Join(t.TempDir(), "etcd.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen returned %v", err)
+	}
+	proxy := &http.Server{Handler: httputil.NewSingleHostReverseProxy(target)}
+	go proxy.Serve(listener)
+	defer proxy.Close()
+
+	etcdClient, err := etcdclient.Dial("unix://" + socket)
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer etcdClient.Close()
+	if err := etcdClient.Set("/unix/key", "value"); err != nil {
+		t.Fatalf("Set through the socket returned %v", err)
+	}
+	if server.Index() == 0 {
+		t.Error("The Set did not reach the server")
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	target, err := url.Parse(server.URI)
+	if err != nil {
+		t.Fatalf("Parse returned %v", err)
+	}
+
+	var proxied int64
+	forward := httputil.NewSingleHostReverseProxy(target)
+	proxy := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		atomic.AddInt64(&proxied, 1)
+		forward.ServeHTTP(writer, request)
+	}))
+	defer proxy.Close()
+
+	etcdClient, err := etcdclient.Dial(server.URI, etcdclient.WithProxy(proxy.URL))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer etcdClient.Close()
+	if err := etcdClient.Set("/proxied/key", "value"); err != nil {
+		t.Fatalf("Set through the proxy returned %v", err)
+	}
+	if atomic.LoadInt64(&proxied) == 0 {
+		t.Error("The Set did not go through the proxy")
+	}
+}
+
+func TestWithProxyRejectsUnsupportedSchemes(t *testing.T) {
+	for _, proxyURL := range []string{"ftp://proxy:21", "://invalid"} {
+		if _, err := etcdclient.Dial("http://127.0.0.1:2379", etcdclient.WithProxy(proxyURL)); err == nil {
+			t.Errorf("Dial with the proxy %v returned no error", proxyURL)
+		}
+	}
+}
+
+func TestShutdownWaitsForTheBackgroundGoroutines(t *testing.T) {
+	etcdClient := dial(t)
+
+	var stopped int64
+	etcdClient.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		atomic.StoreInt64(&stopped, 1)
+	})
+
+	if err := etcdClient.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown returned %v", err)
+	}
+	if atomic.LoadInt64(&stopped) != 1 {
+		t.Error("Shutdown returned before the goroutine stopped")
+	}
+
+	etcdClient.Go(func(ctx context.Context) {
+		t.Error("Go ran fn on a client that was shut down")
+	})
+}
+
+func TestShutdownReturnsWhenItsContextIsDone(t *testing.T) {
+	etcdClient := dial(t)
+	release := make(chan struct{})
+	etcdClient.Go(func(ctx context.Context) {
+		<-release
+	})
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := etcdClient.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown with a goroutine that does not stop returned %v, expected the error of its context", err)
+	}
+}
+
+func TestWithValidatorRejectsInvalidValues(t *testing.T) {
+	invalid := errors.New("invalid JSON")
+	var checked []string
+	etcdClient := dial(t,
+		etcdclient.WithValidator("/config", func(key, value string) error {
+			checked = append(checked, key)
+			if !json.Valid([]byte(value)) {
+				return invalid
+			}
+			return nil
+		}),
+		etcdclient.WithValidator("/config/small", func(key, value string) error {
+			if len(value) > 10 {
+				return errors.New("too long")
+			}
+			return nil
+		}),
+		// values are checked before they are compressed
+		etcdclient.WithCompression(1),
+	)
+
+	if err := etcdClient.Set("/config/app", "{not json"); !errors.Is(err, invalid) {
+		t.Errorf("Set of an invalid value returned %v, expected the validator's error", err)
+	}
+	if value, _ := etcdClient.Get("/config/app"); value != "" {
+		t.Errorf("A rejected value was written as %q", value)
+	}
+	if err := etcdClient.Set("/config/app", `{"valid": true}`); err != nil {
+		t.Errorf("Set of a valid value returned %v", err)
+	}
+	if err := etcdClient.Set("/config/small/key", `{"valid": true}`); err == nil {
+		t.Error("Set of a value one of two validators rejects returned no error")
+	}
+
+	for _, key := range []string{"/configuration/key", "/other"} {
+		if err := etcdClient.Set(key, "{not json"); err != nil {
+			t.Errorf("Set(%v) outside the prefix returned %v", key, err)
+		}
+	}
+	if err := etcdClient.MkDir("/config/dir"); err != nil {
+		t.Errorf("MkDir under the prefix returned %v, expected directories not to be checked", err)
+	}
+	if expected := []string{"/config/app", "/config/app", "/config/small/key"}; !reflect.DeepEqual(checked, expected) {
+		t.Errorf("The validator checked %v, expected %v", checked, expected)
+	}
+}
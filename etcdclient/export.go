@@ -0,0 +1,149 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Export is the document produced by Export and consumed by Import
+type Export struct {
+	// Directory is the directory that was exported
+	Directory string `json:"directory"`
+
+	// Nodes holds every key and directory that was exported,
+	// parent directories always come before their children
+	Nodes []ExportedNode `json:"nodes"`
+}
+
+// ExportedNode is a single key or directory in an Export
+type ExportedNode struct {
+	// Key is relative to the exported directory
+	Key   string `json:"key"`
+	Dir   bool   `json:"dir,omitempty"`
+	Value string `json:"value,omitempty"`
+
+	// TTL is the remaining time to live in seconds, 0 if the node does not expire
+	TTL int64 `json:"ttl,omitempty"`
+}
+
+// Export returns a JSON document with every key, value, directory
+// and TTL in the directory, recursively
+func (etcdClient *SimpleEtcdClient) Export(directory string) ([]byte, error) {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Sort: true, Recursive: true}
+	response, err := api.Get(etcdClient.ctx, directory, options)
+
+	export := Export{Directory: directory, Nodes: make([]ExportedNode, 0)}
+	if err != nil {
+		if isKeyNotFound(err) {
+			return json.Marshal(export)
+		}
+		return nil, err
+	}
+
+	// etcd may name the root directory "" or "/"
+	parent := strings.TrimSuffix(response.Node.Key, "/")
+	for _, node := range flattenNodes(response.Node.Nodes) {
+		export.Nodes = append(export.Nodes, ExportedNode{
+			Key:   strings.TrimPrefix(node.Key, parent),
+			Dir:   node.Dir,
+			Value: node.Value,
+			TTL:   node.TTL,
+		})
+	}
+	return json.Marshal(export)
+}
+
+// Import writes a document produced by Export into the directory.
+// Existing keys are only replaced if overwrite is true
+func (etcdClient *SimpleEtcdClient) Import(directory string, data []byte, overwrite bool) error {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return err
+	}
+	return etcdClient.importNodes(directory, export.Nodes, overwrite, nil)
+}
+
+// ProgressFunc is called by Backup and Restore with the
+// number of keys and directories done so far
+type ProgressFunc func(done int)
+
+// Backup writes every key, value, directory and TTL in the keyspace to w,
+// in the format of Export. Directories are fetched one at a time, so large
+// keyspaces do not need a single huge request, and progress, which may be
+// nil, is called after every node. Hidden keys, whose names start with an
+// underscore, are not listed by etcd and are not backed up
+func (etcdClient *SimpleEtcdClient) Backup(w io.Writer, progress ProgressFunc) error {
+	export := Export{Directory: "/", Nodes: make([]ExportedNode, 0)}
+	err := etcdClient.walkNodes("/", func(node *client.Node) error {
+		export.Nodes = append(export.Nodes, ExportedNode{
+			Key:   node.Key,
+			Dir:   node.Dir,
+			Value: node.Value,
+			TTL:   node.TTL,
+		})
+		if progress != nil {
+			progress(len(export.Nodes))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(w).Encode(export)
+}
+
+// Restore writes a backup made by Backup, or a document made by Export,
+// into the keyspace. Existing keys are only replaced if overwrite is true.
+// progress, which may be nil, is called after every node
+func (etcdClient *SimpleEtcdClient) Restore(r io.Reader, overwrite bool, progress ProgressFunc) error {
+	var export Export
+	if err := json.NewDecoder(r).Decode(&export); err != nil {
+		return err
+	}
+	return etcdClient.importNodes(export.Directory, export.Nodes, overwrite, progress)
+}
+
+func (etcdClient *SimpleEtcdClient) importNodes(directory string, nodes []ExportedNode, overwrite bool, progress ProgressFunc) error {
+	for i, node := range nodes {
+		key := path.Join(directory, node.Key)
+		ttl := time.Duration(node.TTL) * time.Second
+
+		if err := etcdClient.importNode(key, node, ttl, overwrite); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress(i + 1)
+		}
+	}
+	return nil
+}
+
+func (etcdClient *SimpleEtcdClient) importNode(key string, node ExportedNode, ttl time.Duration, overwrite bool) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, key, node.Value, &client.SetOptions{TTL: ttl, Dir: node.Dir, PrevExist: client.PrevNoExist})
+	if !isNodeExist(err) {
+		return err
+	}
+
+	if !overwrite {
+		return nil
+	}
+
+	if node.Dir {
+		if ttl <= 0 {
+			return nil
+		}
+		_, err = api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevExist})
+		return err
+	}
+
+	_, err = api.Set(etcdClient.ctx, key, node.Value, &client.SetOptions{TTL: ttl})
+	return err
+}
@@ -0,0 +1,172 @@
+package etcdclient_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// decodeExport decodes a document made by Export
+func decodeExport(t *testing.T, data []byte) etcdclient.Export {
+	var export etcdclient.Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		t.Fatalf("Export made invalid JSON: %v", err)
+	}
+	return export
+}
+
+// exportedTTL returns the TTL of the exported key
+func exportedTTL(t *testing.T, export etcdclient.Export, key string) int64 {
+	for _, node := range export.Nodes {
+		if node.Key == key {
+			return node.TTL
+		}
+	}
+	t.Fatalf("%v was not exported", key)
+	return 0
+}
+
+func TestExportAndImport(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/src/a", "/src/dir/b")
+	if err := etcdClient.MkDir("/src/empty"); err != nil {
+		t.Fatalf("MkDir returned %v", err)
+	}
+	if _, err := etcdClient.SetWithOptions("/src/expiring", "value", etcdclient.SetOptions{TTL: time.Minute}); err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+
+	data, err := etcdClient.Export("/src")
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+	if ttl := exportedTTL(t, decodeExport(t, data), "/expiring"); ttl <= 0 {
+		t.Errorf("Exported /expiring with the TTL %v, expected it to expire", ttl)
+	}
+
+	if err := etcdClient.Import("/dst", data, false); err != nil {
+		t.Fatalf("Import returned %v", err)
+	}
+	src, _ := etcdClient.LsRecursive("/src")
+	dst, err := etcdClient.LsRecursive("/dst")
+	if err != nil {
+		t.Fatalf("LsRecursive returned %v", err)
+	}
+	if len(dst) != len(src) {
+		t.Errorf("Imported %v, expected the keys of %v", dst, src)
+	}
+	if value, err := etcdClient.Get("/dst/dir/b"); err != nil || value != "value" {
+		t.Errorf("Get of an imported key returned %q, %v", value, err)
+	}
+	if keys, err := etcdClient.Ls("/dst/empty"); err != nil || len(keys) != 0 {
+		t.Errorf("Ls of an imported empty directory returned %v, %v", keys, err)
+	}
+
+	reexported, err := etcdClient.Export("/dst")
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+	if ttl := exportedTTL(t, decodeExport(t, reexported), "/expiring"); ttl <= 0 {
+		t.Errorf("Import did not keep the TTL of /expiring, it has %v", ttl)
+	}
+}
+
+func TestImportOnlyOverwritesWhenAsked(t *testing.T) {
+	etcdClient := dial(t)
+	data := []byte(`{"directory": "/src", "nodes": [{"key": "/key", "value": "imported"}]}`)
+	if err := etcdClient.Set("/dst/key", "existing"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if err := etcdClient.Import("/dst", data, false); err != nil {
+		t.Fatalf("Import returned %v", err)
+	}
+	if value, _ := etcdClient.Get("/dst/key"); value != "existing" {
+		t.Errorf("Import without overwrite replaced the key with %q", value)
+	}
+
+	if err := etcdClient.Import("/dst", data, true); err != nil {
+		t.Fatalf("Import returned %v", err)
+	}
+	if value, _ := etcdClient.Get("/dst/key"); value != "imported" {
+		t.Errorf("Import with overwrite left the key at %q", value)
+	}
+}
+
+func TestExportOfAMissingDirectory(t *testing.T) {
+	etcdClient := dial(t)
+
+	data, err := etcdClient.Export("/missing")
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+	export := decodeExport(t, data)
+	if expected := (etcdclient.Export{Directory: "/missing", Nodes: []etcdclient.ExportedNode{}}); !reflect.DeepEqual(export, expected) {
+		t.Errorf("Export returned %+v, expected %+v", export, expected)
+	}
+}
+
+func TestExportOfTheRoot(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/key")
+
+	data, err := etcdClient.Export("/")
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+	if nodes := decodeExport(t, data).Nodes; len(nodes) != 1 || nodes[0].Key != "/key" {
+		t.Errorf("Export of / returned %+v, expected /key", nodes)
+	}
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/a", "/dir/b", "/dir/nested/c")
+	if _, err := etcdClient.SetWithOptions("/expiring", "value", etcdclient.SetOptions{TTL: time.Minute}); err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+
+	var backup bytes.Buffer
+	backedUp := 0
+	if err := etcdClient.Backup(&backup, func(done int) { backedUp = done }); err != nil {
+		t.Fatalf("Backup returned %v", err)
+	}
+	// the keys and the /dir and /dir/nested directories
+	if backedUp != 6 {
+		t.Errorf("Backup reported %v nodes done, expected 6", backedUp)
+	}
+
+	restored := dial(t)
+	restoredNodes := 0
+	if err := restored.Restore(&backup, false, func(done int) { restoredNodes = done }); err != nil {
+		t.Fatalf("Restore returned %v", err)
+	}
+	if restoredNodes != backedUp {
+		t.Errorf("Restore reported %v nodes done, expected %v", restoredNodes, backedUp)
+	}
+	for _, key := range []string{"/a", "/dir/b", "/dir/nested/c", "/expiring"} {
+		if value, err := restored.Get(key); err != nil || value != "value" {
+			t.Errorf("Get(%v) of the restored keyspace returned %q, %v", key, value, err)
+		}
+	}
+	data, err := restored.Export("/")
+	if err != nil {
+		t.Fatalf("Export returned %v", err)
+	}
+	if ttl := exportedTTL(t, decodeExport(t, data), "/expiring"); ttl <= 0 {
+		t.Errorf("Restore did not keep the TTL of /expiring, it has %v", ttl)
+	}
+}
+
+func TestRestoreOfAnInvalidBackup(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.Restore(strings.NewReader("not json"), false, nil); err == nil {
+		t.Error("Restore of a backup that is not JSON returned no error")
+	}
+}
@@ -0,0 +1,171 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// WithFallbackSnapshot makes the client save a snapshot of the keys under
+// prefix to the file at snapshotPath every interval, and serve reads under
+// prefix from the latest snapshot when etcd cannot be reached. A snapshot
+// left by a previous run is used until the first one is saved, so a service
+// can start during an outage. Stale reports whether the last read was
+// served from the snapshot
+func WithFallbackSnapshot(snapshotPath, prefix string, interval time.Duration) Option {
+	return func(opts *options) {
+		opts.fallback = &fallbackSnapshot{
+			path:     snapshotPath,
+			prefix:   normalizeKey(prefix),
+			interval: interval,
+			nodes:    make(map[string]ExportedNode),
+		}
+	}
+}
+
+// Stale returns true if the last read was served from the
+// snapshot of WithFallbackSnapshot because etcd was unreachable
+func (etcdClient *SimpleEtcdClient) Stale() bool {
+	fallback := etcdClient.options.fallback
+	return fallback != nil && atomic.LoadInt32(&fallback.stale) == 1
+}
+
+type fallbackSnapshot struct {
+	path     string
+	prefix   string
+	interval time.Duration
+
+	mutex sync.RWMutex
+	nodes map[string]ExportedNode
+
+	// stale is 1 if the last read was served from the snapshot
+	stale int32
+}
+
+// load reads the snapshot saved by a previous run, if any
+func (snapshot *fallbackSnapshot) load() error {
+	data, err := ioutil.ReadFile(snapshot.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return snapshot.update(data)
+}
+
+// update replaces the snapshot with an Export of the prefix
+func (snapshot *fallbackSnapshot) update(data []byte) error {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return err
+	}
+
+	nodes := make(map[string]ExportedNode, len(export.Nodes))
+	for _, node := range export.Nodes {
+		node.Key = path.Join(snapshot.prefix, node.Key)
+		nodes[node.Key] = node
+	}
+
+	snapshot.mutex.Lock()
+	defer snapshot.mutex.Unlock()
+	snapshot.nodes = nodes
+	return nil
+}
+
+// save exports the prefix and writes it to the snapshot file
+func (snapshot *fallbackSnapshot) save(etcdClient *SimpleEtcdClient) error {
+	data, err := etcdClient.Export(snapshot.prefix)
+	if err != nil {
+		return err
+	}
+
+	staged, err := ioutil.TempFile(filepath.Dir(snapshot.path), "."+filepath.Base(snapshot.path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+
+	_, err = staged.Write(data)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(staged.Name(), snapshot.path); err != nil {
+		return err
+	}
+	return snapshot.update(data)
+}
+
+// run saves the snapshot every interval until the client is closed
+func (snapshot *fallbackSnapshot) run(etcdClient *SimpleEtcdClient) {
+	for {
+		if err := snapshot.save(etcdClient); err != nil {
+			etcdClient.options.log("saving fallback snapshot failed", "path", snapshot.path, "err", err)
+		}
+		if err := etcdClient.sleep(snapshot.interval); err != nil {
+			return
+		}
+	}
+}
+
+// get serves a read from the snapshot if the read failed because etcd
+// could not be reached and the snapshot has the key
+func (snapshot *fallbackSnapshot) get(key string, opts *client.GetOptions, response *client.Response, err error) (*client.Response, error) {
+	if err == nil || isKeyNotFound(err) {
+		atomic.StoreInt32(&snapshot.stale, 0)
+		return response, err
+	}
+
+	var clientErr *Error
+	if !errors.As(err, &clientErr) || !isUnreachable(clientErr.Err) || clientErr.Err == context.Canceled {
+		return response, err
+	}
+
+	snapshot.mutex.RLock()
+	defer snapshot.mutex.RUnlock()
+
+	key = normalizeKey(key)
+	node, ok := snapshot.nodes[key]
+	if key == snapshot.prefix {
+		node, ok = ExportedNode{Key: key, Dir: true}, true
+	}
+	if !ok {
+		return response, err
+	}
+
+	atomic.StoreInt32(&snapshot.stale, 1)
+	recursive := opts != nil && opts.Recursive
+	return &client.Response{Action: "get", Node: snapshot.node(node, recursive, true)}, nil
+}
+
+// node converts a snapshot node into an etcd node, with its children
+// if it is a directory and children is true. The read lock must be held
+func (snapshot *fallbackSnapshot) node(exported ExportedNode, recursive, children bool) *client.Node {
+	node := &client.Node{Key: exported.Key, Value: exported.Value, Dir: exported.Dir, TTL: exported.TTL}
+	if !exported.Dir || !children {
+		return node
+	}
+
+	for key, child := range snapshot.nodes {
+		if path.Dir(key) == exported.Key && key != exported.Key {
+			node.Nodes = append(node.Nodes, snapshot.node(child, recursive, recursive))
+		}
+	}
+	sort.Slice(node.Nodes, func(i, j int) bool {
+		return node.Nodes[i].Key < node.Nodes[j].Key
+	})
+	return node
+}
@@ -0,0 +1,80 @@
+package etcdclient_test
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// exportedKeys returns the keys in the snapshot file
+func exportedKeys(t *testing.T, snapshot string) []string {
+	var keys []string
+	for _, node := range decodeExport(t, []byte(readFile(t, snapshot))).Nodes {
+		keys = append(keys, node.Key)
+	}
+	return keys
+}
+
+func TestWithFallbackSnapshotServesReadsDuringAnOutage(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+	etcd, err := server.Client(etcdclient.WithFallbackSnapshot(snapshot, "/config", 10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcd.Close()
+	etcdClient := etcd.(*etcdclient.SimpleEtcdClient)
+	setKeys(t, etcdClient, "/config/a", "/config/dir/b", "/other")
+
+	// wait for a snapshot that has the keys
+	deadline := time.Now().Add(5 * time.Second)
+	for readFile(t, snapshot) == "" || !reflect.DeepEqual(exportedKeys(t, snapshot), []string{"/a", "/dir", "/dir/b"}) {
+		if time.Now().After(deadline) {
+			t.Fatalf("The snapshot is %q, expected the keys under /config", readFile(t, snapshot))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if value, err := etcdClient.Get("/config/a"); err != nil || value != "value" || etcdClient.Stale() {
+		t.Fatalf("Get returned %q, %v, stale %v, expected the live value", value, err, etcdClient.Stale())
+	}
+
+	server.Stop()
+	if value, err := etcdClient.Get("/config/dir/b"); err != nil || value != "value" {
+		t.Errorf("Get during an outage returned %q, %v, expected the value in the snapshot", value, err)
+	}
+	if !etcdClient.Stale() {
+		t.Error("A read served from the snapshot is not Stale")
+	}
+	if keys, err := etcdClient.Ls("/config"); err != nil || !reflect.DeepEqual(keys, []string{"/config/a", "/config/dir"}) {
+		t.Errorf("Ls during an outage returned %v, %v, expected the keys in the snapshot", keys, err)
+	}
+	if _, err := etcdClient.Get("/other"); err == nil {
+		t.Error("Get of a key outside the prefix during an outage returned no error")
+	}
+}
+
+func TestWithFallbackSnapshotUsesTheSnapshotOfAPreviousRun(t *testing.T) {
+	snapshot := filepath.Join(t.TempDir(), "snapshot.json")
+	data := `{"directory": "/config", "nodes": [{"key": "/a", "value": "saved"}]}`
+	if err := ioutil.WriteFile(snapshot, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile returned %v", err)
+	}
+	// the client starts during the outage, so it can not replace the snapshot
+	server := etcdtest.StartMemory()
+	server.Stop()
+	etcd, err := server.Client(etcdclient.WithFallbackSnapshot(snapshot, "/config", time.Hour))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcd.Close()
+
+	if value, err := etcd.Get("/config/a"); err != nil || value != "saved" {
+		t.Errorf("Get during an outage returned %q, %v, expected the value saved by the previous run", value, err)
+	}
+}
@@ -0,0 +1,52 @@
+package etcdclient
+
+import (
+	"path"
+	"strings"
+)
+
+// WatchRecursiveFiltered watches a directory like WatchRecursive, but only calls
+// the callback for keys matching the pattern. The pattern is matched against the
+// key relative to the directory, using the syntax of path.Match, so
+// "*/config/*.json" matches "/directory/service/config/app.json".
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchRecursiveFiltered(directory, pattern string, onChange OnChangeCallback) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return err
+	}
+
+	return etcdClient.WatchRecursive(directory, func(key, newValue string) {
+		if matchKey(directory, pattern, key) {
+			onChange(key, newValue)
+		}
+	})
+}
+
+// LsMatching returns all the keys in the directory, recursively, that match
+// the pattern. The pattern is matched like in WatchRecursiveFiltered
+func (etcdClient *SimpleEtcdClient) LsMatching(directory, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return make([]string, 0), err
+	}
+
+	keys, err := etcdClient.LsRecursive(directory)
+	if err != nil {
+		return make([]string, 0), err
+	}
+
+	matching := make([]string, 0)
+	for _, key := range keys {
+		if matchKey(directory, pattern, key) {
+			matching = append(matching, key)
+		}
+	}
+	return matching, nil
+}
+
+// matchKey returns true if key, relative to
+// directory, matches the pattern
+func matchKey(directory, pattern, key string) bool {
+	relative := strings.TrimPrefix(key, "/"+strings.Trim(directory, "/"))
+	matched, _ := path.Match(pattern, strings.TrimPrefix(relative, "/"))
+	return matched
+}
@@ -0,0 +1,40 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLsMatching(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/services/api/config/app.json", "/services/api/config/app.yaml", "/services/web/config/site.json", "/services/api/app.json")
+
+	keys, err := etcdClient.LsMatching("/services", "*/config/*.json")
+	if expected := []string{"/services/api/config/app.json", "/services/web/config/site.json"}; err != nil || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("LsMatching returned %v, %v, expected %v", keys, err, expected)
+	}
+
+	if _, err := etcdClient.LsMatching("/services", "["); err == nil {
+		t.Error("LsMatching with an invalid pattern returned no error")
+	}
+}
+
+func TestWatchRecursiveFiltered(t *testing.T) {
+	etcdClient := dial(t)
+
+	keys := make(chan string, 10)
+	go etcdClient.WatchRecursiveFiltered("/services", "*/config/*.json", func(key, newValue string) {
+		keys <- key
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	setKeys(t, etcdClient, "/services/api/app.json", "/services/api/config/app.yaml", "/services/api/config/app.json")
+	if key := receive(t, keys); key != "/services/api/config/app.json" {
+		t.Errorf("WatchRecursiveFiltered called the callback for %v, expected only matching keys", key)
+	}
+
+	if err := etcdClient.WatchRecursiveFiltered("/services", "[", func(key, newValue string) {}); err == nil {
+		t.Error("WatchRecursiveFiltered with an invalid pattern returned no error")
+	}
+}
@@ -0,0 +1,258 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// FreezeDir is the directory FreezePrefix keeps its markers in
+const FreezeDir = "/_frozen"
+
+// FreezeRetry is how a client waits before reading the frozen prefixes
+// again after it could not read or watch them
+var FreezeRetry = RetryPolicy{MaxRetries: -1, InitialBackoff: time.Second, MaxBackoff: time.Minute}
+
+// Freeze is the marker FreezePrefix places
+type Freeze struct {
+	Prefix   string    `json:"prefix"`
+	Reason   string    `json:"reason"`
+	FrozenAt time.Time `json:"frozenAt"`
+}
+
+// FreezePrefix rejects every write under the prefix, from every client
+// that uses this package, with a *FrozenError that gives the reason, for
+// instance to lock production configuration during an incident. Deleting
+// a directory that holds a frozen prefix is rejected too. The freeze
+// lifts after the ttl, or with UnfreezePrefix if the ttl is 0. Clients
+// watch FreezeDir from their first write, so other clients enforce the
+// freeze once their watch sees it. Clients that cannot read FreezeDir,
+// for instance because their role may not, log it and are not stopped
+func (etcdClient *SimpleEtcdClient) FreezePrefix(prefix, reason string, ttl time.Duration) error {
+	freeze := Freeze{Prefix: normalizeKey(prefix), Reason: reason, FrozenAt: time.Now().UTC()}
+	data, err := json.Marshal(freeze)
+	if err != nil {
+		return err
+	}
+
+	api := etcdClient.keysAPI()
+	_, err = api.Set(etcdClient.ctx, freezeMarkerKey(prefix), string(data), &client.SetOptions{TTL: ttl})
+	if err != nil {
+		return err
+	}
+
+	// the watch catches up soon, but this client's next write must see it
+	etcdClient.options.freezes.add(freeze)
+	return nil
+}
+
+// UnfreezePrefix lifts the freeze of the prefix, prefixes
+// above or below it that were frozen stay frozen
+func (etcdClient *SimpleEtcdClient) UnfreezePrefix(prefix string) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Delete(etcdClient.ctx, freezeMarkerKey(prefix), nil)
+	if err != nil && !isKeyNotFound(err) {
+		return err
+	}
+
+	etcdClient.options.freezes.remove(normalizeKey(prefix))
+	return nil
+}
+
+// FrozenPrefixes returns the prefixes that are frozen
+func (etcdClient *SimpleEtcdClient) FrozenPrefixes() ([]Freeze, error) {
+	freezes, _, err := etcdClient.keysAPI().(*keysAPI).frozenPrefixes(etcdClient.ctx)
+	return freezes, err
+}
+
+// freezeMarkerKey returns the key of the marker of the prefix. Markers
+// are named after the escaped prefix, directly in FreezeDir, so frozen
+// prefixes inside frozen prefixes do not collide, and their names do not
+// start with _, which would hide them from listings and watches of FreezeDir
+func freezeMarkerKey(prefix string) string {
+	return path.Join(FreezeDir, url.PathEscape(normalizeKey(prefix)))
+}
+
+// frozenPrefixes reads every marker in FreezeDir and returns
+// them with the index to watch FreezeDir for changes after
+func (api *keysAPI) frozenPrefixes(ctx context.Context) ([]Freeze, uint64, error) {
+	freezes := make([]Freeze, 0)
+	response, err := api.Get(ctx, FreezeDir, &client.GetOptions{Sort: true})
+	if isKeyNotFound(err) {
+		etcdErr, _ := etcdError(err)
+		return freezes, etcdErr.Index, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, node := range response.Node.Nodes {
+		if node.Dir {
+			continue
+		}
+
+		freeze := Freeze{Reason: node.Value}
+		if json.Unmarshal([]byte(node.Value), &freeze) != nil || freeze.Prefix == "" {
+			prefix, err := url.PathUnescape(path.Base(node.Key))
+			if err != nil {
+				continue
+			}
+			freeze.Prefix = normalizeKey(prefix)
+		}
+		freezes = append(freezes, freeze)
+	}
+	return freezes, response.Index, nil
+}
+
+// checkFrozen returns a *FrozenError if the key is under a frozen prefix,
+// or, if recursive, if a frozen prefix is under the key. The markers can
+// always be written, so a freeze can be lifted. If the frozen prefixes
+// cannot be read, for instance because the client's role may not read
+// FreezeDir, the write is allowed
+func (api *keysAPI) checkFrozen(ctx context.Context, key string, recursive bool) error {
+	key = normalizeKey(key)
+	if key == FreezeDir || isAncestor(FreezeDir, key) {
+		return nil
+	}
+
+	for _, freeze := range api.etcdClient.options.freezes.get(ctx, api.etcdClient) {
+		if key == freeze.Prefix || isAncestor(freeze.Prefix, key) || recursive && isAncestor(key, freeze.Prefix) {
+			return &FrozenError{Key: key, Freeze: freeze}
+		}
+	}
+	return nil
+}
+
+// freezeCache holds the frozen prefixes, kept fresh by a watch of
+// FreezeDir that starts with the first write, so writes do not wait
+// for an extra read
+type freezeCache struct {
+	mutex    sync.Mutex
+	freezes  []Freeze
+	loaded   bool
+	watching bool
+}
+
+// get returns the frozen prefixes. The first writes, until the watch
+// has read them, read them themselves, without holding the lock
+func (cache *freezeCache) get(ctx context.Context, etcdClient *SimpleEtcdClient) []Freeze {
+	cache.mutex.Lock()
+	if !cache.watching {
+		cache.watching = true
+		watchClient := etcdClient.WithContext(etcdClient.root).(*SimpleEtcdClient)
+		etcdClient.goBackground(func() {
+			cache.watch(watchClient)
+		})
+	}
+	freezes, loaded := cache.freezes, cache.loaded
+	cache.mutex.Unlock()
+
+	if loaded {
+		return freezes
+	}
+
+	freezes, _, err := etcdClient.keysAPI().(*keysAPI).frozenPrefixes(ctx)
+	if err != nil {
+		etcdClient.options.log("could not read the frozen prefixes, allowing the write", "err", err)
+		return nil
+	}
+	cache.set(freezes, false)
+	return freezes
+}
+
+// watch reads the frozen prefixes, then reads them again every time
+// something changes in FreezeDir, until the client is closed
+func (cache *freezeCache) watch(etcdClient *SimpleEtcdClient) {
+	attempt := 0
+	for !etcdClient.stopped() {
+		api := etcdClient.keysAPI().(*keysAPI)
+		freezes, index, err := api.frozenPrefixes(etcdClient.ctx)
+		if err == nil {
+			cache.set(freezes, true)
+			err = cache.waitForChange(etcdClient, index)
+		} else {
+			// keep what is known instead of making every write read again
+			cache.set(cache.current(), true)
+		}
+		if err == nil {
+			attempt = 0
+			continue
+		}
+		if etcdClient.stopped() {
+			return
+		}
+
+		attempt++
+		backoff := FreezeRetry.backoff(attempt)
+		etcdClient.options.log("could not watch the frozen prefixes, retrying", "backoff", backoff, "err", err)
+		if etcdClient.sleep(backoff) != nil {
+			return
+		}
+	}
+}
+
+// waitForChange returns nil once something changes
+// in FreezeDir after the index, or the watch error
+func (cache *freezeCache) waitForChange(etcdClient *SimpleEtcdClient, index uint64) error {
+	ctx, cancel := context.WithCancel(etcdClient.ctx)
+	defer cancel()
+
+	watchClient := etcdClient.WithContext(ctx).(*SimpleEtcdClient)
+	err := watchClient.watchRecursive(FreezeDir, index, false, func(event Event) {
+		cancel()
+	})
+	if _, cleared := eventIndexCleared(err); cleared || ctx.Err() != nil && !etcdClient.stopped() {
+		return nil
+	}
+	return err
+}
+
+// set replaces the frozen prefixes. Only the watch marks them loaded,
+// the writes that read them before keep reading them until it does, and
+// what they read is ignored once the watch has
+func (cache *freezeCache) set(freezes []Freeze, loaded bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.loaded && !loaded {
+		return
+	}
+	cache.freezes = freezes
+	cache.loaded = loaded
+}
+
+func (cache *freezeCache) current() []Freeze {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.freezes
+}
+
+// add records a freeze made by this client
+func (cache *freezeCache) add(freeze Freeze) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.freezes = append(cache.withoutPrefix(freeze.Prefix), freeze)
+}
+
+// remove forgets the freeze of the prefix lifted by this client
+func (cache *freezeCache) remove(prefix string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.freezes = cache.withoutPrefix(prefix)
+}
+
+// withoutPrefix returns a copy of the freezes without the one
+// of the prefix, the mutex must be held
+func (cache *freezeCache) withoutPrefix(prefix string) []Freeze {
+	freezes := make([]Freeze, 0, len(cache.freezes)+1)
+	for _, freeze := range cache.freezes {
+		if freeze.Prefix != prefix {
+			freezes = append(freezes, freeze)
+		}
+	}
+	return freezes
+}
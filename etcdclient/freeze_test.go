@@ -0,0 +1,128 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// dialTwice returns two clients of a new etcdtest.MemoryServer,
+// they and the server are stopped when the test ends
+func dialTwice(t *testing.T) (*etcdclient.SimpleEtcdClient, *etcdclient.SimpleEtcdClient) {
+	server := etcdtest.StartMemory()
+	t.Cleanup(func() { server.Stop() })
+
+	clients := make([]*etcdclient.SimpleEtcdClient, 2)
+	for i := range clients {
+		etcd, err := server.Client()
+		if err != nil {
+			t.Fatalf("Client returned %v", err)
+		}
+		t.Cleanup(func() { etcd.Close() })
+		clients[i] = etcd.(*etcdclient.SimpleEtcdClient)
+	}
+	return clients[0], clients[1]
+}
+
+func TestFreezePrefix(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/prod/config/key", "/prod/other")
+
+	if err := etcdClient.FreezePrefix("/prod/config", "incident 42", 0); err != nil {
+		t.Fatalf("FreezePrefix returned %v", err)
+	}
+
+	err := etcdClient.Set("/prod/config/key", "changed")
+	var frozen *etcdclient.FrozenError
+	if !errors.As(err, &frozen) || frozen.Key != "/prod/config/key" || frozen.Prefix != "/prod/config" || frozen.Reason != "incident 42" {
+		t.Errorf("Set under the frozen prefix returned %v, expected a FrozenError with the reason", err)
+	}
+	if err := etcdClient.Del("/prod/config/key"); !errors.Is(err, etcdclient.ErrFrozen) {
+		t.Errorf("Del under the frozen prefix returned %v, expected ErrFrozen", err)
+	}
+	if err := etcdClient.DelDir("/prod"); !errors.Is(err, etcdclient.ErrFrozen) {
+		t.Errorf("DelDir of a directory holding the frozen prefix returned %v, expected ErrFrozen", err)
+	}
+	if err := etcdClient.Set("/prod/other", "changed"); err != nil {
+		t.Errorf("Set outside the frozen prefix returned %v", err)
+	}
+	if err := etcdClient.Set("/prod/configuration", "value"); err != nil {
+		t.Errorf("Set of a sibling of the frozen prefix returned %v", err)
+	}
+
+	if err := etcdClient.UnfreezePrefix("/prod/config"); err != nil {
+		t.Fatalf("UnfreezePrefix returned %v", err)
+	}
+	if err := etcdClient.Set("/prod/config/key", "changed"); err != nil {
+		t.Errorf("Set after UnfreezePrefix returned %v", err)
+	}
+	if err := etcdClient.UnfreezePrefix("/prod/config"); err != nil {
+		t.Errorf("UnfreezePrefix of a prefix that is not frozen returned %v", err)
+	}
+}
+
+func TestFrozenPrefixes(t *testing.T) {
+	etcdClient := dial(t)
+
+	if freezes, err := etcdClient.FrozenPrefixes(); err != nil || len(freezes) != 0 {
+		t.Errorf("FrozenPrefixes without freezes returned %v, %v", freezes, err)
+	}
+	for _, prefix := range []string{"/prod", "/prod/config"} {
+		if err := etcdClient.FreezePrefix(prefix, "incident 42", 0); err != nil {
+			t.Fatalf("FreezePrefix returned %v", err)
+		}
+	}
+
+	freezes, err := etcdClient.FrozenPrefixes()
+	if err != nil || len(freezes) != 2 || freezes[0].Prefix != "/prod" || freezes[1].Prefix != "/prod/config" || freezes[0].Reason != "incident 42" {
+		t.Errorf("FrozenPrefixes returned %+v, %v, expected both prefixes", freezes, err)
+	}
+}
+
+func TestFreezePrefixStopsOtherClients(t *testing.T) {
+	freezer, writer := dialTwice(t)
+	if err := writer.Set("/prod/config/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	if err := freezer.FreezePrefix("/prod/config", "incident 42", 0); err != nil {
+		t.Fatalf("FreezePrefix returned %v", err)
+	}
+	waitForWrite(t, writer, true)
+
+	if err := freezer.UnfreezePrefix("/prod/config"); err != nil {
+		t.Fatalf("UnfreezePrefix returned %v", err)
+	}
+	waitForWrite(t, writer, false)
+}
+
+func TestFreezePrefixExpires(t *testing.T) {
+	freezer, writer := dialTwice(t)
+	writer.Set("/prod/config/key", "value")
+
+	if err := freezer.FreezePrefix("/prod/config", "incident 42", time.Second); err != nil {
+		t.Fatalf("FreezePrefix returned %v", err)
+	}
+	waitForWrite(t, writer, true)
+	waitForWrite(t, writer, false)
+}
+
+// waitForWrite waits for writes under /prod/config to be rejected
+// as frozen, or to be allowed again if frozen is false
+func waitForWrite(t *testing.T, etcdClient *etcdclient.SimpleEtcdClient, frozen bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		err := etcdClient.Set("/prod/config/key", "value")
+		if errors.Is(err, etcdclient.ErrFrozen) == frozen {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Set still returned %v, expected frozen to be %v", err, frozen)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
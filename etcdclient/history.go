@@ -0,0 +1,64 @@
+package etcdclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// historyWait is how long History waits for the next event. Events that
+// are still in etcd's history are returned immediately, so waiting longer
+// means there are no more events in the range
+var historyWait = time.Second
+
+// History returns the changes to the key, or to the directory and
+// everything in it, from fromIndex to toIndex inclusive. Etcd only keeps
+// the last 1000 events, if fromIndex is older than that an error is
+// returned. A toIndex of 0, or past the current index, returns every
+// change up to the current index
+func (etcdClient *SimpleEtcdClient) History(key string, fromIndex, toIndex uint64) ([]Event, error) {
+	if fromIndex <= 1 {
+		return nil, fmt.Errorf("History fromIndex must be greater than 1, got %v", fromIndex)
+	}
+
+	current, err := etcdClient.Index()
+	if err != nil {
+		return nil, err
+	}
+	if toIndex == 0 || toIndex > current {
+		toIndex = current
+	}
+
+	events := make([]Event, 0)
+	if fromIndex > toIndex {
+		return events, nil
+	}
+
+	api := etcdClient.keysAPI()
+	watcher := api.Watcher(key, &client.WatcherOptions{AfterIndex: fromIndex - 1, Recursive: true})
+	for {
+		ctx, cancel := context.WithTimeout(etcdClient.ctx, historyWait)
+		response, err := watcher.Next(ctx)
+		cancel()
+
+		if err != nil {
+			if ctx.Err() != nil && etcdClient.ctx.Err() == nil {
+				return events, nil
+			}
+			if _, ok := eventIndexCleared(err); ok {
+				return nil, fmt.Errorf("History of %v from index %v has been cleared: %v", key, fromIndex, err)
+			}
+			return nil, err
+		}
+
+		if response.Node.ModifiedIndex > toIndex {
+			return events, nil
+		}
+		events = append(events, newEvent(response))
+		if response.Node.ModifiedIndex == toIndex {
+			return events, nil
+		}
+	}
+}
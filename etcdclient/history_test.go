@@ -0,0 +1,69 @@
+package etcdclient_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// setAndIndex sets the key and returns the index of the change
+func setAndIndex(t *testing.T, etcdClient etcdclient.EtcdClient, key, value string) uint64 {
+	if err := etcdClient.Set(key, value); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+	return index
+}
+
+func TestHistory(t *testing.T) {
+	etcdClient := dial(t)
+	// History can not start at index 1, the first change of the server
+	setAndIndex(t, etcdClient, "/other", "value")
+	first := setAndIndex(t, etcdClient, "/history/a", "1")
+	other := setAndIndex(t, etcdClient, "/other", "value")
+	setAndIndex(t, etcdClient, "/history/b", "2")
+	if err := etcdClient.Del("/history/a"); err != nil {
+		t.Fatalf("Del returned %v", err)
+	}
+
+	events, err := etcdClient.History("/history", first, 0)
+	if err != nil {
+		t.Fatalf("History returned %v", err)
+	}
+	summary := ""
+	for _, event := range events {
+		summary += fmt.Sprintf("%v %v %v,", event.Action, event.Key, event.Value)
+	}
+	if expected := "set /history/a 1,set /history/b 2,delete /history/a ,"; summary != expected {
+		t.Errorf("History returned %v, expected %v", summary, expected)
+	}
+
+	events, err = etcdClient.History("/history", first, other)
+	if err != nil || len(events) != 1 || events[0].Index != first {
+		t.Errorf("History up to index %v returned %v, %v, expected only the change at %v", other, events, err, first)
+	}
+	events, err = etcdClient.History("/history", other+100, 0)
+	if err != nil || len(events) != 0 {
+		t.Errorf("History past the current index returned %v, %v, expected no events", events, err)
+	}
+}
+
+func TestHistoryRejectsClearedAndInvalidIndexes(t *testing.T) {
+	etcdClient := dial(t)
+	first := setAndIndex(t, etcdClient, "/history/key", "first")
+	for i := 0; i <= etcdtest.MemoryHistory; i++ {
+		setAndIndex(t, etcdClient, "/history/key", fmt.Sprint(i))
+	}
+
+	if _, err := etcdClient.History("/history", first, 0); err == nil {
+		t.Error("History from a cleared index returned no error")
+	}
+	if _, err := etcdClient.History("/history", 1, 0); err == nil {
+		t.Error("History from index 1 returned no error")
+	}
+}
@@ -0,0 +1,56 @@
+package etcdclient
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// Join joins the parts into an absolute key, adding slashes
+// between them as needed
+func Join(parts ...string) string {
+	return normalizeKey(path.Join(parts...))
+}
+
+// Split returns the segments of the key. The root
+// key has no segments
+func Split(key string) []string {
+	key = strings.Trim(key, "/")
+	if key == "" {
+		return []string{}
+	}
+	return strings.Split(key, "/")
+}
+
+// ValidateKey returns an error if the key is empty, has a trailing slash,
+// an empty, "." or ".." segment, or a control or whitespace character.
+// Etcd accepts some of these but gives them a different meaning, see
+// WithKeyValidation to check every key that is written
+func ValidateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("Invalid key %q: key is empty", key)
+	}
+	if key == "/" {
+		return nil
+	}
+	if strings.HasSuffix(key, "/") {
+		return fmt.Errorf("Invalid key %q: trailing slash", key)
+	}
+
+	for _, segment := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		switch segment {
+		case "":
+			return fmt.Errorf("Invalid key %q: empty segment", key)
+		case ".", "..":
+			return fmt.Errorf("Invalid key %q: %q segment", key, segment)
+		}
+	}
+
+	for _, char := range key {
+		if unicode.IsControl(char) || unicode.IsSpace(char) {
+			return fmt.Errorf("Invalid key %q: invalid character %q", key, char)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestJoin(t *testing.T) {
+	for _, test := range []struct {
+		parts    []string
+		expected string
+	}{
+		{[]string{"config", "db", "host"}, "/config/db/host"},
+		{[]string{"/config/", "/db/"}, "/config/db"},
+		{[]string{"/"}, "/"},
+		{[]string{}, "/"},
+	} {
+		if key := etcdclient.Join(test.parts...); key != test.expected {
+			t.Errorf("Join(%q) returned %q, expected %q", test.parts, key, test.expected)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for key, expected := range map[string][]string{
+		"/config/db/host": {"config", "db", "host"},
+		"config/":         {"config"},
+		"/":               {},
+		"":                {},
+	} {
+		if segments := etcdclient.Split(key); !reflect.DeepEqual(segments, expected) {
+			t.Errorf("Split(%q) returned %q, expected %q", key, segments, expected)
+		}
+	}
+}
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"/", "/config", "config/db.host", "/a-b_c/d"} {
+		if err := etcdclient.ValidateKey(key); err != nil {
+			t.Errorf("ValidateKey(%q) returned %v", key, err)
+		}
+	}
+	for _, key := range []string{"", "/config/", "/config//db", "/config/./db", "/config/..", "/has space", "/tab\tkey"} {
+		if err := etcdclient.ValidateKey(key); err == nil {
+			t.Errorf("ValidateKey(%q) returned no error", key)
+		}
+	}
+}
+
+func TestWithKeyValidation(t *testing.T) {
+	etcdClient := dial(t, etcdclient.WithKeyValidation())
+
+	if err := etcdClient.Set("/config//db", "value"); err == nil {
+		t.Error("Set of an invalid key returned no error")
+	}
+	if err := etcdClient.Set("/config/db", "value"); err != nil {
+		t.Errorf("Set of a valid key returned %v", err)
+	}
+	if keys, _ := etcdClient.LsRecursive("/"); !reflect.DeepEqual(keys, []string{"/config", "/config/db"}) {
+		t.Errorf("Got the keys %v, expected only the valid key to be written", keys)
+	}
+}
@@ -0,0 +1,282 @@
+package etcdclient
+
+import (
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// keysAPI wraps client.KeysAPI so every request made by
+// the client is reported to the configured hooks
+type keysAPI struct {
+	client.KeysAPI
+	etcdClient *SimpleEtcdClient
+}
+
+// keysWatcher wraps client.Watcher so every request made
+// by the watcher is reported to the configured hooks
+type keysWatcher struct {
+	client.Watcher
+	api *keysAPI
+	key string
+}
+
+// keysAPI returns the KeysAPI all requests should go through
+func (etcdClient *SimpleEtcdClient) keysAPI() client.KeysAPI {
+	if header := Metadata(etcdClient.ctx); header != nil {
+		keys, err := etcdClient.metadataKeysAPI(header)
+		if err == nil {
+			return &keysAPI{keys, etcdClient}
+		}
+		etcdClient.options.log("sending requests without metadata", "err", err)
+	}
+	return &keysAPI{client.NewKeysAPI(etcdClient.etcd), etcdClient}
+}
+
+func (api *keysAPI) Get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	if api.etcdClient.options.quorumReads {
+		quorumOpts := client.GetOptions{Quorum: true}
+		if opts != nil {
+			quorumOpts.Recursive = opts.Recursive
+			quorumOpts.Sort = opts.Sort
+		}
+		opts = &quorumOpts
+	}
+
+	group := api.etcdClient.options.singleflight
+	if group == nil {
+		return api.fallback(key, opts)(api.get(ctx, key, opts))
+	}
+
+	return api.fallback(key, opts)(group.do(getCallKey(key, opts), func() (*client.Response, error) {
+		return api.get(ctx, key, opts)
+	}))
+}
+
+// fallback returns a function that serves the read from the fallback
+// snapshot, if there is one, when etcd could not be reached
+func (api *keysAPI) fallback(key string, opts *client.GetOptions) func(*client.Response, error) (*client.Response, error) {
+	snapshot := api.etcdClient.options.fallback
+	return func(response *client.Response, err error) (*client.Response, error) {
+		if snapshot == nil {
+			return response, err
+		}
+		return snapshot.get(key, opts, response, err)
+	}
+}
+
+func (api *keysAPI) get(ctx context.Context, key string, opts *client.GetOptions) (*client.Response, error) {
+	return api.do(ctx, "get", key, func(ctx context.Context) (*client.Response, error) {
+		return api.route(ctx, false, func(keys client.KeysAPI) (*client.Response, error) {
+			return keys.Get(ctx, key, opts)
+		})
+	})
+}
+
+func (api *keysAPI) Set(ctx context.Context, key, value string, opts *client.SetOptions) (*client.Response, error) {
+	options := api.etcdClient.options
+	if err := options.checkWrite(key); err != nil {
+		return nil, wrapError("set", key, err)
+	}
+	if err := api.checkFrozen(ctx, key, false); err != nil {
+		return nil, wrapError("set", key, err)
+	}
+
+	if opts == nil || !opts.Dir && !opts.Refresh {
+		if err := options.validateValue(key, value); err != nil {
+			return nil, wrapError("set", key, err)
+		}
+	}
+
+	if opts == nil || !opts.Dir {
+		encoded, err := options.encodeValue(value)
+		if err != nil {
+			return nil, wrapError("set", key, err)
+		}
+		value = encoded
+	}
+
+	if opts != nil && opts.PrevValue != "" {
+		prevValue, err := options.encodeValue(opts.PrevValue)
+		if err != nil {
+			return nil, wrapError("set", key, err)
+		}
+		encodedOpts := *opts
+		encodedOpts.PrevValue = prevValue
+		opts = &encodedOpts
+	}
+
+	return api.do(ctx, "set", key, func(ctx context.Context) (*client.Response, error) {
+		return api.route(ctx, true, func(keys client.KeysAPI) (*client.Response, error) {
+			return keys.Set(ctx, key, value, opts)
+		})
+	})
+}
+
+func (api *keysAPI) Delete(ctx context.Context, key string, opts *client.DeleteOptions) (*client.Response, error) {
+	if err := api.etcdClient.options.checkWrite(key); err != nil {
+		return nil, wrapError("delete", key, err)
+	}
+	if err := api.checkFrozen(ctx, key, opts != nil && (opts.Recursive || opts.Dir)); err != nil {
+		return nil, wrapError("delete", key, err)
+	}
+
+	if opts != nil && opts.PrevValue != "" {
+		prevValue, err := api.etcdClient.options.encodeValue(opts.PrevValue)
+		if err != nil {
+			return nil, wrapError("delete", key, err)
+		}
+		encodedOpts := *opts
+		encodedOpts.PrevValue = prevValue
+		opts = &encodedOpts
+	}
+
+	return api.do(ctx, "delete", key, func(ctx context.Context) (*client.Response, error) {
+		return api.route(ctx, true, func(keys client.KeysAPI) (*client.Response, error) {
+			return keys.Delete(ctx, key, opts)
+		})
+	})
+}
+
+func (api *keysAPI) Create(ctx context.Context, key, value string) (*client.Response, error) {
+	return api.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevNoExist})
+}
+
+func (api *keysAPI) Update(ctx context.Context, key, value string) (*client.Response, error) {
+	return api.Set(ctx, key, value, &client.SetOptions{PrevExist: client.PrevExist})
+}
+
+func (api *keysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *client.CreateInOrderOptions) (*client.Response, error) {
+	if err := api.etcdClient.options.checkWrite(dir); err != nil {
+		return nil, wrapError("createInOrder", dir, err)
+	}
+	if err := api.checkFrozen(ctx, dir, false); err != nil {
+		return nil, wrapError("createInOrder", dir, err)
+	}
+	if err := api.etcdClient.options.validateValue(dir, value); err != nil {
+		return nil, wrapError("createInOrder", dir, err)
+	}
+
+	return api.do(ctx, "createInOrder", dir, func(ctx context.Context) (*client.Response, error) {
+		return api.route(ctx, true, func(keys client.KeysAPI) (*client.Response, error) {
+			return keys.CreateInOrder(ctx, dir, value, opts)
+		})
+	})
+}
+
+func (api *keysAPI) Watcher(key string, opts *client.WatcherOptions) client.Watcher {
+	return &keysWatcher{api.KeysAPI.Watcher(key, opts), api, key}
+}
+
+func (watcher *keysWatcher) Next(ctx context.Context) (*client.Response, error) {
+	return watcher.api.do(ctx, "watch", watcher.key, watcher.Watcher.Next)
+}
+
+// checkWrite returns an error if the key may not be written
+func (opts *options) checkWrite(key string) error {
+	if opts.readOnly {
+		return ErrReadOnly
+	}
+	if opts.validateKeys {
+		return ValidateKey(key)
+	}
+	return nil
+}
+
+// validateValue returns an error if a validator for the key rejects the
+// value. Values written in order to a directory are checked as the directory
+func (opts *options) validateValue(key, value string) error {
+	key = normalizeKey(key)
+	for _, validator := range opts.validators {
+		if key != validator.prefix && !isAncestor(validator.prefix, key) {
+			continue
+		}
+		if err := validator.fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// do makes a request, reporting it to the configured hooks
+func (api *keysAPI) do(ctx context.Context, op, key string, request func(ctx context.Context) (*client.Response, error)) (*client.Response, error) {
+	etcdClient := api.etcdClient
+	start := time.Now()
+
+	ctx, cancel := etcdClient.withContext(ctx)
+	defer cancel()
+	ctx, span := etcdClient.startSpan(ctx, op, key)
+
+	response, err := api.request(ctx, op, request)
+	if err == nil {
+		err = etcdClient.options.decodeResponse(response)
+	}
+
+	span.End(err)
+	api.observe(op, key, start, err)
+	return response, wrapError(op, key, err)
+}
+
+// request waits for the rate limiter and checks the circuit
+// breaker, if they are configured, then makes the request
+func (api *keysAPI) request(ctx context.Context, op string, request func(ctx context.Context) (*client.Response, error)) (*client.Response, error) {
+	options := api.etcdClient.options
+
+	if options.rateLimiter != nil {
+		if err := options.rateLimiter.wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.scheduler != nil && op != "watch" {
+		if err := options.scheduler.acquire(ctx); err != nil {
+			return nil, err
+		}
+		defer options.scheduler.release()
+	}
+
+	trial := false
+	if options.breaker != nil {
+		var allowed bool
+		if allowed, trial = options.breaker.allow(); !allowed {
+			return nil, ErrCircuitOpen
+		}
+	}
+
+	response, err := request(ctx)
+	if options.breaker != nil {
+		options.breaker.record(err, trial)
+	}
+	options.recordConnection(err)
+	return response, err
+}
+
+// observe reports a finished request. Missing keys are
+// an expected result, so they are not reported as errors
+func (api *keysAPI) observe(op, key string, start time.Time, err error) {
+	options := api.etcdClient.options
+	took := time.Since(start)
+	if isKeyNotFound(err) {
+		err = nil
+	}
+
+	if op == "watch" {
+		return
+	}
+
+	if options.metrics != nil {
+		options.metrics.ObserveRequest(op, took, err)
+	}
+
+	threshold := options.slowRequestThreshold
+	if threshold <= 0 {
+		threshold = DefaultSlowRequestThreshold
+	}
+	if took > threshold {
+		options.log("slow request", "op", op, "key", key, "took", took)
+		if options.onSlowRequest != nil {
+			options.onSlowRequest(op, key, took)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package etcdclient
+
+import "time"
+
+// DefaultSlowRequestThreshold is how long a request can take
+// before it is logged as slow
+const DefaultSlowRequestThreshold = time.Second
+
+// Logger receives messages about things the client handles on its own,
+// such as watch retries, ignored errors and slow requests. The keyvals
+// are alternating keys and values describing the message
+type Logger interface {
+	Log(msg string, keyvals ...interface{})
+}
+
+// WithLogger sends the client's log messages to logger
+func WithLogger(logger Logger) Option {
+	return func(opts *options) {
+		opts.logger = logger
+	}
+}
+
+// OnSlowRequestCallback is used for passing callbacks to
+// WithSlowRequestThreshold
+type OnSlowRequestCallback func(op, key string, took time.Duration)
+
+// WithSlowRequestThreshold logs requests that take longer than threshold,
+// instead of DefaultSlowRequestThreshold, and calls onSlowRequest for each
+// of them. onSlowRequest may be nil. Watches are never slow
+func WithSlowRequestThreshold(threshold time.Duration, onSlowRequest OnSlowRequestCallback) Option {
+	return func(opts *options) {
+		opts.slowRequestThreshold = threshold
+		opts.onSlowRequest = onSlowRequest
+	}
+}
+
+func (opts *options) log(msg string, keyvals ...interface{}) {
+	if opts.logger != nil {
+		opts.logger.Log(msg, keyvals...)
+	}
+}
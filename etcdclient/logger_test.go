@@ -0,0 +1,157 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// recordedLogger keeps every message with its keyvals
+type recordedLogger struct {
+	mutex    sync.Mutex
+	messages []string
+	keyvals  [][]interface{}
+}
+
+func (logger *recordedLogger) Log(msg string, keyvals ...interface{}) {
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	logger.messages = append(logger.messages, msg)
+	logger.keyvals = append(logger.keyvals, keyvals)
+}
+
+func TestWithLoggerLogsWatchRetries(t *testing.T) {
+	server := etcdtest.StartMemory()
+	logger := &recordedLogger{}
+	etcdClient, err := server.Client(
+		etcdclient.WithLogger(logger),
+		etcdclient.WithWatchRetry(etcdclient.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+	server.Stop()
+
+	if err := etcdClient.WatchRecursive("/watched", func(key, newValue string) {}); err == nil {
+		t.Error("WatchRecursive returned no error")
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	retries := 0
+	for i, msg := range logger.messages {
+		if msg != "retrying watch" {
+			continue
+		}
+		retries++
+		keyvals := logger.keyvals[i]
+		if len(keyvals) < 2 || keyvals[0] != "directory" || keyvals[1] != "/watched" {
+			t.Errorf("Logged %q with %v, expected the directory first", msg, keyvals)
+		}
+	}
+	if retries != 2 {
+		t.Errorf("Logged %v retries in %v, expected 2", retries, logger.messages)
+	}
+}
+
+func TestWithLoggerLogsClearedWatchEvents(t *testing.T) {
+	logger := &recordedLogger{}
+	etcdClient := dial(t, etcdclient.WithLogger(logger))
+
+	if err := etcdClient.Set("/cleared/key", "first"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+	for i := 0; i <= etcdtest.MemoryHistory; i++ {
+		if err := etcdClient.Set("/other/key", "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	changed := make(chan struct{}, 1)
+	go etcdClient.WatchRecursiveDebouncedFrom("/cleared", index, 10*time.Millisecond, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The callback was not called after the index was cleared")
+	}
+
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	for _, msg := range logger.messages {
+		if msg == "watch events were cleared, treating them as a change" {
+			return
+		}
+	}
+	t.Errorf("Logged %v, expected the cleared watch events to be logged", logger.messages)
+}
+
+func TestWithSlowRequestThreshold(t *testing.T) {
+	logger := &recordedLogger{}
+	var mutex sync.Mutex
+	var slow []string
+	etcdClient := dial(t, etcdclient.WithLogger(logger), etcdclient.WithSlowRequestThreshold(time.Nanosecond, func(op, key string, took time.Duration) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		slow = append(slow, op+" "+key)
+	}))
+
+	watched := make(chan string, 1)
+	go etcdClient.WatchRecursive("/slow", func(key, newValue string) { watched <- key })
+	time.Sleep(100 * time.Millisecond)
+	if err := etcdClient.Set("/slow/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	receive(t, watched)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	// the internal gets of a write are reported too
+	set := false
+	for _, request := range slow {
+		if strings.HasPrefix(request, "watch") {
+			t.Errorf("%v was reported as slow, expected watches never to be", request)
+		}
+		set = set || request == "set /slow/key"
+	}
+	if !set {
+		t.Errorf("The slow requests were %v, expected the set", slow)
+	}
+	logger.mutex.Lock()
+	defer logger.mutex.Unlock()
+	for _, msg := range logger.messages {
+		if msg != "slow request" {
+			t.Errorf("Logged %q, expected only slow requests", msg)
+		}
+	}
+	if len(logger.messages) != len(slow) {
+		t.Errorf("Logged %v slow requests, expected one for each of %v", len(logger.messages), slow)
+	}
+}
+
+func TestSlowRequestsOnlyPastTheThreshold(t *testing.T) {
+	logger := &recordedLogger{}
+	etcdClient := dial(t, etcdclient.WithLogger(logger), etcdclient.WithSlowRequestThreshold(time.Hour, func(op, key string, took time.Duration) {
+		t.Errorf("%v %v was reported as slow, it took %v", op, key, took)
+	}))
+
+	if err := etcdClient.Set("/slow/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if len(logger.messages) != 0 {
+		t.Errorf("Logged %v, expected nothing", logger.messages)
+	}
+}
@@ -0,0 +1,182 @@
+package etcdclient
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/coreos/etcd/client"
+)
+
+// LsKeys returns the names of the keys in the directory, relative to
+// the directory. Subdirectories are left out
+func (etcdClient *SimpleEtcdClient) LsKeys(directory string) ([]string, error) {
+	return etcdClient.lsNames(directory, func(node *client.Node) bool {
+		return !node.Dir
+	})
+}
+
+// LsDirs returns the names of the subdirectories in the directory,
+// relative to the directory. Keys are left out
+func (etcdClient *SimpleEtcdClient) LsDirs(directory string) ([]string, error) {
+	return etcdClient.lsNames(directory, func(node *client.Node) bool {
+		return node.Dir
+	})
+}
+
+// lsNames returns the names of the children of the
+// directory that match the filter
+func (etcdClient *SimpleEtcdClient) lsNames(directory string, filter func(node *client.Node) bool) ([]string, error) {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Sort: true, Recursive: false}
+	response, err := api.Get(etcdClient.ctx, directory, options)
+
+	names := make([]string, 0)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return names, nil
+		}
+		return names, err
+	}
+
+	for _, node := range response.Node.Nodes {
+		if filter(node) {
+			names = append(names, path.Base(node.Key))
+		}
+	}
+	return names, nil
+}
+
+// LsPage is a page of keys returned by LsRecursivePaged
+type LsPage struct {
+	Keys []string
+
+	// Next is passed to LsRecursivePaged to get the following page,
+	// it is empty once there are no more pages
+	Next string
+}
+
+// errPageFull stops the traversal once a page is full
+var errPageFull = errors.New("page full")
+
+// LsRecursivePaged returns up to limit keys in the directory, recursively,
+// starting after the key after. Pass an empty after for the first page and
+// the page's Next for the following pages. The directory is traversed one
+// level at a time, depth first with the children of each directory sorted
+// by name, so only the directories of the current page are fetched
+func (etcdClient *SimpleEtcdClient) LsRecursivePaged(directory string, limit int, after string) (LsPage, error) {
+	page := LsPage{Keys: make([]string, 0)}
+	if limit <= 0 {
+		return page, fmt.Errorf("Page limit must be greater than 0, got %v", limit)
+	}
+
+	err := etcdClient.lsPage(directory, limit, after, &page)
+	if err == errPageFull {
+		return page, nil
+	}
+	if err != nil {
+		return LsPage{Keys: make([]string, 0)}, err
+	}
+
+	page.Next = ""
+	return page, nil
+}
+
+func (etcdClient *SimpleEtcdClient) lsPage(directory string, limit int, after string, page *LsPage) error {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Sort: true, Recursive: false}
+	response, err := api.Get(etcdClient.ctx, directory, options)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, node := range response.Node.Nodes {
+		if after != "" && compareKeys(node.Key, after) <= 0 {
+			if node.Dir && (node.Key == after || isAncestor(node.Key, after)) {
+				if err := etcdClient.lsPage(node.Key, limit, after, page); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		if len(page.Keys) == limit {
+			return errPageFull
+		}
+		page.Keys = append(page.Keys, node.Key)
+		page.Next = node.Key
+
+		if node.Dir {
+			if err := etcdClient.lsPage(node.Key, limit, "", page); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WalkFunc is called by WalkRecursive for every key and directory
+type WalkFunc func(key, value string, dir bool) error
+
+// WalkRecursive calls fn for every key and directory in the directory,
+// recursively, in the same order as LsRecursivePaged. Directories are
+// fetched one level at a time as they are reached instead of all at once.
+// If fn returns an error the walk stops and the error is returned
+func (etcdClient *SimpleEtcdClient) WalkRecursive(directory string, fn WalkFunc) error {
+	return etcdClient.walkNodes(directory, func(node *client.Node) error {
+		return fn(node.Key, node.Value, node.Dir)
+	})
+}
+
+// walkNodes calls fn for every node in the directory, recursively,
+// fetching the directories one level at a time
+func (etcdClient *SimpleEtcdClient) walkNodes(directory string, fn func(node *client.Node) error) error {
+	api := etcdClient.keysAPI()
+	options := &client.GetOptions{Sort: true, Recursive: false}
+	ctx := etcdClient.withDefaultPriority(PriorityBackground).ctx
+	response, err := api.Get(ctx, directory, options)
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, node := range response.Node.Nodes {
+		if err := fn(node); err != nil {
+			return err
+		}
+
+		if node.Dir {
+			if err := etcdClient.walkNodes(node.Key, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// compareKeys orders keys the way a depth first traversal with
+// sorted children visits them, it returns a negative number if
+// a comes before b, 0 if they are the same and a positive number
+// if a comes after b
+func compareKeys(a, b string) int {
+	aSegments := strings.Split(strings.Trim(a, "/"), "/")
+	bSegments := strings.Split(strings.Trim(b, "/"), "/")
+
+	for i := 0; i < len(aSegments) && i < len(bSegments); i++ {
+		if aSegments[i] != bSegments[i] {
+			return strings.Compare(aSegments[i], bSegments[i])
+		}
+	}
+	return len(aSegments) - len(bSegments)
+}
+
+// isAncestor returns true if key is inside directory
+func isAncestor(directory, key string) bool {
+	return strings.HasPrefix(key, strings.TrimSuffix(directory, "/")+"/")
+}
@@ -0,0 +1,117 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestLsKeysAndLsDirs(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/ls/b", "/ls/a", "/ls/dir/c", "/ls/other/d")
+
+	keys, err := etcdClient.LsKeys("/ls")
+	if expected := []string{"a", "b"}; err != nil || !reflect.DeepEqual(keys, expected) {
+		t.Errorf("LsKeys returned %v, %v, expected %v", keys, err, expected)
+	}
+	dirs, err := etcdClient.LsDirs("/ls")
+	if expected := []string{"dir", "other"}; err != nil || !reflect.DeepEqual(dirs, expected) {
+		t.Errorf("LsDirs returned %v, %v, expected %v", dirs, err, expected)
+	}
+
+	keys, err = etcdClient.LsKeys("/missing")
+	if err != nil || keys == nil || len(keys) != 0 {
+		t.Errorf("LsKeys of a missing directory returned %#v, %v, expected an empty list", keys, err)
+	}
+}
+
+func TestLsRecursivePaged(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/paged/a", "/paged/b/c", "/paged/b/d/e", "/paged/f")
+	expected, err := etcdClient.LsRecursive("/paged")
+	if err != nil {
+		t.Fatalf("LsRecursive returned %v", err)
+	}
+
+	for limit := 1; limit <= len(expected)+1; limit++ {
+		var keys []string
+		after := ""
+		for pages := 0; ; pages++ {
+			if pages > len(expected) {
+				t.Fatalf("With a limit of %v the pages never ended", limit)
+			}
+			page, err := etcdClient.LsRecursivePaged("/paged", limit, after)
+			if err != nil {
+				t.Fatalf("LsRecursivePaged returned %v", err)
+			}
+			if len(page.Keys) > limit {
+				t.Errorf("Got a page of %v keys with a limit of %v", len(page.Keys), limit)
+			}
+			keys = append(keys, page.Keys...)
+			if page.Next == "" {
+				break
+			}
+			after = page.Next
+		}
+
+		if !reflect.DeepEqual(keys, expected) {
+			t.Errorf("With a limit of %v the pages hold %v, expected %v", limit, keys, expected)
+		}
+	}
+}
+
+func TestLsRecursivePagedRequiresALimit(t *testing.T) {
+	etcdClient := dial(t)
+
+	if _, err := etcdClient.LsRecursivePaged("/paged", 0, ""); err == nil {
+		t.Error("LsRecursivePaged with a limit of 0 returned no error")
+	}
+}
+
+func TestWalkRecursive(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/walk/a", "/walk/b/c", "/walk/d")
+	expected, err := etcdClient.LsRecursive("/walk")
+	if err != nil {
+		t.Fatalf("LsRecursive returned %v", err)
+	}
+
+	var walked []string
+	err = etcdClient.WalkRecursive("/walk", func(key, value string, dir bool) error {
+		walked = append(walked, key)
+		if dir != (key == "/walk/b") {
+			t.Errorf("WalkRecursive called %v with dir %v", key, dir)
+		}
+		if !dir && value != "value" {
+			t.Errorf("WalkRecursive called %v with %q, expected \"value\"", key, value)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("WalkRecursive returned %v", err)
+	}
+	if !reflect.DeepEqual(walked, expected) {
+		t.Errorf("WalkRecursive walked %v, expected %v", walked, expected)
+	}
+}
+
+func TestWalkRecursiveStopsAtAnError(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/walk/a", "/walk/b/c", "/walk/d")
+	stop := errors.New("stop")
+
+	var walked []string
+	err := etcdClient.WalkRecursive("/walk", func(key, value string, dir bool) error {
+		walked = append(walked, key)
+		if key == "/walk/b/c" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Errorf("WalkRecursive returned %v, expected the error of fn", err)
+	}
+	if expected := []string{"/walk/a", "/walk/b", "/walk/b/c"}; !reflect.DeepEqual(walked, expected) {
+		t.Errorf("WalkRecursive walked %v, expected %v", walked, expected)
+	}
+}
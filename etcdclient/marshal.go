@@ -0,0 +1,143 @@
+package etcdclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Codec converts Go values to and from the strings stored in etcd,
+// for GetValue and SetValue
+type Codec interface {
+	Marshal(value interface{}) ([]byte, error)
+	Unmarshal(data []byte, value interface{}) error
+}
+
+// CodecFuncs makes a Codec out of a pair of functions, so formats whose
+// packages are not vendored here can be plugged in, for example
+// CodecFuncs{yaml.Marshal, yaml.Unmarshal}. Protobuf needs a small wrapper
+// that asserts the values to proto.Message. Values must be valid UTF-8,
+// wrap binary formats with Base64Codec
+type CodecFuncs struct {
+	MarshalFunc   func(value interface{}) ([]byte, error)
+	UnmarshalFunc func(data []byte, value interface{}) error
+}
+
+// Marshal calls MarshalFunc
+func (codec CodecFuncs) Marshal(value interface{}) ([]byte, error) {
+	return codec.MarshalFunc(value)
+}
+
+// Unmarshal calls UnmarshalFunc
+func (codec CodecFuncs) Unmarshal(data []byte, value interface{}) error {
+	return codec.UnmarshalFunc(data, value)
+}
+
+// JSONCodec stores values as JSON, it is used unless WithCodec
+// or WithPrefixCodec configure another codec
+var JSONCodec Codec = CodecFuncs{json.Marshal, json.Unmarshal}
+
+// GobCodec stores values encoded with encoding/gob, base64 encoded
+var GobCodec Codec = Base64Codec{CodecFuncs{gobMarshal, gobUnmarshal}}
+
+// Base64Codec base64 encodes the output of a binary codec,
+// since etcd v2 can only store strings
+type Base64Codec struct {
+	Codec Codec
+}
+
+// Marshal marshals the value with the inner codec and base64 encodes it
+func (codec Base64Codec) Marshal(value interface{}) ([]byte, error) {
+	data, err := codec.Codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(data)))
+	base64.StdEncoding.Encode(encoded, data)
+	return encoded, nil
+}
+
+// Unmarshal base64 decodes the data and unmarshals it with the inner codec
+func (codec Base64Codec) Unmarshal(data []byte, value interface{}) error {
+	decoded := make([]byte, base64.StdEncoding.DecodedLen(len(data)))
+	n, err := base64.StdEncoding.Decode(decoded, data)
+	if err != nil {
+		return err
+	}
+	return codec.Codec.Unmarshal(decoded[:n], value)
+}
+
+func gobMarshal(value interface{}) ([]byte, error) {
+	var buffer bytes.Buffer
+	err := gob.NewEncoder(&buffer).Encode(value)
+	return buffer.Bytes(), err
+}
+
+func gobUnmarshal(data []byte, value interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(value)
+}
+
+type prefixCodec struct {
+	prefix string
+	codec  Codec
+}
+
+// WithCodec makes GetValue and SetValue use the codec for
+// every key that has no codec set by WithPrefixCodec
+func WithCodec(codec Codec) Option {
+	return func(opts *options) {
+		opts.codec = codec
+	}
+}
+
+// WithPrefixCodec makes GetValue and SetValue use the codec for the prefix
+// and every key under it. If several prefixes match a key, the longest wins
+func WithPrefixCodec(prefix string, codec Codec) Option {
+	return func(opts *options) {
+		opts.prefixCodecs = append(opts.prefixCodecs, prefixCodec{normalizeKey(prefix), codec})
+		sort.SliceStable(opts.prefixCodecs, func(i, j int) bool {
+			return len(opts.prefixCodecs[i].prefix) > len(opts.prefixCodecs[j].prefix)
+		})
+	}
+}
+
+// CodecFor returns the codec GetValue and SetValue use for the key
+func (etcdClient *SimpleEtcdClient) CodecFor(key string) Codec {
+	key = normalizeKey(key)
+	for _, prefixCodec := range etcdClient.options.prefixCodecs {
+		if key == prefixCodec.prefix || isAncestor(prefixCodec.prefix, key) {
+			return prefixCodec.codec
+		}
+	}
+
+	if etcdClient.options.codec != nil {
+		return etcdClient.options.codec
+	}
+	return JSONCodec
+}
+
+// GetValue reads the key and unmarshals its value into the value out
+// points to, with the codec for the key. A missing key leaves out as it is
+func (etcdClient *SimpleEtcdClient) GetValue(key string, out interface{}) error {
+	value, err := etcdClient.Get(key)
+	if err != nil || value == "" {
+		return err
+	}
+
+	if err := etcdClient.CodecFor(key).Unmarshal([]byte(value), out); err != nil {
+		return fmt.Errorf("Failed to decode %v: %v", key, err)
+	}
+	return nil
+}
+
+// SetValue marshals the value with the codec for the key and sets it
+func (etcdClient *SimpleEtcdClient) SetValue(key string, value interface{}) error {
+	data, err := etcdClient.CodecFor(key).Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v: %v", key, err)
+	}
+	return etcdClient.Set(key, string(data))
+}
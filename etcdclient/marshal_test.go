@@ -0,0 +1,81 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+type endpoint struct {
+	Host string
+	Port int
+}
+
+func TestGetValueAndSetValue(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.SetValue("/values/endpoint", endpoint{"localhost", 80}); err != nil {
+		t.Fatalf("SetValue returned %v", err)
+	}
+	if raw, _ := etcdClient.Get("/values/endpoint"); raw != `{"Host":"localhost","Port":80}` {
+		t.Errorf("SetValue stored %v, expected JSON by default", raw)
+	}
+	var value endpoint
+	if err := etcdClient.GetValue("/values/endpoint", &value); err != nil || value != (endpoint{"localhost", 80}) {
+		t.Errorf("GetValue returned %+v, %v", value, err)
+	}
+
+	missing := endpoint{Host: "unchanged"}
+	if err := etcdClient.GetValue("/values/missing", &missing); err != nil || missing.Host != "unchanged" {
+		t.Errorf("GetValue of a missing key returned %+v, %v, expected the value left as it is", missing, err)
+	}
+
+	etcdClient.Set("/values/invalid", "not json")
+	if err := etcdClient.GetValue("/values/invalid", &value); err == nil || !strings.Contains(err.Error(), "/values/invalid") {
+		t.Errorf("GetValue of an invalid value returned %v, expected an error naming the key", err)
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	named := func(name string) etcdclient.Codec {
+		return etcdclient.CodecFuncs{
+			MarshalFunc:   func(value interface{}) ([]byte, error) { return []byte(name), nil },
+			UnmarshalFunc: func(data []byte, value interface{}) error { return nil },
+		}
+	}
+	etcdClient := dial(t,
+		etcdclient.WithCodec(named("client")),
+		etcdclient.WithPrefixCodec("/config", named("config")),
+		etcdclient.WithPrefixCodec("/config/yaml", named("yaml")),
+	)
+
+	for key, expected := range map[string]string{
+		"/other":           "client",
+		"/config":          "config",
+		"/config/app":      "config",
+		"/config/yaml/app": "yaml",
+		"/configuration":   "client",
+	} {
+		if data, _ := etcdClient.CodecFor(key).Marshal(nil); string(data) != expected {
+			t.Errorf("CodecFor(%v) returned the %v codec, expected the %v codec", key, string(data), expected)
+		}
+	}
+}
+
+func TestGobCodec(t *testing.T) {
+	etcdClient := dial(t, etcdclient.WithCodec(etcdclient.GobCodec))
+
+	if err := etcdClient.SetValue("/values/endpoint", endpoint{"localhost", 80}); err != nil {
+		t.Fatalf("SetValue returned %v", err)
+	}
+	var value endpoint
+	if err := etcdClient.GetValue("/values/endpoint", &value); err != nil || value != (endpoint{"localhost", 80}) {
+		t.Errorf("GetValue returned %+v, %v", value, err)
+	}
+
+	etcdClient.Set("/values/invalid", "!!! not base64")
+	if err := etcdClient.GetValue("/values/invalid", &value); err == nil {
+		t.Error("GetValue of a value that is not base64 returned no error")
+	}
+}
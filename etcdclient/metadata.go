@@ -0,0 +1,68 @@
+package etcdclient
+
+import (
+	"net/http"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+type metadataKey struct{}
+
+// WithMetadata returns a context that makes every key request of a client
+// returned by WithContext send the header, such as a request id or the
+// user on whose behalf the request is made, so the logs of etcd and of
+// proxies in front of it can be matched with application requests.
+// It can be called more than once to send several headers
+func WithMetadata(ctx context.Context, name, value string) context.Context {
+	header := http.Header{}
+	for existing, values := range Metadata(ctx) {
+		header[existing] = values
+	}
+	header.Add(name, value)
+	return context.WithValue(ctx, metadataKey{}, header)
+}
+
+// Metadata returns the headers added to the context by WithMetadata
+func Metadata(ctx context.Context) http.Header {
+	header, _ := ctx.Value(metadataKey{}).(http.Header)
+	return header
+}
+
+// metadataKeysAPI returns a KeysAPI whose requests send the headers.
+// It talks to the same endpoints, through the same transport, as the
+// client's own KeysAPI, so connections are shared
+func (etcdClient *SimpleEtcdClient) metadataKeysAPI(header http.Header) (client.KeysAPI, error) {
+	config := etcdClient.options.etcd
+	config.Endpoints = etcdClient.etcd.Endpoints()
+
+	transport := config.Transport
+	if transport == nil {
+		transport = client.DefaultTransport
+	}
+	config.Transport = &metadataTransport{transport, header}
+
+	etcd, err := client.New(config)
+	if err != nil {
+		return nil, err
+	}
+	return client.NewKeysAPI(etcd), nil
+}
+
+// metadataTransport adds headers to every request
+type metadataTransport struct {
+	client.CancelableTransport
+	header http.Header
+}
+
+// RoundTrip sets the headers on the request itself rather than on a copy,
+// so CancelRequest still finds it. The etcd client never reuses requests
+func (transport *metadataTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Header == nil {
+		request.Header = http.Header{}
+	}
+	for name, values := range transport.header {
+		request.Header[name] = values
+	}
+	return transport.CancelableTransport.RoundTrip(request)
+}
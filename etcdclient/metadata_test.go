@@ -0,0 +1,65 @@
+package etcdclient_test
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// headerTransport records the header of every request it sends
+type headerTransport struct {
+	*http.Transport
+	mutex   sync.Mutex
+	headers []http.Header
+}
+
+func (transport *headerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport.mutex.Lock()
+	transport.headers = append(transport.headers, request.Header)
+	transport.mutex.Unlock()
+	return transport.Transport.RoundTrip(request)
+}
+
+func TestWithMetadata(t *testing.T) {
+	ctx := etcdclient.WithMetadata(context.Background(), "X-Request-Id", "42")
+	ctx = etcdclient.WithMetadata(ctx, "X-User", "alice")
+	ctx = etcdclient.WithMetadata(ctx, "X-User", "bob")
+
+	header := etcdclient.Metadata(ctx)
+	if header.Get("X-Request-Id") != "42" || len(header["X-User"]) != 2 {
+		t.Errorf("Metadata returned %v, expected every added header", header)
+	}
+	if header := etcdclient.Metadata(context.Background()); header != nil {
+		t.Errorf("Metadata of a context without metadata returned %v", header)
+	}
+}
+
+func TestWithMetadataSendsTheHeaders(t *testing.T) {
+	transport := &headerTransport{Transport: &http.Transport{}}
+	etcdClient := dial(t, etcdclient.WithTransport(transport))
+
+	ctx := etcdclient.WithMetadata(context.Background(), "X-Request-Id", "42")
+	if err := etcdClient.WithContext(ctx).Set("/metadata/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Set("/metadata/other", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	var with, without int
+	for _, header := range transport.headers {
+		if header.Get("X-Request-Id") == "42" {
+			with++
+		} else {
+			without++
+		}
+	}
+	if with == 0 || without == 0 {
+		t.Errorf("%v requests sent the header and %v did not, expected only the requests with the metadata to send it", with, without)
+	}
+}
@@ -0,0 +1,24 @@
+package etcdclient
+
+import "time"
+
+// Metrics receives measurements of the requests the client
+// makes. Implement it to export them to a monitoring system
+// such as Prometheus, then pass it to WithMetrics
+type Metrics interface {
+	// ObserveRequest is called after every request with the
+	// operation ("get", "set", "delete" or "createInOrder"),
+	// how long it took and the error, if any
+	ObserveRequest(op string, duration time.Duration, err error)
+
+	// ObserveWatchReconnect is called every time a watch on
+	// the directory reconnects after an error
+	ObserveWatchReconnect(directory string)
+}
+
+// WithMetrics reports every request and watch reconnect to metrics
+func WithMetrics(metrics Metrics) Option {
+	return func(opts *options) {
+		opts.metrics = metrics
+	}
+}
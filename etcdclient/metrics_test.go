@@ -0,0 +1,84 @@
+package etcdclient_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// recordedMetrics counts the observations of every operation
+type recordedMetrics struct {
+	mutex      sync.Mutex
+	requests   map[string]int
+	errors     map[string]int
+	reconnects map[string]int
+}
+
+func newRecordedMetrics() *recordedMetrics {
+	return &recordedMetrics{requests: make(map[string]int), errors: make(map[string]int), reconnects: make(map[string]int)}
+}
+
+func (metrics *recordedMetrics) ObserveRequest(op string, duration time.Duration, err error) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.requests[op]++
+	if err != nil {
+		metrics.errors[op]++
+	}
+}
+
+func (metrics *recordedMetrics) ObserveWatchReconnect(directory string) {
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.reconnects[directory]++
+}
+
+func TestWithMetricsObservesRequests(t *testing.T) {
+	metrics := newRecordedMetrics()
+	etcdClient := dial(t, etcdclient.WithMetrics(metrics))
+
+	if err := etcdClient.Set("/metrics/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	etcdClient.Get("/metrics/key")
+	etcdClient.Get("/metrics/missing")
+	if err := etcdClient.Set("/metrics", "value"); err == nil {
+		t.Fatal("Set over a directory returned no error")
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	if metrics.requests["get"] < 2 || metrics.errors["get"] != 0 {
+		t.Errorf("Observed %v gets with %v errors, expected at least 2 without errors, a missing key is not an error", metrics.requests["get"], metrics.errors["get"])
+	}
+	if metrics.requests["set"] < 2 || metrics.errors["set"] != 1 {
+		t.Errorf("Observed %v sets with %v errors, expected at least 2 with 1 error", metrics.requests["set"], metrics.errors["set"])
+	}
+}
+
+func TestWithMetricsObservesWatchReconnects(t *testing.T) {
+	server := etcdtest.StartMemory()
+	metrics := newRecordedMetrics()
+	etcdClient, err := server.Client(
+		etcdclient.WithMetrics(metrics),
+		etcdclient.WithWatchRetry(etcdclient.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+	server.Stop()
+
+	if err := etcdClient.WatchRecursive("/watched", func(key, newValue string) {}); err == nil {
+		t.Error("WatchRecursive returned no error")
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	if metrics.reconnects["/watched"] != 2 {
+		t.Errorf("Observed %v reconnects, expected 2", metrics.reconnects["/watched"])
+	}
+}
@@ -0,0 +1,78 @@
+package etcdclient
+
+import (
+	"strings"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// Mirror copies everything under srcPrefix in src to dstPrefix in dst,
+// then keeps dst up to date by replicating every change made to src.
+// Values are copied with their TTLs, but TTLs are not replicated for
+// later changes.
+// This method only returns if there is an error
+func Mirror(src EtcdClient, srcPrefix string, dst EtcdClient, dstPrefix string) error {
+	index, err := src.Index()
+	if err != nil {
+		return err
+	}
+
+	data, err := src.Export(srcPrefix)
+	if err != nil {
+		return err
+	}
+
+	if err := dst.Import(dstPrefix, data, true); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	srcPrefix = normalizeKey(srcPrefix)
+	dstPrefix = normalizeKey(dstPrefix)
+
+	// events may be delivered concurrently, see WithWatchWorkers
+	var mutex sync.Mutex
+	var replicateErr error
+	err = src.WithContext(ctx).WatchEvents(srcPrefix, index, func(event Event) {
+		mutex.Lock()
+		failed := replicateErr != nil
+		mutex.Unlock()
+		if failed {
+			return
+		}
+
+		key := Join(dstPrefix, strings.TrimPrefix(normalizeKey(event.Key), srcPrefix))
+		if err := replicate(dst, key, event); err != nil {
+			mutex.Lock()
+			if replicateErr == nil {
+				replicateErr = err
+			}
+			mutex.Unlock()
+			cancel()
+		}
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if replicateErr != nil {
+		return replicateErr
+	}
+	return err
+}
+
+// replicate applies the event to key in dst
+func replicate(dst EtcdClient, key string, event Event) error {
+	switch {
+	case event.Removed() && event.Dir:
+		return dst.DelDir(key)
+	case event.Removed():
+		return dst.Del(key)
+	case event.Dir:
+		return dst.MkDir(key)
+	default:
+		return dst.Set(key, event.Value)
+	}
+}
@@ -0,0 +1,50 @@
+package etcdclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+func TestMirrorCopiesThenReplicatesChanges(t *testing.T) {
+	src := dial(t)
+	dst := dial(t)
+	setKeys(t, src, "/src/copied", "/src/removed")
+
+	mirrored := make(chan error, 1)
+	go func() {
+		mirrored <- etcdclient.Mirror(src, "/src", dst, "/dst")
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := dst.WaitForValue(ctx, "/dst/copied", "value"); err != nil {
+		t.Fatalf("The initial copy was not made: %v", err)
+	}
+
+	if err := src.Set("/src/dir/added", "added"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := src.Del("/src/removed"); err != nil {
+		t.Fatalf("Del returned %v", err)
+	}
+	if err := dst.WaitForValue(ctx, "/dst/dir/added", "added"); err != nil {
+		t.Errorf("A change was not replicated: %v", err)
+	}
+	for {
+		if value, err := dst.Get("/dst/removed"); err == nil && value == "" {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatal("A deletion was not replicated")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	src.Close()
+	if err := <-mirrored; err == nil {
+		t.Error("Mirror returned no error after the source was closed")
+	}
+}
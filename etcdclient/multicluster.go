@@ -0,0 +1,103 @@
+package etcdclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// FailoverMode decides which requests DialMultiCluster
+// sends to the secondary cluster
+type FailoverMode int
+
+const (
+	// FailoverReads only sends reads to the secondary cluster,
+	// writes fail while the primary cluster is unreachable
+	FailoverReads FailoverMode = iota
+
+	// FailoverAll sends reads and writes to the secondary cluster
+	FailoverAll
+)
+
+// FailoverCooldown is how long requests go to the secondary cluster
+// after the primary cluster could not be reached, before the primary
+// cluster is tried again
+var FailoverCooldown = 5 * time.Second
+
+// DialMultiCluster constructs a new EtcdClient for an active/passive pair of
+// clusters. Requests go to the primary cluster and, when it cannot be
+// reached, to the secondary cluster as set by mode, until the primary
+// cluster is tried again after FailoverCooldown. Watches always use the
+// primary cluster, since its indexes mean nothing to the secondary cluster
+func DialMultiCluster(primary, secondary []string, mode FailoverMode, opts ...Option) (EtcdClient, error) {
+	if len(primary) == 0 || len(secondary) == 0 {
+		return nil, errors.New("DialMultiCluster requires primary and secondary endpoints")
+	}
+
+	opts = append(opts, func(opts *options) {
+		opts.etcd.Endpoints = primary
+		opts.failover = &failover{endpoints: secondary, mode: mode}
+	})
+	return Dial(primary[0], opts...)
+}
+
+type failover struct {
+	endpoints []string
+	mode      FailoverMode
+	etcd      client.Client
+
+	mutex     sync.Mutex
+	downUntil time.Time
+}
+
+// connect creates the client for the secondary cluster, configured
+// like the client for the primary one
+func (failover *failover) connect(config client.Config) error {
+	config.Endpoints = failover.endpoints
+	etcd, err := client.New(config)
+	if err != nil {
+		return err
+	}
+	failover.etcd = etcd
+	return nil
+}
+
+// primaryDown returns true if the primary cluster was
+// unreachable less than FailoverCooldown ago
+func (failover *failover) primaryDown() bool {
+	failover.mutex.Lock()
+	defer failover.mutex.Unlock()
+	return time.Now().Before(failover.downUntil)
+}
+
+func (failover *failover) markPrimaryDown() {
+	failover.mutex.Lock()
+	defer failover.mutex.Unlock()
+	failover.downUntil = time.Now().Add(FailoverCooldown)
+}
+
+// route makes the request on the primary cluster, or on the secondary
+// cluster if the primary is unreachable and the mode allows it
+func (api *keysAPI) route(ctx context.Context, write bool, request func(keys client.KeysAPI) (*client.Response, error)) (*client.Response, error) {
+	failover := api.etcdClient.options.failover
+	if failover == nil || (write && failover.mode != FailoverAll) {
+		return request(api.KeysAPI)
+	}
+
+	secondary := client.NewKeysAPI(failover.etcd)
+	if failover.primaryDown() {
+		return request(secondary)
+	}
+
+	response, err := request(api.KeysAPI)
+	if !isUnreachable(err) || ctx.Err() != nil {
+		return response, err
+	}
+
+	api.etcdClient.options.log("failing over to the secondary cluster", "endpoints", failover.endpoints, "err", err)
+	failover.markPrimaryDown()
+	return request(secondary)
+}
@@ -0,0 +1,73 @@
+package etcdclient_test
+
+import (
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+// startClusters starts a primary and a secondary etcdtest.MemoryServer,
+// with /key set to the name of each, and returns a DialMultiCluster client
+func startClusters(t *testing.T, mode etcdclient.FailoverMode) (*etcdtest.MemoryServer, *etcdtest.MemoryServer, etcdclient.EtcdClient) {
+	clusters := make([]*etcdtest.MemoryServer, 2)
+	for i, name := range []string{"primary", "secondary"} {
+		server := etcdtest.StartMemory()
+		t.Cleanup(func() { server.Stop() })
+		etcd, err := server.Client()
+		if err != nil {
+			t.Fatalf("Client returned %v", err)
+		}
+		defer etcd.Close()
+		if err := etcd.Set("/key", name); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+		clusters[i] = server
+	}
+
+	etcd, err := etcdclient.DialMultiCluster([]string{clusters[0].URI}, []string{clusters[1].URI}, mode)
+	if err != nil {
+		t.Fatalf("DialMultiCluster returned %v", err)
+	}
+	t.Cleanup(func() { etcd.Close() })
+	return clusters[0], clusters[1], etcd
+}
+
+func TestDialMultiClusterFailsOverReads(t *testing.T) {
+	primary, _, etcd := startClusters(t, etcdclient.FailoverReads)
+
+	if value, err := etcd.Get("/key"); err != nil || value != "primary" {
+		t.Errorf("Get returned %q, %v, expected the primary cluster's value", value, err)
+	}
+
+	primary.Stop()
+	if value, err := etcd.Get("/key"); err != nil || value != "secondary" {
+		t.Errorf("Get with the primary cluster down returned %q, %v, expected the secondary cluster's value", value, err)
+	}
+	if err := etcd.Set("/key", "written"); err == nil {
+		t.Error("Set with the primary cluster down returned no error, expected FailoverReads to refuse writes")
+	}
+}
+
+func TestDialMultiClusterFailsOverEverything(t *testing.T) {
+	primary, secondary, etcd := startClusters(t, etcdclient.FailoverAll)
+	primary.Stop()
+
+	if err := etcd.Set("/key", "written"); err != nil {
+		t.Fatalf("Set with the primary cluster down returned %v", err)
+	}
+	direct, err := secondary.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer direct.Close()
+	if value, _ := direct.Get("/key"); value != "written" {
+		t.Errorf("The secondary cluster has %q, expected the write", value)
+	}
+}
+
+func TestDialMultiClusterRequiresBothClusters(t *testing.T) {
+	if _, err := etcdclient.DialMultiCluster([]string{"http://localhost:2379"}, nil, etcdclient.FailoverAll); err == nil {
+		t.Error("DialMultiCluster without secondary endpoints returned no error")
+	}
+}
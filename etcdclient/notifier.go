@@ -0,0 +1,145 @@
+package etcdclient
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// NotifierBackoff is how a Notifier waits before restarting its shared
+// watch after it fails. It is restarted for as long as there are subscribers
+var NotifierBackoff = RetryPolicy{MaxRetries: -1, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 10 * time.Second}
+
+// Notifier shares a single watch of a directory between many subscribers,
+// instead of every subscriber opening its own watch. The watch starts with
+// the first subscriber and stops when the last one unsubscribes
+type Notifier struct {
+	etcdClient *SimpleEtcdClient
+	directory  string
+
+	mutex       sync.Mutex
+	subscribers map[Subscription]OnEventCallback
+	next        Subscription
+	cancel      context.CancelFunc
+
+	// generation counts the watches started, so a watch that stops
+	// only clears cancel if no newer watch replaced it
+	generation int
+}
+
+// Subscription identifies a subscriber of a Notifier
+type Subscription int
+
+// Notifier returns a notifier for the directory
+func (etcdClient *SimpleEtcdClient) Notifier(directory string) *Notifier {
+	return &Notifier{
+		etcdClient:  etcdClient,
+		directory:   directory,
+		subscribers: make(map[Subscription]OnEventCallback),
+	}
+}
+
+// Subscribe calls onEvent for every change in the directory from now on,
+// until Unsubscribe is called. The subscribers of an event are called one
+// after the other
+func (notifier *Notifier) Subscribe(onEvent OnEventCallback) Subscription {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	notifier.next++
+	notifier.subscribers[notifier.next] = onEvent
+	if notifier.cancel == nil {
+		notifier.start()
+	}
+	return notifier.next
+}
+
+// Unsubscribe stops calling the subscriber
+func (notifier *Notifier) Unsubscribe(subscription Subscription) {
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+
+	delete(notifier.subscribers, subscription)
+	if len(notifier.subscribers) == 0 && notifier.cancel != nil {
+		notifier.cancel()
+		notifier.cancel = nil
+	}
+}
+
+// start starts the shared watch, the mutex must be held. The watch is
+// restarted after the last event it saw, with NotifierBackoff, until the
+// last subscriber unsubscribes or the client is closed
+func (notifier *Notifier) start() {
+	ctx, cancel := context.WithCancel(notifier.etcdClient.ctx)
+	notifier.cancel = cancel
+	notifier.generation++
+	generation := notifier.generation
+	watchClient := notifier.etcdClient.WithContext(ctx).(*SimpleEtcdClient)
+
+	notifier.etcdClient.goBackground(func() {
+		defer notifier.stopped(generation, cancel)
+
+		// events may be delivered concurrently, see WithWatchWorkers
+		var mutex sync.Mutex
+		var afterIndex uint64
+		attempt := 0
+		onEvent := func(event Event) {
+			mutex.Lock()
+			if event.Index > afterIndex {
+				afterIndex = event.Index
+			}
+			attempt = 0
+			mutex.Unlock()
+			notifier.notify(event)
+		}
+
+		for {
+			mutex.Lock()
+			from := afterIndex
+			mutex.Unlock()
+
+			err := watchClient.watchRecursive(notifier.directory, from, true, onEvent)
+			if watchClient.stopped() {
+				return
+			}
+
+			mutex.Lock()
+			attempt++
+			backoff := NotifierBackoff.backoff(attempt)
+			mutex.Unlock()
+			notifier.etcdClient.options.log("notifier watch failed, restarting", "directory", notifier.directory, "backoff", backoff, "err", err)
+			if notifier.etcdClient.options.onWatchError != nil {
+				notifier.etcdClient.options.onWatchError(err)
+			}
+			if watchClient.sleep(backoff) != nil {
+				return
+			}
+		}
+	})
+}
+
+// stopped clears cancel once the watch of the generation stopped, unless
+// a newer watch was started, so the next subscriber starts a new one
+func (notifier *Notifier) stopped(generation int, cancel context.CancelFunc) {
+	cancel()
+
+	notifier.mutex.Lock()
+	defer notifier.mutex.Unlock()
+	if notifier.generation == generation {
+		notifier.cancel = nil
+	}
+}
+
+func (notifier *Notifier) notify(event Event) {
+	notifier.mutex.Lock()
+	subscribers := make([]OnEventCallback, 0, len(notifier.subscribers))
+	for _, onEvent := range notifier.subscribers {
+		subscribers = append(subscribers, onEvent)
+	}
+	notifier.mutex.Unlock()
+
+	for _, onEvent := range subscribers {
+		onEvent(event)
+	}
+}
@@ -0,0 +1,70 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestNotifierSharesOneWatch(t *testing.T) {
+	recording := &etcdclient.Recording{}
+	etcdClient := dial(t, etcdclient.WithRecorder(recording))
+	notifier := etcdClient.Notifier("/notified")
+
+	first, second := make(chan string, 10), make(chan string, 10)
+	notifier.Subscribe(func(event etcdclient.Event) { first <- event.Key })
+	subscription := notifier.Subscribe(func(event etcdclient.Event) { second <- event.Key })
+	time.Sleep(100 * time.Millisecond)
+
+	if err := etcdClient.Set("/notified/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if key := receive(t, first); key != "/notified/key" {
+		t.Errorf("The first subscriber got %v, expected /notified/key", key)
+	}
+	if key := receive(t, second); key != "/notified/key" {
+		t.Errorf("The second subscriber got %v, expected /notified/key", key)
+	}
+	watches := 0
+	for _, url := range recordedGets(recording, "/notified") {
+		if strings.Contains(url, "wait=true") {
+			watches++
+		}
+	}
+	if watches != 1 {
+		t.Errorf("The subscribers made %v watch requests for one change, expected them to share 1", watches)
+	}
+
+	notifier.Unsubscribe(subscription)
+	if err := etcdClient.Set("/notified/other", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	receive(t, first)
+	select {
+	case key := <-second:
+		t.Errorf("An unsubscribed subscriber got %v", key)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestNotifierRestartsAfterTheLastSubscriberLeft(t *testing.T) {
+	etcdClient := dial(t)
+	notifier := etcdClient.Notifier("/notified")
+
+	notifier.Unsubscribe(notifier.Subscribe(func(event etcdclient.Event) {
+		t.Errorf("An unsubscribed subscriber got %v", event.Key)
+	}))
+	time.Sleep(100 * time.Millisecond)
+
+	events := make(chan string, 10)
+	notifier.Subscribe(func(event etcdclient.Event) { events <- event.Key })
+	time.Sleep(100 * time.Millisecond)
+	if err := etcdClient.Set("/notified/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if key := receive(t, events); key != "/notified/key" {
+		t.Errorf("The new subscriber got %v, expected /notified/key", key)
+	}
+}
@@ -0,0 +1,239 @@
+package etcdclient
+
+import (
+	"fmt"
+	"math"
+	"net/url"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Option configures the client constructed by Dial
+type Option func(*options)
+
+// RetryPolicy controls how watches reconnect after an error
+type RetryPolicy struct {
+	// MaxRetries is the number of consecutive errors a watch will
+	// retry before giving up. A negative value retries forever
+	MaxRetries int
+
+	// InitialBackoff is how long to wait before the first retry.
+	// The wait doubles on every consecutive error
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between retries, 0 leaves it uncapped
+	MaxBackoff time.Duration
+}
+
+// OnErrorCallback is used for passing error callbacks to
+// WithWatchErrorHandler
+type OnErrorCallback func(err error)
+
+type options struct {
+	etcd         client.Config
+	watchRetry   RetryPolicy
+	onWatchError OnErrorCallback
+	watchdog     time.Duration
+	autoSync     time.Duration
+	srvDomain    string
+	metrics      Metrics
+	logger       Logger
+	tracer       Tracer
+	singleflight *singleflight
+	rateLimiter  *rateLimiter
+	scheduler    *scheduler
+	breaker      *circuitBreaker
+	quorumReads  bool
+	readOnly     bool
+	validateKeys bool
+	proxy        *url.URL
+	recording    *Recording
+	replay       *Recording
+	watchBuffer  int
+	bufferPolicy BufferPolicy
+	watchWorkers int
+	valueCodecs  []valueCodec
+	validators   []validator
+	codec        Codec
+	prefixCodecs []prefixCodec
+	freezes      *freezeCache
+
+	slowRequestThreshold time.Duration
+	onSlowRequest        OnSlowRequestCallback
+
+	fallback *fallbackSnapshot
+	failover *failover
+
+	// connected is 1 if the last request reached etcd, accessed atomically
+	connected int32
+
+	// err is set by options that could not be applied, Dial returns it
+	err error
+}
+
+// WithWatchRetry makes watches reconnect according to the policy
+// instead of returning on the first error
+func WithWatchRetry(policy RetryPolicy) Option {
+	return func(opts *options) {
+		opts.watchRetry = policy
+	}
+}
+
+// WithWatchErrorHandler calls onError for every error a watch
+// encounters, including the ones that are retried
+func WithWatchErrorHandler(onError OnErrorCallback) Option {
+	return func(opts *options) {
+		opts.onWatchError = onError
+	}
+}
+
+// WithWatchWatchdog makes watches reconnect if they see no event for the
+// period, since a watch on a half-open connection can otherwise hang
+// forever. Etcd sends nothing on a watch until there is an event, so
+// the period should be longer than quiet directories usually stay quiet.
+// Watches resume after the last event they saw, or the index they started
+// at if they saw none, which etcd only remembers for its last 1000 events
+// across the whole cluster. If a quiet directory
+// reconnects after more changes than that elsewhere, watches that skip
+// cleared events, like WatchRecursive, miss what changed in between, and
+// WatchEvents and WatchRecursiveFrom return the EventIndexCleared error.
+// Every reconnect is logged and reported to ObserveWatchReconnect
+func WithWatchWatchdog(period time.Duration) Option {
+	return func(opts *options) {
+		opts.watchdog = period
+	}
+}
+
+// WithAutoSync makes the client refresh its list of endpoints from the
+// cluster membership every interval, so members added or removed after
+// Dial are picked up without a restart
+func WithAutoSync(interval time.Duration) Option {
+	return func(opts *options) {
+		opts.autoSync = interval
+	}
+}
+
+// WithSRVDiscovery makes Dial look up the etcd endpoints from the DNS SRV
+// records of the domain instead of using the uri it was given. Dialing
+// "srv://<domain>" does the same thing
+func WithSRVDiscovery(domain string) Option {
+	return func(opts *options) {
+		opts.srvDomain = domain
+	}
+}
+
+// WithQuorumReads makes every read a quorum read, which always returns the
+// latest committed value, like GetLinearizable. Quorum reads go through the
+// leader, so they are slower than the default local reads
+func WithQuorumReads() Option {
+	return func(opts *options) {
+		opts.quorumReads = true
+	}
+}
+
+// WithReadOnly makes the client refuse every write, including deletes and
+// creating directories, with ErrReadOnly. Raw returns the underlying etcd
+// client, which is not restricted, so do not hand it to code that should
+// only read
+func WithReadOnly() Option {
+	return func(opts *options) {
+		opts.readOnly = true
+	}
+}
+
+// WithKeyValidation makes the client check every key it writes or deletes
+// with ValidateKey, returning its error instead of making the request
+func WithKeyValidation() Option {
+	return func(opts *options) {
+		opts.validateKeys = true
+	}
+}
+
+// ValidatorFunc checks a value before it is written to key
+type ValidatorFunc func(key, value string) error
+
+type validator struct {
+	prefix string
+	fn     ValidatorFunc
+}
+
+// WithValidator makes the client call fn with every value it writes to the
+// prefix or a key under it, and return the error fn returns instead of making
+// the request. It can be used more than once, every matching validator must
+// accept the value. Directories and TTL refreshes have no value to check
+func WithValidator(prefix string, fn ValidatorFunc) Option {
+	return func(opts *options) {
+		opts.validators = append(opts.validators, validator{normalizeKey(prefix), fn})
+	}
+}
+
+// WithTransport makes the client send its requests through transport, for
+// example one with a higher MaxIdleConnsPerHost for clients making many
+// concurrent requests, which otherwise keep reconnecting to etcd. Close
+// closes the idle connections of the transport if it supports it
+func WithTransport(transport client.CancelableTransport) Option {
+	return func(opts *options) {
+		opts.etcd.Transport = transport
+	}
+}
+
+// WithHeaderTimeoutPerRequest fails a request to an endpoint if it does not
+// respond within timeout, so the next endpoint is tried. It does not apply
+// to watches, which wait for changes indefinitely
+func WithHeaderTimeoutPerRequest(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.etcd.HeaderTimeoutPerRequest = timeout
+	}
+}
+
+// WithProxy makes the client connect to etcd through the proxy at
+// proxyURL, an http, https or socks5 url. Without it, the proxy set in the
+// HTTPS_PROXY and HTTP_PROXY environment variables is used
+func WithProxy(proxyURL string) Option {
+	return func(opts *options) {
+		proxy, err := url.Parse(proxyURL)
+		if err != nil {
+			opts.err = fmt.Errorf("Invalid proxy url %v: %v", proxyURL, err)
+			return
+		}
+
+		switch proxy.Scheme {
+		default:
+			opts.err = fmt.Errorf("Unsupported proxy scheme %q, expected http, https or socks5", proxy.Scheme)
+		case "http", "https", "socks5":
+			opts.proxy = proxy
+		}
+	}
+}
+
+// WithBasicAuth makes the client authenticate as the user,
+// which etcd requires once auth has been enabled
+func WithBasicAuth(username, password string) Option {
+	return func(opts *options) {
+		opts.etcd.Username = username
+		opts.etcd.Password = password
+	}
+}
+
+// backoff returns how long to wait before the given retry attempt,
+// starting at 1
+func (policy RetryPolicy) backoff(attempt int) time.Duration {
+	wait := policy.InitialBackoff
+	for i := 1; i < attempt && (policy.MaxBackoff <= 0 || wait < policy.MaxBackoff); i++ {
+		if wait > math.MaxInt64/2 {
+			return time.Duration(math.MaxInt64)
+		}
+		wait *= 2
+	}
+	if policy.MaxBackoff > 0 && wait > policy.MaxBackoff {
+		return policy.MaxBackoff
+	}
+	return wait
+}
+
+// shouldRetry returns true if a watch that has failed attempt
+// consecutive times should try again
+func (policy RetryPolicy) shouldRetry(attempt int) bool {
+	return policy.MaxRetries < 0 || attempt <= policy.MaxRetries
+}
@@ -0,0 +1,48 @@
+package etcdclient
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	for _, test := range []struct {
+		policy   RetryPolicy
+		attempt  int
+		expected time.Duration
+	}{
+		{RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, 1, time.Second},
+		{RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, 2, 2 * time.Second},
+		{RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, 4, 8 * time.Second},
+		{RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, 7, time.Minute},
+		{RetryPolicy{InitialBackoff: time.Second, MaxBackoff: time.Minute}, 100, time.Minute},
+		{RetryPolicy{InitialBackoff: time.Second}, 1, time.Second},
+		{RetryPolicy{InitialBackoff: time.Second}, 3, 4 * time.Second},
+		{RetryPolicy{InitialBackoff: time.Second}, 11, 1024 * time.Second},
+		{RetryPolicy{InitialBackoff: time.Second}, 1000, time.Duration(math.MaxInt64)},
+		{RetryPolicy{InitialBackoff: 3 * time.Second, MaxBackoff: 2 * time.Second}, 1, 2 * time.Second},
+		{RetryPolicy{}, 5, 0},
+	} {
+		if backoff := test.policy.backoff(test.attempt); backoff != test.expected {
+			t.Errorf("%+v.backoff(%v) returned %v, expected %v", test.policy, test.attempt, backoff, test.expected)
+		}
+	}
+}
+
+func TestRetryPolicyShouldRetry(t *testing.T) {
+	for _, test := range []struct {
+		policy   RetryPolicy
+		attempt  int
+		expected bool
+	}{
+		{RetryPolicy{}, 1, false},
+		{RetryPolicy{MaxRetries: 2}, 2, true},
+		{RetryPolicy{MaxRetries: 2}, 3, false},
+		{RetryPolicy{MaxRetries: -1}, 1000, true},
+	} {
+		if retry := test.policy.shouldRetry(test.attempt); retry != test.expected {
+			t.Errorf("%+v.shouldRetry(%v) returned %v, expected %v", test.policy, test.attempt, retry, test.expected)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	"github.com/coreos/etcd/client"
+)
+
+// PlanAction is what a bulk operation would do to a single key
+type PlanAction string
+
+const (
+	// PlanCreate creates a key or directory that does not exist
+	PlanCreate PlanAction = "create"
+
+	// PlanUpdate replaces the value of an existing key
+	PlanUpdate PlanAction = "update"
+
+	// PlanDelete deletes a key or directory
+	PlanDelete PlanAction = "delete"
+)
+
+// PlannedOp is a single change a bulk operation would make
+type PlannedOp struct {
+	Action PlanAction `json:"action"`
+	Key    string     `json:"key"`
+	Dir    bool       `json:"dir,omitempty"`
+
+	// OldValue is the value before the change, empty for creates
+	OldValue string `json:"oldValue,omitempty"`
+
+	// NewValue is the value after the change, empty for deletes
+	NewValue string `json:"newValue,omitempty"`
+}
+
+// String describes the change as a line of a diff
+func (op PlannedOp) String() string {
+	name := op.Key
+	if op.Dir {
+		name += "/"
+	}
+
+	switch op.Action {
+	case PlanCreate:
+		if op.Dir {
+			return fmt.Sprintf("+ %v", name)
+		}
+		return fmt.Sprintf("+ %v = %q", name, op.NewValue)
+	case PlanUpdate:
+		return fmt.Sprintf("~ %v = %q -> %q", name, op.OldValue, op.NewValue)
+	default:
+		if op.Dir {
+			return fmt.Sprintf("- %v", name)
+		}
+		return fmt.Sprintf("- %v (was %q)", name, op.OldValue)
+	}
+}
+
+// Plan is the list of changes a bulk operation would make, in order
+type Plan []PlannedOp
+
+// PlanImport returns the changes Import would make with the same
+// arguments, without writing anything. Keys that would be written with
+// the value they already have are left out
+func (etcdClient *SimpleEtcdClient) PlanImport(directory string, data []byte, overwrite bool) (Plan, error) {
+	var export Export
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, err
+	}
+
+	current, err := etcdClient.nodesByKey(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := make(Plan, 0)
+	for _, node := range export.Nodes {
+		key := normalizeKey(path.Join(directory, node.Key))
+		existing, ok := current[key]
+
+		switch {
+		case !ok:
+			plan = append(plan, PlannedOp{Action: PlanCreate, Key: key, Dir: node.Dir, NewValue: node.Value})
+			current[key] = &client.Node{Key: key, Dir: node.Dir, Value: node.Value}
+		case overwrite && !node.Dir && existing.Value != node.Value:
+			plan = append(plan, PlannedOp{Action: PlanUpdate, Key: key, OldValue: existing.Value, NewValue: node.Value})
+		}
+	}
+	return plan, nil
+}
+
+// PlanMirror returns the changes Mirror would make to dst when it first
+// copies srcPrefix to dstPrefix, without writing anything. Changes Mirror
+// replicates afterwards cannot be known in advance
+func PlanMirror(src EtcdClient, srcPrefix string, dst *SimpleEtcdClient, dstPrefix string) (Plan, error) {
+	data, err := src.Export(srcPrefix)
+	if err != nil {
+		return nil, err
+	}
+	return dst.PlanImport(dstPrefix, data, true)
+}
+
+// PlanDelPrefix returns the keys and directories DelPrefix would
+// delete under the prefix, without deleting anything
+func (etcdClient *SimpleEtcdClient) PlanDelPrefix(prefix string) (Plan, error) {
+	plan := make(Plan, 0)
+	err := etcdClient.walkNodes(prefix, func(node *client.Node) error {
+		plan = append(plan, PlannedOp{Action: PlanDelete, Key: node.Key, Dir: node.Dir, OldValue: node.Value})
+		return nil
+	})
+	return plan, err
+}
+
+// nodesByKey returns every node in the directory, recursively, by key
+func (etcdClient *SimpleEtcdClient) nodesByKey(directory string) (map[string]*client.Node, error) {
+	nodes := make(map[string]*client.Node)
+
+	api := etcdClient.keysAPI()
+	response, err := api.Get(etcdClient.ctx, directory, &client.GetOptions{Recursive: true})
+	if err != nil {
+		if isKeyNotFound(err) {
+			return nodes, nil
+		}
+		return nil, err
+	}
+
+	for _, node := range flattenNodes(response.Node.Nodes) {
+		nodes[node.Key] = node
+	}
+	return nodes, nil
+}
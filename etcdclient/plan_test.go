@@ -0,0 +1,73 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// planLines describes every change in the plan as a line of a diff,
+// or the error of the planning
+func planLines(plan etcdclient.Plan, err error) []string {
+	if err != nil {
+		return []string{"error: " + err.Error()}
+	}
+	lines := make([]string, 0, len(plan))
+	for _, op := range plan {
+		lines = append(lines, op.String())
+	}
+	return lines
+}
+
+func TestPlanImport(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/dst/changed", "old"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Set("/dst/same", "same"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	data := []byte(`{"directory": "/src", "nodes": [
+		{"key": "/changed", "value": "new"},
+		{"key": "/same", "value": "same"},
+		{"key": "/dir", "dir": true},
+		{"key": "/dir/created", "value": "created"}
+	]}`)
+
+	expected := []string{`~ /dst/changed = "old" -> "new"`, `+ /dst/dir/`, `+ /dst/dir/created = "created"`}
+	if lines := planLines(etcdClient.PlanImport("/dst", data, true)); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("PlanImport returned %q, expected %q", lines, expected)
+	}
+	expected = []string{`+ /dst/dir/`, `+ /dst/dir/created = "created"`}
+	if lines := planLines(etcdClient.PlanImport("/dst", data, false)); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("PlanImport without overwrite returned %q, expected %q", lines, expected)
+	}
+	if value, _ := etcdClient.Get("/dst/changed"); value != "old" {
+		t.Errorf("PlanImport wrote %q", value)
+	}
+}
+
+func TestPlanMirror(t *testing.T) {
+	src, dst := dial(t), dial(t)
+	setKeys(t, src, "/src/a", "/src/b")
+	setKeys(t, dst, "/dst/a")
+
+	expected := []string{`+ /dst/b = "value"`}
+	if lines := planLines(etcdclient.PlanMirror(src, "/src", dst, "/dst")); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("PlanMirror returned %q, expected %q", lines, expected)
+	}
+}
+
+func TestPlanDelPrefix(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/doomed/a", "/doomed/dir/b", "/kept")
+
+	expected := []string{`- /doomed/a (was "value")`, `- /doomed/dir/`, `- /doomed/dir/b (was "value")`}
+	if lines := planLines(etcdClient.PlanDelPrefix("/doomed")); !reflect.DeepEqual(lines, expected) {
+		t.Errorf("PlanDelPrefix returned %q, expected %q", lines, expected)
+	}
+	if value, _ := etcdClient.Get("/doomed/a"); value != "value" {
+		t.Error("PlanDelPrefix deleted a key")
+	}
+}
@@ -0,0 +1,85 @@
+package etcdclient
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// WithRateLimit limits the client to rps requests per second on average,
+// allowing bursts of up to burst requests. Requests over the limit wait for
+// their turn, or fail if their context is done first. Use WithContext to
+// give requests a deadline. An rps of 0 or less disables the limit
+func WithRateLimit(rps int, burst int) Option {
+	return func(opts *options) {
+		opts.rateLimiter = nil
+		if rps > 0 {
+			opts.rateLimiter = newRateLimiter(rps, burst)
+		}
+	}
+}
+
+// rateLimiter is a token bucket
+type rateLimiter struct {
+	mutex  sync.Mutex
+	rps    float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(rps, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{rps: float64(rps), burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait takes a token, waiting for one to be available
+// if there are none. It returns early with an error if
+// ctx is done, or will be before a token is available
+func (limiter *rateLimiter) wait(ctx context.Context) error {
+	delay := limiter.reserve()
+	if delay <= 0 {
+		return nil
+	}
+
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(delay).After(deadline) {
+		limiter.refund()
+		return context.DeadlineExceeded
+	}
+
+	select {
+	case <-ctx.Done():
+		limiter.refund()
+		return ctx.Err()
+	case <-time.After(delay):
+		return nil
+	}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (limiter *rateLimiter) reserve() time.Duration {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+	limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rps
+	if limiter.tokens > limiter.burst {
+		limiter.tokens = limiter.burst
+	}
+	limiter.last = now
+	limiter.tokens--
+
+	if limiter.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-limiter.tokens / limiter.rps * float64(time.Second))
+}
+
+func (limiter *rateLimiter) refund() {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	limiter.tokens++
+}
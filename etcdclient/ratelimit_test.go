@@ -0,0 +1,57 @@
+package etcdclient
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestRateLimiterAllowsABurstThenWaits(t *testing.T) {
+	limiter := newRateLimiter(20, 2)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := limiter.wait(context.Background()); err != nil {
+			t.Fatalf("wait returned %v", err)
+		}
+	}
+	// the burst is free, the third request waits for a token at 20 per second
+	if took := time.Since(start); took < 40*time.Millisecond || took > time.Second {
+		t.Errorf("3 requests with a burst of 2 took %v, expected about 50ms", took)
+	}
+}
+
+func TestRateLimiterFailsRequestsThatWouldMissTheirDeadline(t *testing.T) {
+	limiter := newRateLimiter(1, 1)
+	if err := limiter.wait(context.Background()); err != nil {
+		t.Fatalf("wait returned %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := limiter.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("wait returned %v, expected DeadlineExceeded", err)
+	}
+	if took := time.Since(start); took > 100*time.Millisecond {
+		t.Errorf("wait took %v to fail, expected it to fail without waiting for a token", took)
+	}
+
+	// the failed request gave its token back
+	limiter.mutex.Lock()
+	tokens := limiter.tokens
+	limiter.mutex.Unlock()
+	if tokens < -0.1 || tokens > 0.1 {
+		t.Errorf("The limiter has %v tokens, expected the failed request to refund its token", tokens)
+	}
+}
+
+func TestWithRateLimitOfZeroDisablesTheLimit(t *testing.T) {
+	opts := &options{}
+	WithRateLimit(10, 1)(opts)
+	WithRateLimit(0, 1)(opts)
+	if opts.rateLimiter != nil {
+		t.Error("WithRateLimit(0) left a rate limiter")
+	}
+}
@@ -0,0 +1,27 @@
+package etcdclient
+
+import "github.com/coreos/etcd/client"
+
+// Raw returns the underlying etcd client, for anything this package
+// does not expose
+func (etcdClient *SimpleEtcdClient) Raw() client.Client {
+	return etcdClient.etcd
+}
+
+// RawKeysAPI returns the KeysAPI the client uses. Requests made
+// through it are still reported to the client's hooks
+func (etcdClient *SimpleEtcdClient) RawKeysAPI() client.KeysAPI {
+	return etcdClient.keysAPI()
+}
+
+// GetResponse gets a key in Etcd and returns the full response
+func (etcdClient *SimpleEtcdClient) GetResponse(key string, opts *client.GetOptions) (*client.Response, error) {
+	api := etcdClient.keysAPI()
+	return api.Get(etcdClient.ctx, key, opts)
+}
+
+// SetResponse sets a key in Etcd and returns the full response
+func (etcdClient *SimpleEtcdClient) SetResponse(key, value string, opts *client.SetOptions) (*client.Response, error) {
+	api := etcdClient.keysAPI()
+	return api.Set(etcdClient.ctx, key, value, opts)
+}
@@ -0,0 +1,55 @@
+package etcdclient_test
+
+import (
+	"testing"
+
+	"github.com/coreos/etcd/client"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+func TestSetResponseAndGetResponse(t *testing.T) {
+	etcdClient := dial(t)
+
+	set, err := etcdClient.SetResponse("/raw/key", "value", &client.SetOptions{PrevExist: client.PrevNoExist})
+	if err != nil {
+		t.Fatalf("SetResponse returned %v", err)
+	}
+	if set.Action != "create" || set.Node.Value != "value" {
+		t.Errorf("SetResponse returned %v of %q, expected a create of \"value\"", set.Action, set.Node.Value)
+	}
+
+	got, err := etcdClient.GetResponse("/raw", &client.GetOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("GetResponse returned %v", err)
+	}
+	if !got.Node.Dir || len(got.Node.Nodes) != 1 || got.Node.Nodes[0].ModifiedIndex != set.Node.ModifiedIndex {
+		t.Errorf("GetResponse returned %+v, expected the directory with the key", got.Node)
+	}
+}
+
+func TestRawKeysAPIReportsToTheHooks(t *testing.T) {
+	metrics := newRecordedMetrics()
+	etcdClient := dial(t, etcdclient.WithMetrics(metrics))
+
+	if _, err := etcdClient.RawKeysAPI().Set(context.Background(), "/raw/key", "value", nil); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value, _ := etcdClient.Get("/raw/key"); value != "value" {
+		t.Errorf("Get returned %q, expected \"value\"", value)
+	}
+
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	if metrics.requests["set"] != 1 {
+		t.Errorf("Observed %v sets, expected the raw Set", metrics.requests["set"])
+	}
+}
+
+func TestRaw(t *testing.T) {
+	etcdClient := dial(t)
+
+	if endpoints := etcdClient.Raw().Endpoints(); len(endpoints) != 1 || endpoints[0] != etcdClient.Endpoints()[0] {
+		t.Errorf("Raw has the endpoints %v, expected %v", endpoints, etcdClient.Endpoints())
+	}
+}
@@ -0,0 +1,213 @@
+package etcdclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/coreos/etcd/client"
+)
+
+// Recording holds the requests a client made to etcd and the responses it
+// got, see WithRecorder and WithReplay. It can be saved as a fixture file
+type Recording struct {
+	Interactions []Interaction
+
+	mutex  sync.Mutex
+	played []bool
+}
+
+// Interaction is a single request and its response
+type Interaction struct {
+	Method       string
+	URL          string
+	Body         string `json:",omitempty"`
+	Status       int
+	Header       http.Header `json:",omitempty"`
+	ResponseBody string
+}
+
+// LoadRecording reads a recording saved with Save
+func LoadRecording(path string) (*Recording, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := &Recording{}
+	if err := json.Unmarshal(data, recording); err != nil {
+		return nil, fmt.Errorf("Failed to parse recording %v: %v", path, err)
+	}
+	return recording, nil
+}
+
+// Save writes the recording to a fixture file
+func (recording *Recording) Save(path string) error {
+	recording.mutex.Lock()
+	defer recording.mutex.Unlock()
+
+	data, err := json.MarshalIndent(recording, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, data, 0644)
+}
+
+// WithRecorder records every request made to etcd, and its response, in
+// recording. Requests that fail to reach etcd are not recorded. It wraps
+// the transport of WithTransport and WithProxy, whatever their order
+func WithRecorder(recording *Recording) Option {
+	return func(opts *options) {
+		opts.recording = recording
+	}
+}
+
+// WithReplay answers requests with the responses in recording instead of
+// contacting etcd. Each request is matched, in order, with the first
+// interaction of the same method and path that has not been replayed yet,
+// so the hosts in the recording do not matter. Bodies are not compared,
+// they may differ between runs, for example once encrypted. WithReplay
+// replaces the transport, WithTransport and WithProxy are ignored
+func WithReplay(recording *Recording) Option {
+	return func(opts *options) {
+		opts.replay = recording
+	}
+}
+
+// wrapTransport applies WithRecorder and WithReplay to the transport,
+// once every other option has configured it
+func (opts *options) wrapTransport() {
+	if opts.recording != nil {
+		opts.etcd.Transport = &recordingTransport{opts.etcd.Transport, opts.recording}
+	}
+	if opts.replay != nil {
+		opts.etcd.Transport = &replayTransport{opts.replay}
+	}
+}
+
+func (recording *Recording) record(interaction Interaction) {
+	recording.mutex.Lock()
+	defer recording.mutex.Unlock()
+
+	recording.Interactions = append(recording.Interactions, interaction)
+}
+
+// replay returns the first interaction matching the
+// request that has not been replayed yet
+func (recording *Recording) replay(method, requestURI string) (Interaction, bool) {
+	recording.mutex.Lock()
+	defer recording.mutex.Unlock()
+
+	if recording.played == nil {
+		recording.played = make([]bool, len(recording.Interactions))
+	}
+
+	for i, interaction := range recording.Interactions {
+		if recording.played[i] || interaction.Method != method {
+			continue
+		}
+		if requestPath(interaction.URL) != requestURI {
+			continue
+		}
+		recording.played[i] = true
+		return interaction, true
+	}
+	return Interaction{}, false
+}
+
+type recordingTransport struct {
+	client.CancelableTransport
+	recording *Recording
+}
+
+func (transport *recordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	body, err := readRequestBody(request)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := transport.CancelableTransport.RoundTrip(request)
+	if err != nil {
+		return nil, err
+	}
+
+	responseBody, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(responseBody))
+
+	transport.recording.record(Interaction{
+		Method:       request.Method,
+		URL:          request.URL.String(),
+		Body:         body,
+		Status:       response.StatusCode,
+		Header:       response.Header,
+		ResponseBody: string(responseBody),
+	})
+	return response, nil
+}
+
+func (transport *recordingTransport) CloseIdleConnections() {
+	if closer, ok := transport.CancelableTransport.(idleConnectionCloser); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+type replayTransport struct {
+	recording *Recording
+}
+
+func (transport *replayTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	if request.Body != nil {
+		request.Body.Close()
+	}
+
+	interaction, ok := transport.recording.replay(request.Method, request.URL.RequestURI())
+	if !ok {
+		return nil, fmt.Errorf("No recorded response for %v %v", request.Method, request.URL.RequestURI())
+	}
+
+	return &http.Response{
+		Status:     fmt.Sprintf("%v %v", interaction.Status, http.StatusText(interaction.Status)),
+		StatusCode: interaction.Status,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     interaction.Header,
+		Body:       ioutil.NopCloser(bytes.NewBufferString(interaction.ResponseBody)),
+		Request:    request,
+	}, nil
+}
+
+func (transport *replayTransport) CancelRequest(request *http.Request) {}
+
+// readRequestBody reads the body of the request and
+// replaces it so the request can still be sent
+func readRequestBody(request *http.Request) (string, error) {
+	if request.Body == nil {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return string(body), nil
+}
+
+// requestPath returns the path and query of a recorded url
+func requestPath(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return rawURL
+	}
+	return parsed.RequestURI()
+}
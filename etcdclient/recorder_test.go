@@ -0,0 +1,51 @@
+package etcdclient_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestRecorderAndProxyInEitherOrder(t *testing.T) {
+	recording := &etcdclient.Recording{}
+	orders := [][]etcdclient.Option{
+		{etcdclient.WithRecorder(recording), etcdclient.WithProxy("http://127.0.0.1:3128")},
+		{etcdclient.WithProxy("http://127.0.0.1:3128"), etcdclient.WithRecorder(recording)},
+	}
+	for _, opts := range orders {
+		etcdClient, err := etcdclient.Dial("http://127.0.0.1:2379", opts...)
+		if err != nil {
+			t.Errorf("Dial returned %v", err)
+			continue
+		}
+		etcdClient.Close()
+	}
+}
+
+func TestReplayOfEncryptedRequests(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	server := etcdtest.StartMemory()
+	defer server.Stop()
+
+	recording := &etcdclient.Recording{}
+	recorded, err := server.Client(etcdclient.WithEncryption(key), etcdclient.WithRecorder(recording))
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	if err := recorded.Set("/secret", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	recorded.Close()
+
+	// every encryption of the value differs, so the bodies do too
+	replayed, err := etcdclient.Dial(server.URI, etcdclient.WithEncryption(key), etcdclient.WithReplay(recording))
+	if err != nil {
+		t.Fatalf("Dial returned %v", err)
+	}
+	defer replayed.Close()
+	if err := replayed.Set("/secret", "value"); err != nil {
+		t.Errorf("Replayed Set returned %v", err)
+	}
+}
@@ -0,0 +1,78 @@
+package etcdclient
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// refreshJitter is the share of the interval by which ScheduleRefresh
+// moves every refresh, so many clients started together spread out
+const refreshJitter = 0.1
+
+// ScheduleRefresh refreshes the TTL of an existing key or directory right
+// away, then again every interval, give or take up to a tenth of it, until
+// stop is called or the client is closed. Refreshes do not change the value
+// or fire watch events. Failed refreshes are logged and retried at the next
+// interval, the schedule ends if the key is deleted. An error is returned,
+// and nothing is scheduled, if the first refresh fails
+func (etcdClient *SimpleEtcdClient) ScheduleRefresh(key string, ttl, interval time.Duration) (func(), error) {
+	node, err := etcdClient.getNode(key)
+	if err != nil {
+		return nil, err
+	}
+	if node == nil {
+		return nil, wrapError("refresh", key, ErrKeyNotFound)
+	}
+
+	critical := etcdClient.withDefaultPriority(PriorityCritical)
+	refresh := critical.RefreshTTL
+	if node.Dir {
+		refresh = critical.refreshDirTTL
+	}
+	if err := refresh(key, ttl); err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		stopOnce.Do(func() { close(done) })
+	}
+
+	etcdClient.goBackground(func() {
+		for {
+			jitter := time.Duration((rand.Float64()*2 - 1) * refreshJitter * float64(interval))
+			select {
+			case <-done:
+				return
+			case <-etcdClient.ctx.Done():
+				return
+			case <-etcdClient.root.Done():
+				return
+			case <-time.After(interval + jitter):
+			}
+
+			err := refresh(key, ttl)
+			if err == nil {
+				continue
+			}
+
+			etcdClient.options.log("scheduled refresh failed", "key", key, "err", err)
+			if isKeyNotFound(err) {
+				return
+			}
+		}
+	})
+	return stop, nil
+}
+
+// refreshDirTTL refreshes the TTL of an existing directory without
+// firing a watch event, unlike UpdateDirWithTTL
+func (etcdClient *SimpleEtcdClient) refreshDirTTL(key string, ttl time.Duration) error {
+	api := etcdClient.keysAPI()
+	_, err := api.Set(etcdClient.ctx, key, "", &client.SetOptions{Dir: true, TTL: ttl, Refresh: true, PrevExist: client.PrevExist})
+	return err
+}
@@ -0,0 +1,53 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestScheduleRefreshKeepsTheKeyAlive(t *testing.T) {
+	etcdClient := dial(t)
+	if _, err := etcdClient.SetWithOptions("/scheduled/key", "value", etcdclient.SetOptions{TTL: time.Second}); err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+	if err := etcdClient.EnsureDirWithTTL("/scheduled/dir", time.Second); err != nil {
+		t.Fatalf("EnsureDirWithTTL returned %v", err)
+	}
+
+	var stops []func()
+	for _, key := range []string{"/scheduled/key", "/scheduled/dir"} {
+		stop, err := etcdClient.ScheduleRefresh(key, time.Second, 300*time.Millisecond)
+		if err != nil {
+			t.Fatalf("ScheduleRefresh(%v) returned %v", key, err)
+		}
+		stops = append(stops, stop)
+	}
+
+	time.Sleep(2 * time.Second)
+	if value, err := etcdClient.Get("/scheduled/key"); err != nil || value != "value" {
+		t.Errorf("Get of the refreshed key returned %q, %v, expected it kept alive with its value", value, err)
+	}
+	if keys, err := etcdClient.Ls("/scheduled"); err != nil || len(keys) != 2 {
+		t.Errorf("Ls returned %v, %v, expected the refreshed key and directory", keys, err)
+	}
+
+	for _, stop := range stops {
+		stop()
+		stop()
+	}
+	time.Sleep(2 * time.Second)
+	if keys, err := etcdClient.Ls("/scheduled"); err != nil || len(keys) != 0 {
+		t.Errorf("Ls after stop returned %v, %v, expected the key and directory to expire", keys, err)
+	}
+}
+
+func TestScheduleRefreshOfAMissingKey(t *testing.T) {
+	etcdClient := dial(t)
+
+	if stop, err := etcdClient.ScheduleRefresh("/scheduled/missing", time.Second, time.Second); stop != nil || !errors.Is(err, etcdclient.ErrKeyNotFound) {
+		t.Errorf("ScheduleRefresh of a missing key returned %v, expected ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,87 @@
+package etcdclient
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// RouteHandler is called by a Router for an event whose key matches the
+// handler's pattern, with the values of the pattern's parameters
+type RouteHandler func(event Event, params map[string]string)
+
+// Router calls the handler registered for the pattern matching the key of
+// every event, like an HTTP mux for etcd changes. Patterns are keys where
+// a segment like {id} matches any single segment, so "/devices/{id}/status"
+// matches "/devices/42/status" with the parameter id set to "42". If several
+// patterns match a key, the first one registered is used
+type Router struct {
+	mutex  sync.RWMutex
+	routes []route
+}
+
+type route struct {
+	segments []string
+	handler  RouteHandler
+}
+
+// NewRouter returns a router without any routes
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers the handler for the pattern
+func (router *Router) Handle(pattern string, handler RouteHandler) error {
+	segments := Split(pattern)
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, "{") != strings.HasSuffix(segment, "}") {
+			return fmt.Errorf("Invalid route pattern %v: unbalanced braces in %q", pattern, segment)
+		}
+	}
+
+	router.mutex.Lock()
+	defer router.mutex.Unlock()
+	router.routes = append(router.routes, route{segments: segments, handler: handler})
+	return nil
+}
+
+// OnEvent calls the handler matching the event's key, if any.
+// It can be passed to WatchEvents directly
+func (router *Router) OnEvent(event Event) {
+	router.mutex.RLock()
+	routes := router.routes
+	router.mutex.RUnlock()
+
+	segments := Split(event.Key)
+	for _, route := range routes {
+		if params, ok := route.match(segments); ok {
+			route.handler(event, params)
+			return
+		}
+	}
+}
+
+func (route route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(route.segments) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, segment := range route.segments {
+		if strings.HasPrefix(segment, "{") {
+			params[strings.Trim(segment, "{}")] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// WatchRouted watches the directory and passes every change to the
+// router, so many handlers share a single watch.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchRouted(directory string, router *Router) error {
+	return etcdClient.watchRecursive(directory, 0, true, router.OnEvent)
+}
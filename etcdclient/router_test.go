@@ -0,0 +1,91 @@
+package etcdclient_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestRouterMatching(t *testing.T) {
+	router := etcdclient.NewRouter()
+	var routed []string
+	var params []map[string]string
+	handle := func(name, pattern string) {
+		err := router.Handle(pattern, func(event etcdclient.Event, values map[string]string) {
+			routed = append(routed, name+" "+event.Key)
+			params = append(params, values)
+		})
+		if err != nil {
+			t.Fatalf("Handle(%v) returned %v", pattern, err)
+		}
+	}
+	handle("status", "/devices/{id}/status")
+	handle("any", "/devices/{id}/{field}")
+	handle("shadowed", "/devices/{id}/status")
+	handle("config", "/devices/config")
+
+	for _, key := range []string{
+		"/devices/42/status",
+		"/devices/42/name",
+		"/devices/config",
+		"/devices/42",
+		"/devices/42/status/extra",
+		"/other/42/status",
+	} {
+		router.OnEvent(etcdclient.Event{Key: key})
+	}
+
+	expected := []string{"status /devices/42/status", "any /devices/42/name", "config /devices/config"}
+	if !reflect.DeepEqual(routed, expected) {
+		t.Errorf("The events were routed to %v, expected %v", routed, expected)
+	}
+	expectedParams := []map[string]string{{"id": "42"}, {"id": "42", "field": "name"}, {}}
+	if !reflect.DeepEqual(params, expectedParams) {
+		t.Errorf("The handlers got the params %v, expected %v", params, expectedParams)
+	}
+}
+
+func TestRouterRejectsUnbalancedBraces(t *testing.T) {
+	router := etcdclient.NewRouter()
+	handler := func(event etcdclient.Event, params map[string]string) {}
+
+	for _, pattern := range []string{"/devices/{id/status", "/devices/id}/status"} {
+		if err := router.Handle(pattern, handler); err == nil {
+			t.Errorf("Handle(%v) returned no error", pattern)
+		}
+	}
+}
+
+func TestWatchRouted(t *testing.T) {
+	etcdClient := dial(t)
+	router := etcdclient.NewRouter()
+	ids := make(chan string, 10)
+	router.Handle("/devices/{id}/status", func(event etcdclient.Event, params map[string]string) {
+		ids <- params["id"] + "=" + event.Value
+	})
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- etcdClient.WatchRouted("/devices", router)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := etcdClient.Set("/devices/42/name", "ignored"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Set("/devices/42/status", "online"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if routed := receive(t, ids); routed != "42=online" {
+		t.Errorf("The status handler got %v, expected 42=online", routed)
+	}
+
+	etcdClient.Close()
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchRouted did not return after Close")
+	}
+}
@@ -0,0 +1,181 @@
+package etcdclient
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrRequestShed is returned instead of making a request whose deadline
+// passed while it was queued by the scheduler, see WithRequestScheduler
+var ErrRequestShed = errors.New("request deadline passed while it was queued")
+
+// Priority is how urgent a request is for the scheduler
+type Priority int
+
+const (
+	// PriorityCritical is for requests that must not wait behind others,
+	// such as the heartbeats of sessions and semaphores
+	PriorityCritical Priority = iota
+
+	// PriorityNormal is the priority of requests without one
+	PriorityNormal
+
+	// PriorityBackground is for bulk requests that can wait, such as
+	// LsRecursive and the scans made by Backup, DelPrefix, PrefixStats
+	// and Sweeper
+	PriorityBackground
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a context that gives the requests of a client
+// returned by WithContext the priority
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// priorityOf returns the priority of the context, PriorityNormal if it has none
+func priorityOf(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+	return PriorityNormal
+}
+
+// withDefaultPriority returns a copy of the client whose requests have
+// the priority, unless its context already sets one
+func (etcdClient *SimpleEtcdClient) withDefaultPriority(priority Priority) *SimpleEtcdClient {
+	if _, ok := etcdClient.ctx.Value(priorityKey{}).(Priority); ok {
+		return etcdClient
+	}
+	return etcdClient.WithContext(WithPriority(etcdClient.ctx, priority)).(*SimpleEtcdClient)
+}
+
+// WithRequestScheduler limits the client to maxInFlight requests at a time.
+// Other requests wait in a queue and are sent by priority, see WithPriority,
+// then by earliest deadline, so heartbeats and locks never starve behind bulk
+// scans. A request whose deadline passes in the queue is dropped with
+// ErrRequestShed instead of being sent late. Watches are not limited.
+// maxInFlight must be at least 1
+func WithRequestScheduler(maxInFlight int) Option {
+	return func(opts *options) {
+		if maxInFlight < 1 {
+			opts.err = fmt.Errorf("WithRequestScheduler requires maxInFlight of at least 1, got %v", maxInFlight)
+			return
+		}
+		opts.scheduler = &scheduler{free: maxInFlight}
+	}
+}
+
+type scheduler struct {
+	mutex sync.Mutex
+	free  int
+	queue []*queuedRequest
+	seq   uint64
+}
+
+type queuedRequest struct {
+	priority Priority
+	deadline time.Time
+	seq      uint64
+	granted  bool
+	ready    chan struct{}
+}
+
+// acquire waits for a free slot, the caller must call release once done
+func (scheduler *scheduler) acquire(ctx context.Context) error {
+	scheduler.mutex.Lock()
+	if scheduler.free > 0 && len(scheduler.queue) == 0 {
+		scheduler.free--
+		scheduler.mutex.Unlock()
+		return nil
+	}
+
+	deadline, _ := ctx.Deadline()
+	scheduler.seq++
+	request := &queuedRequest{priority: priorityOf(ctx), deadline: deadline, seq: scheduler.seq, ready: make(chan struct{})}
+	scheduler.queue = append(scheduler.queue, request)
+	scheduler.mutex.Unlock()
+
+	select {
+	case <-request.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	scheduler.mutex.Lock()
+	granted := request.granted
+	scheduler.remove(request)
+	scheduler.mutex.Unlock()
+
+	if granted {
+		scheduler.release()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrRequestShed
+	}
+	return ctx.Err()
+}
+
+// release hands the slot to the most urgent queued request
+func (scheduler *scheduler) release() {
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	now := time.Now()
+	for {
+		next := scheduler.next()
+		if next == nil {
+			scheduler.free++
+			return
+		}
+
+		scheduler.remove(next)
+		if !next.deadline.IsZero() && next.deadline.Before(now) {
+			// it is about to give up, do not waste the slot on it
+			continue
+		}
+		next.granted = true
+		close(next.ready)
+		return
+	}
+}
+
+// next returns the most urgent queued request, the mutex must be held
+func (scheduler *scheduler) next() *queuedRequest {
+	var best *queuedRequest
+	for _, request := range scheduler.queue {
+		if best == nil || request.before(best) {
+			best = request
+		}
+	}
+	return best
+}
+
+// remove takes the request out of the queue, the mutex must be held
+func (scheduler *scheduler) remove(request *queuedRequest) {
+	for i, queued := range scheduler.queue {
+		if queued == request {
+			scheduler.queue = append(scheduler.queue[:i], scheduler.queue[i+1:]...)
+			return
+		}
+	}
+}
+
+// before returns true if the request should be sent before other
+func (request *queuedRequest) before(other *queuedRequest) bool {
+	if request.priority != other.priority {
+		return request.priority < other.priority
+	}
+	if request.deadline.IsZero() != other.deadline.IsZero() {
+		return !request.deadline.IsZero()
+	}
+	if !request.deadline.Equal(other.deadline) {
+		return request.deadline.Before(other.deadline)
+	}
+	return request.seq < other.seq
+}
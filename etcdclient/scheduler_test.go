@@ -0,0 +1,96 @@
+package etcdclient
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// waitForQueue waits until the scheduler has length requests queued
+func waitForQueue(t *testing.T, scheduler *scheduler, length int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		scheduler.mutex.Lock()
+		queued := len(scheduler.queue)
+		scheduler.mutex.Unlock()
+		if queued == length {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Got %v queued requests, expected %v", queued, length)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSchedulerSendsByPriorityThenDeadline(t *testing.T) {
+	scheduler := &scheduler{free: 1}
+	if err := scheduler.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire returned %v", err)
+	}
+
+	later, cancelLater := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLater()
+	sooner, cancelSooner := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelSooner()
+	requests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"background", WithPriority(context.Background(), PriorityBackground)},
+		{"normal", context.Background()},
+		{"normal later", later},
+		{"normal sooner", sooner},
+		{"critical", WithPriority(context.Background(), PriorityCritical)},
+	}
+
+	sent := make(chan string, len(requests))
+	for i, request := range requests {
+		go func(name string, ctx context.Context) {
+			if err := scheduler.acquire(ctx); err != nil {
+				t.Errorf("acquire(%v) returned %v", name, err)
+			}
+			sent <- name
+			scheduler.release()
+		}(request.name, request.ctx)
+		waitForQueue(t, scheduler, i+1)
+	}
+	scheduler.release()
+
+	var order []string
+	for range requests {
+		order = append(order, <-sent)
+	}
+	expected := []string{"critical", "normal sooner", "normal later", "normal", "background"}
+	if !reflect.DeepEqual(order, expected) {
+		t.Errorf("Requests were sent in the order %v, expected %v", order, expected)
+	}
+}
+
+func TestSchedulerShedsRequestsPastTheirDeadline(t *testing.T) {
+	scheduler := &scheduler{free: 1}
+	if err := scheduler.acquire(context.Background()); err != nil {
+		t.Fatalf("acquire returned %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := scheduler.acquire(ctx); err != ErrRequestShed {
+		t.Errorf("acquire returned %v, expected ErrRequestShed", err)
+	}
+
+	scheduler.release()
+	if scheduler.free != 1 || len(scheduler.queue) != 0 {
+		t.Errorf("Got %v free slots and %v queued requests, expected 1 and 0", scheduler.free, len(scheduler.queue))
+	}
+}
+
+func TestWithRequestSchedulerRequiresALimit(t *testing.T) {
+	for _, maxInFlight := range []int{0, -1} {
+		if _, err := Dial("http://127.0.0.1:2379", WithRequestScheduler(maxInFlight)); err == nil {
+			t.Errorf("Dial with WithRequestScheduler(%v) returned no error", maxInFlight)
+		}
+	}
+}
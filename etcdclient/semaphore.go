@@ -0,0 +1,117 @@
+package etcdclient
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// SemaphoreTTL is how long a holder's entry lives without being
+// refreshed, so the slot of a holder that died is freed
+var SemaphoreTTL = 30 * time.Second
+
+// Semaphore lets at most limit holders proceed at the same time. Every
+// holder has an in-order entry in the directory key, the holders of the
+// first limit entries proceed and the others wait for them to leave
+type Semaphore struct {
+	etcdClient *SimpleEtcdClient
+	key        string
+	limit      int
+
+	entry string
+	stop  chan struct{}
+}
+
+// Semaphore returns the semaphore for limit holders stored in the
+// directory key. Every holder must use its own Semaphore
+func (etcdClient *SimpleEtcdClient) Semaphore(key string, limit int) *Semaphore {
+	return &Semaphore{etcdClient: etcdClient, key: key, limit: limit}
+}
+
+// Acquire blocks until the holder is one of the first limit holders
+// or ctx is done. The entry is refreshed until Release is called
+func (semaphore *Semaphore) Acquire(ctx context.Context) error {
+	if semaphore.entry != "" {
+		return fmt.Errorf("Semaphore %v is already acquired", semaphore.key)
+	}
+	if semaphore.limit <= 0 {
+		return fmt.Errorf("Semaphore limit must be greater than 0, got %v", semaphore.limit)
+	}
+
+	etcdClient := semaphore.etcdClient
+	api := etcdClient.keysAPI()
+	response, err := api.CreateInOrder(ctx, semaphore.key, "", &client.CreateInOrderOptions{TTL: SemaphoreTTL})
+	if err != nil {
+		return err
+	}
+	entry, stop := response.Node.Key, make(chan struct{})
+	semaphore.entry = entry
+	semaphore.stop = stop
+	etcdClient.goBackground(func() {
+		semaphore.heartbeat(entry, stop)
+	})
+
+	if err := semaphore.wait(ctx); err != nil {
+		semaphore.Release()
+		return err
+	}
+	return nil
+}
+
+// Release frees the holder's slot, letting the next holder proceed
+func (semaphore *Semaphore) Release() error {
+	if semaphore.entry == "" {
+		return nil
+	}
+
+	close(semaphore.stop)
+	err := semaphore.etcdClient.Del(semaphore.entry)
+	semaphore.entry = ""
+	return err
+}
+
+// wait blocks until the entry is one of the first limit entries
+func (semaphore *Semaphore) wait(ctx context.Context) error {
+	api := semaphore.etcdClient.keysAPI()
+	for {
+		response, err := api.Get(ctx, semaphore.key, &client.GetOptions{Sort: true})
+		if err != nil {
+			return err
+		}
+
+		for i, node := range response.Node.Nodes {
+			if i >= semaphore.limit {
+				break
+			}
+			if node.Key == semaphore.entry {
+				return nil
+			}
+		}
+
+		watcher := api.Watcher(semaphore.key, &client.WatcherOptions{AfterIndex: response.Index, Recursive: true})
+		if _, err := watcher.Next(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// heartbeat refreshes the entry until stop is closed by Release. The
+// entry and stop are passed in since Release clears the fields
+func (semaphore *Semaphore) heartbeat(entry string, stop chan struct{}) {
+	etcdClient := semaphore.etcdClient.withDefaultPriority(PriorityCritical)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-etcdClient.root.Done():
+			return
+		case <-time.After(SemaphoreTTL / 3):
+		}
+
+		if err := etcdClient.RefreshTTL(entry, SemaphoreTTL); err != nil {
+			etcdClient.options.log("semaphore heartbeat failed", "key", entry, "err", err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package etcdclient_test
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestSemaphoreWaitsForAFreeSlot(t *testing.T) {
+	etcdClient := dial(t)
+	holder := etcdClient.Semaphore("/semaphore", 1)
+	if err := holder.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire returned %v", err)
+	}
+	if err := holder.Acquire(context.Background()); err == nil {
+		t.Error("Acquire of a semaphore that is already acquired returned no error")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := etcdClient.Semaphore("/semaphore", 1).Acquire(ctx); err == nil {
+		t.Error("Acquire of a full semaphore returned no error when its context was done")
+	}
+
+	waiter := etcdClient.Semaphore("/semaphore", 1)
+	acquired := make(chan error, 1)
+	go func() {
+		acquired <- waiter.Acquire(context.Background())
+	}()
+	select {
+	case err := <-acquired:
+		t.Fatalf("Acquire returned %v while the slot was held", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := holder.Release(); err != nil {
+		t.Fatalf("Release returned %v", err)
+	}
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Errorf("Acquire returned %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Acquire did not return after the holder released")
+	}
+	if err := waiter.Release(); err != nil {
+		t.Errorf("Release returned %v", err)
+	}
+	if err := waiter.Release(); err != nil {
+		t.Errorf("Release of a semaphore that is not acquired returned %v", err)
+	}
+}
+
+func TestSemaphoreLimitMustBePositive(t *testing.T) {
+	etcdClient := dial(t)
+
+	if err := etcdClient.Semaphore("/semaphore", 0).Acquire(context.Background()); err == nil {
+		t.Error("Acquire of a semaphore with a limit of 0 returned no error")
+	}
+}
@@ -0,0 +1,121 @@
+package etcdclient
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// SessionsDirectory is where sessions create their directories.
+// It is hidden, so it does not show up when listing the root
+const SessionsDirectory = "/_sessions"
+
+// Session is a directory with a TTL that is kept alive by a heartbeat
+// for as long as the session is open. Keys set through the session live
+// in its directory, so they disappear when the session is closed or
+// when its owner dies and stops heartbeating
+type Session struct {
+	etcdClient *SimpleEtcdClient
+	key        string
+	ttl        time.Duration
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSession creates a session directory that expires after ttl
+// unless it is refreshed, and starts refreshing it
+func (etcdClient *SimpleEtcdClient) NewSession(ttl time.Duration) (*Session, error) {
+	id, err := newSessionID()
+	if err != nil {
+		return nil, err
+	}
+
+	key := path.Join(SessionsDirectory, id)
+	api := etcdClient.keysAPI()
+	_, err = api.Set(etcdClient.ctx, key, "", &client.SetOptions{TTL: ttl, Dir: true, PrevExist: client.PrevNoExist})
+	if err != nil {
+		return nil, err
+	}
+
+	session := &Session{etcdClient: etcdClient, key: key, ttl: ttl, done: make(chan struct{})}
+	etcdClient.goBackground(session.heartbeat)
+	return session, nil
+}
+
+// Key returns the session's directory
+func (session *Session) Key() string {
+	return session.key
+}
+
+// TTL returns how long the session lives without a heartbeat
+func (session *Session) TTL() time.Duration {
+	return session.ttl
+}
+
+// Done is closed when the session ends, either because it was
+// closed or because it expired before it could be refreshed
+func (session *Session) Done() <-chan struct{} {
+	return session.done
+}
+
+// Set sets a key in the session's directory, the key is
+// removed when the session ends
+func (session *Session) Set(name, value string) error {
+	return session.etcdClient.Set(path.Join(session.key, name), value)
+}
+
+// Close stops the heartbeat and deletes the session's directory
+func (session *Session) Close() error {
+	session.end()
+	return session.etcdClient.DelDir(session.key)
+}
+
+// heartbeat refreshes the session's TTL until the session ends. The
+// session ends if its directory is gone or it could not be refreshed
+// for a whole TTL
+func (session *Session) heartbeat() {
+	etcdClient := session.etcdClient.withDefaultPriority(PriorityCritical)
+	lastRefresh := time.Now()
+
+	for {
+		select {
+		case <-session.done:
+			return
+		case <-etcdClient.root.Done():
+			session.end()
+			return
+		case <-time.After(session.ttl / 3):
+		}
+
+		err := etcdClient.refreshDirTTL(session.key, session.ttl)
+		if err == nil {
+			lastRefresh = time.Now()
+			continue
+		}
+
+		etcdClient.options.log("session heartbeat failed", "key", session.key, "err", err)
+		if isKeyNotFound(err) || time.Since(lastRefresh) > session.ttl {
+			session.end()
+			return
+		}
+	}
+}
+
+func (session *Session) end() {
+	session.closeOnce.Do(func() {
+		close(session.done)
+	})
+}
+
+func newSessionID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,47 @@
+package etcdclient_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestSessionHeartbeatDoesNotNotifyWatches(t *testing.T) {
+	etcdClient := dial(t)
+
+	session, err := etcdClient.NewSession(time.Second)
+	if err != nil {
+		t.Fatalf("NewSession returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+
+	events := make(chan etcdclient.Event, 10)
+	go etcdClient.WatchEvents(etcdclient.SessionsDirectory, index, func(event etcdclient.Event) {
+		events <- event
+	})
+
+	// outlive the TTL, so the session only survives by heartbeating
+	select {
+	case event := <-events:
+		t.Errorf("The heartbeat fired a watch event: %v of %v", event.Action, event.Key)
+	case <-session.Done():
+		t.Fatal("The session ended while heartbeating")
+	case <-time.After(1500 * time.Millisecond):
+	}
+
+	if err := session.Close(); err != nil {
+		t.Fatalf("Close returned %v", err)
+	}
+	select {
+	case event := <-events:
+		if event.Key != session.Key() {
+			t.Errorf("Close fired %v of %v, expected the session's directory", event.Action, event.Key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Close did not fire a watch event")
+	}
+}
@@ -0,0 +1,108 @@
+package etcdclient
+
+import (
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// PrevExistCondition is a condition on whether a key exists
+type PrevExistCondition int
+
+const (
+	// IgnorePrevExist writes whether or not the key exists
+	IgnorePrevExist PrevExistCondition = iota
+
+	// MustExist only writes if the key exists
+	MustExist
+
+	// MustNotExist only writes if the key does not exist
+	MustNotExist
+)
+
+// SetOptions are the conditions and settings for SetWithOptions,
+// the zero value sets the key unconditionally without a TTL
+type SetOptions struct {
+	// PrevExist is a condition on whether the key exists
+	PrevExist PrevExistCondition
+
+	// PrevValue, if not empty, is the value the key must have
+	PrevValue string
+
+	// PrevIndex, if not 0, is the ModifiedIndex the key must have
+	PrevIndex uint64
+
+	// TTL, if greater than 0, makes the key expire
+	TTL time.Duration
+
+	// Refresh only updates the TTL of an existing key, the value
+	// must be empty and no watch events are fired
+	Refresh bool
+}
+
+// Result describes a key after it was written
+type Result struct {
+	Key   string
+	Value string
+
+	// PrevValue is the value before the write, empty if
+	// PrevExisted is false
+	PrevValue   string
+	PrevExisted bool
+
+	CreatedIndex  uint64
+	ModifiedIndex uint64
+
+	// TTL is the remaining time to live, 0 if the key does not expire
+	TTL time.Duration
+}
+
+// SetWithOptions sets a value in Etcd if the conditions in opts are met
+func (etcdClient *SimpleEtcdClient) SetWithOptions(key, value string, opts SetOptions) (*Result, error) {
+	api := etcdClient.keysAPI()
+	response, err := api.Set(etcdClient.ctx, key, value, opts.etcdSetOptions())
+	if err != nil {
+		return nil, err
+	}
+	return newResult(response), nil
+}
+
+// SetResult sets a value in Etcd like Set and describes the key after the
+// write, so its ModifiedIndex can be used for a compare and swap or to
+// watch from the exact point of the write
+func (etcdClient *SimpleEtcdClient) SetResult(key, value string) (*Result, error) {
+	return etcdClient.SetWithOptions(key, value, SetOptions{})
+}
+
+func (opts SetOptions) etcdSetOptions() *client.SetOptions {
+	setOptions := &client.SetOptions{
+		PrevValue: opts.PrevValue,
+		PrevIndex: opts.PrevIndex,
+		TTL:       opts.TTL,
+		Refresh:   opts.Refresh,
+	}
+
+	switch opts.PrevExist {
+	case MustExist:
+		setOptions.PrevExist = client.PrevExist
+	case MustNotExist:
+		setOptions.PrevExist = client.PrevNoExist
+	}
+	return setOptions
+}
+
+func newResult(response *client.Response) *Result {
+	result := &Result{
+		Key:           response.Node.Key,
+		Value:         response.Node.Value,
+		CreatedIndex:  response.Node.CreatedIndex,
+		ModifiedIndex: response.Node.ModifiedIndex,
+		TTL:           response.Node.TTLDuration(),
+	}
+
+	if response.PrevNode != nil {
+		result.PrevValue = response.PrevNode.Value
+		result.PrevExisted = true
+	}
+	return result
+}
@@ -0,0 +1,103 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestSetWithOptionsPrevExist(t *testing.T) {
+	etcdClient := dial(t)
+
+	if _, err := etcdClient.SetWithOptions("/options/key", "value", etcdclient.SetOptions{PrevExist: etcdclient.MustExist}); !errors.Is(err, etcdclient.ErrKeyNotFound) {
+		t.Errorf("SetWithOptions MustExist of a missing key returned %v, expected ErrKeyNotFound", err)
+	}
+
+	result, err := etcdClient.SetWithOptions("/options/key", "value", etcdclient.SetOptions{PrevExist: etcdclient.MustNotExist})
+	if err != nil {
+		t.Fatalf("SetWithOptions MustNotExist returned %v", err)
+	}
+	if result.Key != "/options/key" || result.Value != "value" || result.PrevExisted || result.CreatedIndex != result.ModifiedIndex {
+		t.Errorf("SetWithOptions returned %+v, expected a new key", result)
+	}
+
+	if _, err := etcdClient.SetWithOptions("/options/key", "other", etcdclient.SetOptions{PrevExist: etcdclient.MustNotExist}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("SetWithOptions MustNotExist of an existing key returned %v, expected ErrConflict", err)
+	}
+}
+
+func TestSetWithOptionsPrevValueAndPrevIndex(t *testing.T) {
+	etcdClient := dial(t)
+	created, err := etcdClient.SetWithOptions("/options/key", "first", etcdclient.SetOptions{})
+	if err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+
+	if _, err := etcdClient.SetWithOptions("/options/key", "second", etcdclient.SetOptions{PrevValue: "wrong"}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("SetWithOptions with the wrong PrevValue returned %v, expected ErrConflict", err)
+	}
+	swapped, err := etcdClient.SetWithOptions("/options/key", "second", etcdclient.SetOptions{PrevValue: "first"})
+	if err != nil {
+		t.Fatalf("SetWithOptions with the right PrevValue returned %v", err)
+	}
+	if !swapped.PrevExisted || swapped.PrevValue != "first" || swapped.CreatedIndex != created.CreatedIndex {
+		t.Errorf("SetWithOptions returned %+v, expected the previous value \"first\"", swapped)
+	}
+
+	if _, err := etcdClient.SetWithOptions("/options/key", "third", etcdclient.SetOptions{PrevIndex: created.ModifiedIndex}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("SetWithOptions with a stale PrevIndex returned %v, expected ErrConflict", err)
+	}
+	if _, err := etcdClient.SetWithOptions("/options/key", "third", etcdclient.SetOptions{PrevIndex: swapped.ModifiedIndex}); err != nil {
+		t.Errorf("SetWithOptions with the current PrevIndex returned %v", err)
+	}
+}
+
+func TestSetWithOptionsTTLAndRefresh(t *testing.T) {
+	etcdClient := dial(t)
+
+	result, err := etcdClient.SetWithOptions("/options/key", "value", etcdclient.SetOptions{TTL: time.Minute})
+	if err != nil {
+		t.Fatalf("SetWithOptions returned %v", err)
+	}
+	if result.TTL <= 0 || result.TTL > time.Minute {
+		t.Errorf("SetWithOptions returned the TTL %v, expected at most a minute", result.TTL)
+	}
+
+	refreshed, err := etcdClient.SetWithOptions("/options/key", "", etcdclient.SetOptions{TTL: time.Hour, Refresh: true})
+	if err != nil {
+		t.Fatalf("SetWithOptions Refresh returned %v", err)
+	}
+	if refreshed.Value != "value" || refreshed.TTL <= time.Minute {
+		t.Errorf("SetWithOptions Refresh returned %q with the TTL %v, expected the value kept and a longer TTL", refreshed.Value, refreshed.TTL)
+	}
+}
+
+func TestSetResult(t *testing.T) {
+	etcdClient := dial(t)
+
+	created, err := etcdClient.SetResult("/result/key", "first")
+	if err != nil {
+		t.Fatalf("SetResult returned %v", err)
+	}
+	if created.Key != "/result/key" || created.Value != "first" || created.PrevExisted || created.ModifiedIndex == 0 {
+		t.Errorf("SetResult of a new key returned %+v", created)
+	}
+
+	updated, err := etcdClient.SetResult("/result/key", "second")
+	if err != nil {
+		t.Fatalf("SetResult returned %v", err)
+	}
+	if !updated.PrevExisted || updated.PrevValue != "first" || updated.ModifiedIndex <= created.ModifiedIndex {
+		t.Errorf("SetResult of an existing key returned %+v, expected the previous value and a newer index", updated)
+	}
+
+	// the index chains into a compare and swap
+	if _, err := etcdClient.SetWithOptions("/result/key", "stale", etcdclient.SetOptions{PrevIndex: created.ModifiedIndex}); !errors.Is(err, etcdclient.ErrConflict) {
+		t.Errorf("SetWithOptions with the index of an older write returned %v, expected ErrConflict", err)
+	}
+	if _, err := etcdClient.SetWithOptions("/result/key", "third", etcdclient.SetOptions{PrevIndex: updated.ModifiedIndex}); err != nil {
+		t.Errorf("SetWithOptions with the index of the last write returned %v", err)
+	}
+}
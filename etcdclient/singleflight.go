@@ -0,0 +1,106 @@
+package etcdclient
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// WithSingleflight makes concurrent identical Gets share a single
+// request to etcd, so a burst of reads for the same key after a cache
+// invalidation only reaches etcd once. Every caller gets a copy of the
+// result of the shared request, including its error, unless the request
+// was cancelled or timed out with the context of the caller that made it,
+// then the callers waiting for it make their own request
+func WithSingleflight() Option {
+	return func(opts *options) {
+		opts.singleflight = &singleflight{calls: make(map[string]*singleflightCall)}
+	}
+}
+
+// singleflight deduplicates concurrent calls with the same key
+type singleflight struct {
+	mutex sync.Mutex
+	calls map[string]*singleflightCall
+}
+
+type singleflightCall struct {
+	wait     sync.WaitGroup
+	response *client.Response
+	err      error
+}
+
+// do calls fn, unless a call with the same key is already in
+// flight, in which case it waits for that call and returns a copy
+// of its result. If that call failed with the context of its caller,
+// do tries again
+func (group *singleflight) do(key string, fn func() (*client.Response, error)) (*client.Response, error) {
+	group.mutex.Lock()
+	if call, ok := group.calls[key]; ok {
+		group.mutex.Unlock()
+		call.wait.Wait()
+		if isContextError(call.err) {
+			return group.do(key, fn)
+		}
+		return copyResponse(call.response), call.err
+	}
+
+	call := &singleflightCall{}
+	call.wait.Add(1)
+	group.calls[key] = call
+	group.mutex.Unlock()
+
+	call.response, call.err = fn()
+	call.wait.Done()
+
+	group.mutex.Lock()
+	delete(group.calls, key)
+	group.mutex.Unlock()
+
+	return call.response, call.err
+}
+
+// isContextError returns true if the error comes from the context of
+// the request rather than from etcd
+func isContextError(err error) bool {
+	return err == context.Canceled || err == context.DeadlineExceeded || err == ErrRequestShed
+}
+
+// copyResponse returns a deep copy of the response, so callers
+// sharing a request can modify their nodes
+func copyResponse(response *client.Response) *client.Response {
+	if response == nil {
+		return nil
+	}
+	copied := *response
+	copied.Node = copyNode(response.Node)
+	copied.PrevNode = copyNode(response.PrevNode)
+	return &copied
+}
+
+func copyNode(node *client.Node) *client.Node {
+	if node == nil {
+		return nil
+	}
+	copied := *node
+	if node.Expiration != nil {
+		expiration := *node.Expiration
+		copied.Expiration = &expiration
+	}
+	if node.Nodes != nil {
+		copied.Nodes = make(client.Nodes, len(node.Nodes))
+		for i, child := range node.Nodes {
+			copied.Nodes[i] = copyNode(child)
+		}
+	}
+	return &copied
+}
+
+func getCallKey(key string, opts *client.GetOptions) string {
+	if opts == nil {
+		opts = &client.GetOptions{}
+	}
+	return fmt.Sprintf("%v?recursive=%v&sorted=%v&quorum=%v", key, opts.Recursive, opts.Sort, opts.Quorum)
+}
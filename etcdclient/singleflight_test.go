@@ -0,0 +1,91 @@
+package etcdclient
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// lead starts a call of key that returns response and err once
+// release is closed, and waits for it to be in flight
+func lead(group *singleflight, key string, response *client.Response, err error, release chan struct{}) {
+	go group.do(key, func() (*client.Response, error) {
+		<-release
+		return response, err
+	})
+	for {
+		group.mutex.Lock()
+		_, ok := group.calls[key]
+		group.mutex.Unlock()
+		if ok {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSingleflightSharesACopyOfTheResponse(t *testing.T) {
+	group := &singleflight{calls: make(map[string]*singleflightCall)}
+	release := make(chan struct{})
+	shared := &client.Response{Node: &client.Node{Key: "/dir", Dir: true, Nodes: client.Nodes{{Key: "/dir/key", Value: "value"}}}}
+	lead(group, "/dir", shared, nil, release)
+
+	var calls int64
+	responses := make([]*client.Response, 3)
+	var wait sync.WaitGroup
+	for i := range responses {
+		wait.Add(1)
+		go func(i int) {
+			defer wait.Done()
+			responses[i], _ = group.do("/dir", func() (*client.Response, error) {
+				atomic.AddInt64(&calls, 1)
+				return nil, nil
+			})
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wait.Wait()
+
+	if calls != 0 {
+		t.Errorf("Waiting callers made %v requests, expected 0", calls)
+	}
+	responses[0].Node.Nodes[0].Value = "changed"
+	for i, response := range responses {
+		if response == shared || response.Node.Nodes[0] == shared.Node.Nodes[0] {
+			t.Errorf("Caller %v got the shared response instead of a copy", i)
+		}
+	}
+	if value := responses[1].Node.Nodes[0].Value; value != "value" {
+		t.Errorf("Got %q after another caller changed its copy, expected \"value\"", value)
+	}
+}
+
+func TestSingleflightRetriesAfterTheLeaderIsCancelled(t *testing.T) {
+	for _, leaderErr := range []error{context.Canceled, context.DeadlineExceeded, ErrRequestShed} {
+		group := &singleflight{calls: make(map[string]*singleflightCall)}
+		release := make(chan struct{})
+		lead(group, "/key", nil, leaderErr, release)
+
+		done := make(chan struct{})
+		var response *client.Response
+		var err error
+		go func() {
+			defer close(done)
+			response, err = group.do("/key", func() (*client.Response, error) {
+				return &client.Response{Node: &client.Node{Key: "/key", Value: "value"}}, nil
+			})
+		}()
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+		<-done
+
+		if err != nil || response == nil || response.Node.Value != "value" {
+			t.Errorf("After the leader failed with %v, do returned %v, %v, expected its own response", leaderErr, response, err)
+		}
+	}
+}
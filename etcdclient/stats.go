@@ -0,0 +1,75 @@
+package etcdclient
+
+import (
+	"sort"
+
+	"github.com/coreos/etcd/client"
+)
+
+// largestKeys is how many keys Stats.Largest holds
+const largestKeys = 10
+
+// Stats describes how much of the keyspace a prefix uses
+type Stats struct {
+	// Keys and Dirs count the keys and directories under the prefix
+	Keys int `json:"keys"`
+	Dirs int `json:"dirs"`
+
+	// ValueBytes is the total size of the values under the prefix
+	ValueBytes int64 `json:"valueBytes"`
+
+	// MaxDepth is how many levels below the prefix the deepest node is
+	MaxDepth int `json:"maxDepth"`
+
+	// Largest holds the keys with the largest values, largest first
+	Largest []KeySize `json:"largest"`
+}
+
+// KeySize is the size of the value of a key
+type KeySize struct {
+	Key   string `json:"key"`
+	Bytes int    `json:"bytes"`
+}
+
+// PrefixStats counts the keys, directories and value bytes under the
+// prefix and finds its deepest node and largest keys. Directories are
+// fetched one at a time, so large prefixes do not need a single huge
+// request. Value sizes are measured after decoding
+func (etcdClient *SimpleEtcdClient) PrefixStats(prefix string) (Stats, error) {
+	var stats Stats
+	base := len(Split(prefix))
+
+	err := etcdClient.walkNodes(prefix, func(node *client.Node) error {
+		if depth := len(Split(node.Key)) - base; depth > stats.MaxDepth {
+			stats.MaxDepth = depth
+		}
+
+		if node.Dir {
+			stats.Dirs++
+			return nil
+		}
+
+		stats.Keys++
+		stats.ValueBytes += int64(len(node.Value))
+		stats.addLargest(KeySize{Key: node.Key, Bytes: len(node.Value)})
+		return nil
+	})
+	return stats, err
+}
+
+// addLargest keeps the key if it is one of the largest seen so far
+func (stats *Stats) addLargest(size KeySize) {
+	index := sort.Search(len(stats.Largest), func(i int) bool {
+		return stats.Largest[i].Bytes < size.Bytes
+	})
+	if index >= largestKeys {
+		return
+	}
+
+	stats.Largest = append(stats.Largest, KeySize{})
+	copy(stats.Largest[index+1:], stats.Largest[index:])
+	stats.Largest[index] = size
+	if len(stats.Largest) > largestKeys {
+		stats.Largest = stats.Largest[:largestKeys]
+	}
+}
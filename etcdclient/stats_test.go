@@ -0,0 +1,53 @@
+package etcdclient_test
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestPrefixStats(t *testing.T) {
+	// sizes are measured after the values are decompressed
+	etcdClient := dial(t, etcdclient.WithCompression(10))
+	for i := 1; i <= 12; i++ {
+		if err := etcdClient.Set(fmt.Sprintf("/team/dir%v/key", i%3), ""); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+		if err := etcdClient.Set(fmt.Sprintf("/team/deep/%v/key%v", i, i), strings.Repeat("a", i*10)); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+	setKeys(t, etcdClient, "/teamwork/key")
+
+	stats, err := etcdClient.PrefixStats("/team")
+	if err != nil {
+		t.Fatalf("PrefixStats returned %v", err)
+	}
+	if stats.Keys != 15 || stats.Dirs != 16 || stats.ValueBytes != 780 || stats.MaxDepth != 3 {
+		t.Errorf("PrefixStats returned %+v, expected 15 keys, 16 dirs, 780 bytes and a depth of 3", stats)
+	}
+
+	var largest []string
+	for _, size := range stats.Largest {
+		largest = append(largest, fmt.Sprintf("%v %v", size.Key, size.Bytes))
+	}
+	var expected []string
+	for i := 12; i > 2; i-- {
+		expected = append(expected, fmt.Sprintf("/team/deep/%v/key%v %v", i, i, i*10))
+	}
+	if !reflect.DeepEqual(largest, expected) {
+		t.Errorf("PrefixStats found the largest keys\n%v\nexpected\n%v", largest, expected)
+	}
+}
+
+func TestPrefixStatsOfAMissingPrefix(t *testing.T) {
+	etcdClient := dial(t)
+
+	stats, err := etcdClient.PrefixStats("/missing")
+	if err != nil || !reflect.DeepEqual(stats, etcdclient.Stats{}) {
+		t.Errorf("PrefixStats of a missing prefix returned %+v, %v, expected empty stats", stats, err)
+	}
+}
@@ -0,0 +1,129 @@
+package etcdclient
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/coreos/etcd/client"
+)
+
+// TimestampFunc returns when the entry at key was last updated. ok is
+// false if the entry has no timestamp, and the sweeper leaves it alone
+type TimestampFunc func(key, value string) (timestamp time.Time, ok bool)
+
+// Sweeper deletes entries under a prefix that are older than a maximum
+// age, for registries where a TTL on every key is impractical. The age
+// of an entry comes from a timestamp in its value
+type Sweeper struct {
+	etcdClient *SimpleEtcdClient
+	prefix     string
+	maxAge     time.Duration
+
+	// Timestamp reads the timestamp of an entry,
+	// DefaultTimestamp is used if it is nil
+	Timestamp TimestampFunc
+
+	// OnSweep, if not nil, is called with every key that is deleted
+	OnSweep func(key string)
+
+	// OnError, if not nil, is called by Run when a sweep fails,
+	// instead of Run returning the error
+	OnError OnErrorCallback
+}
+
+// Sweeper returns a sweeper for the entries under the prefix
+// that were updated more than maxAge ago
+func (etcdClient *SimpleEtcdClient) Sweeper(prefix string, maxAge time.Duration) *Sweeper {
+	return &Sweeper{etcdClient: etcdClient, prefix: prefix, maxAge: maxAge}
+}
+
+// DefaultTimestamp reads the timestamp from values that are an RFC 3339
+// time, or a JSON object with a "timestamp" field holding an RFC 3339
+// time or the number of seconds since the Unix epoch
+func DefaultTimestamp(key, value string) (time.Time, bool) {
+	value = strings.TrimSpace(value)
+	if timestamp, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return timestamp, true
+	}
+
+	var meta struct {
+		Timestamp json.RawMessage `json:"timestamp"`
+	}
+	// a null timestamp would decode as the zero value without an error
+	if err := json.Unmarshal([]byte(value), &meta); err != nil || meta.Timestamp == nil || string(meta.Timestamp) == "null" {
+		return time.Time{}, false
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(meta.Timestamp, &seconds); err == nil {
+		return time.Unix(0, int64(seconds*float64(time.Second))), true
+	}
+
+	var timestamp time.Time
+	if err := json.Unmarshal(meta.Timestamp, &timestamp); err == nil {
+		return timestamp, true
+	}
+	return time.Time{}, false
+}
+
+// Sweep deletes every stale entry under the prefix once and returns how
+// many were deleted. An entry that changes between the scan and the
+// delete is kept, so a registration refreshed at the last moment survives
+func (sweeper *Sweeper) Sweep() (int, error) {
+	timestamp := sweeper.Timestamp
+	if timestamp == nil {
+		timestamp = DefaultTimestamp
+	}
+
+	var stale []*client.Node
+	now := time.Now()
+	err := sweeper.etcdClient.walkNodes(sweeper.prefix, func(node *client.Node) error {
+		if node.Dir {
+			return nil
+		}
+		if updated, ok := timestamp(node.Key, node.Value); ok && now.Sub(updated) > sweeper.maxAge {
+			stale = append(stale, node)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	api := sweeper.etcdClient.keysAPI()
+	deleted := 0
+	for _, node := range stale {
+		_, err := api.Delete(sweeper.etcdClient.ctx, node.Key, &client.DeleteOptions{PrevIndex: node.ModifiedIndex})
+		if isKeyNotFound(err) || hasErrorCode(err, client.ErrorCodeTestFailed) {
+			continue
+		}
+		if err != nil {
+			return deleted, err
+		}
+
+		deleted++
+		if sweeper.OnSweep != nil {
+			sweeper.OnSweep(node.Key)
+		}
+	}
+	return deleted, nil
+}
+
+// Run sweeps the prefix every interval until the client is closed.
+// This method only returns if there is an error. Sweep errors are
+// passed to OnError instead if it is set
+func (sweeper *Sweeper) Run(interval time.Duration) error {
+	for {
+		if _, err := sweeper.Sweep(); err != nil {
+			if sweeper.OnError == nil {
+				return err
+			}
+			sweeper.OnError(err)
+		}
+
+		if err := sweeper.etcdClient.sleep(interval); err != nil {
+			return err
+		}
+	}
+}
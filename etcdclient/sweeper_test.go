@@ -0,0 +1,146 @@
+package etcdclient_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestDefaultTimestamp(t *testing.T) {
+	when := time.Date(2020, 1, 2, 3, 4, 5, 500000000, time.UTC)
+	cases := map[string]bool{
+		when.Format(time.RFC3339Nano):                                                true,
+		" " + when.Format(time.RFC3339Nano) + "\n":                                   true,
+		fmt.Sprintf(`{"timestamp": %v}`, float64(when.UnixNano())/1e9):               true,
+		fmt.Sprintf(`{"timestamp": %q, "host": "a"}`, when.Format(time.RFC3339Nano)): true,
+		`{"timestamp": null}`:                                                        false,
+		`{"timestamp": "yesterday"}`:                                                 false,
+		`{"host": "a"}`:                                                              false,
+		"not a timestamp":                                                            false,
+		"":                                                                           false,
+	}
+
+	for value, expected := range cases {
+		timestamp, ok := etcdclient.DefaultTimestamp("/key", value)
+		if ok != expected {
+			t.Errorf("DefaultTimestamp(%q) returned ok %v, expected %v", value, ok, expected)
+		}
+		if ok && !timestamp.Equal(when) {
+			t.Errorf("DefaultTimestamp(%q) returned %v, expected %v", value, timestamp, when)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	etcdClient := dial(t)
+	old := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	recent := time.Now().Format(time.RFC3339)
+	values := map[string]string{
+		"/registry/old":     old,
+		"/registry/dir/old": fmt.Sprintf(`{"timestamp": %q}`, old),
+		"/registry/recent":  recent,
+		"/registry/untimed": "value",
+		"/elsewhere/old":    old,
+	}
+	for key, value := range values {
+		if err := etcdClient.Set(key, value); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	sweeper := etcdClient.Sweeper("/registry", time.Minute)
+	var swept []string
+	sweeper.OnSweep = func(key string) { swept = append(swept, key) }
+	deleted, err := sweeper.Sweep()
+	if err != nil || deleted != 2 {
+		t.Errorf("Sweep returned %v, %v, expected 2 deleted", deleted, err)
+	}
+	sort.Strings(swept)
+	if expected := []string{"/registry/dir/old", "/registry/old"}; !reflect.DeepEqual(swept, expected) {
+		t.Errorf("Sweep deleted %v, expected %v", swept, expected)
+	}
+	for _, key := range []string{"/registry/recent", "/registry/untimed", "/elsewhere/old"} {
+		if value, _ := etcdClient.Get(key); value == "" {
+			t.Errorf("Sweep deleted %v", key)
+		}
+	}
+}
+
+func TestSweepWithATimestampFunc(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/registry/stale", "/registry/live")
+
+	sweeper := etcdClient.Sweeper("/registry", time.Minute)
+	sweeper.Timestamp = func(key, value string) (time.Time, bool) {
+		if strings.HasSuffix(key, "stale") {
+			return time.Now().Add(-time.Hour), true
+		}
+		return time.Now(), true
+	}
+	if deleted, err := sweeper.Sweep(); err != nil || deleted != 1 {
+		t.Errorf("Sweep returned %v, %v, expected 1 deleted", deleted, err)
+	}
+	if value, _ := etcdClient.Get("/registry/live"); value != "value" {
+		t.Error("Sweep deleted an entry its Timestamp said was live")
+	}
+}
+
+func TestSweeperRun(t *testing.T) {
+	etcdClient := dial(t)
+	sweeper := etcdClient.Sweeper("/registry", time.Minute)
+	swept := make(chan string, 10)
+	sweeper.OnSweep = func(key string) { swept <- key }
+
+	ran := make(chan error, 1)
+	go func() {
+		ran <- sweeper.Run(10 * time.Millisecond)
+	}()
+	if err := etcdClient.Set("/registry/old", time.Now().Add(-time.Hour).Format(time.RFC3339)); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if key := receive(t, swept); key != "/registry/old" {
+		t.Errorf("Run swept %v, expected /registry/old", key)
+	}
+
+	etcdClient.Close()
+	select {
+	case err := <-ran:
+		if err == nil {
+			t.Error("Run returned no error after Close")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after Close")
+	}
+}
+
+func TestSweeperRunPassesErrorsToOnError(t *testing.T) {
+	server := etcdtest.StartMemory()
+	etcd, err := server.Client()
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcd.Close()
+	server.Stop()
+	sweeper := etcd.(*etcdclient.SimpleEtcdClient).Sweeper("/registry", time.Minute)
+	failures := make(chan error, 10)
+	sweeper.OnError = func(err error) { failures <- err }
+
+	go sweeper.Run(10 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-failures:
+			if !errors.Is(err, etcdclient.ErrConnRefused) {
+				t.Errorf("OnError got %v, expected ErrConnRefused", err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not keep sweeping after a sweep failed")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package etcdclient
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// SyncToDir writes every key in the directory, recursively, to a file in
+// localDir at the same relative path. Files in localDir that have no key
+// are removed, so localDir should only hold synced files
+func (etcdClient *SimpleEtcdClient) SyncToDir(directory, localDir string) error {
+	values, err := etcdClient.valuesUnder(directory)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(localDir, 0755); err != nil {
+		return err
+	}
+	for relative, value := range values {
+		if err := writeSyncedFile(localDir, relative, value); err != nil {
+			return err
+		}
+	}
+
+	return filepath.Walk(localDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relative, err := filepath.Rel(localDir, file)
+		if err != nil {
+			return err
+		}
+		if _, ok := values[filepath.ToSlash(relative)]; ok {
+			return nil
+		}
+		return os.Remove(file)
+	})
+}
+
+// WatchToDir syncs the directory to localDir like SyncToDir, then keeps
+// localDir up to date as keys change. Changes that cannot be written are
+// logged and skipped.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchToDir(directory, localDir string) error {
+	index, err := etcdClient.Index()
+	if err != nil {
+		return err
+	}
+
+	if err := etcdClient.SyncToDir(directory, localDir); err != nil {
+		return err
+	}
+
+	root := normalizeKey(directory)
+	return etcdClient.WatchEvents(directory, index, func(event Event) {
+		relative := strings.TrimPrefix(strings.TrimPrefix(event.Key, root), "/")
+		file := filepath.Join(localDir, filepath.FromSlash(relative))
+
+		var err error
+		switch {
+		case event.Removed() && event.Dir:
+			err = os.RemoveAll(file)
+		case event.Removed():
+			err = os.Remove(file)
+			if os.IsNotExist(err) {
+				err = nil
+			}
+		case !event.Dir:
+			err = writeSyncedFile(localDir, relative, event.Value)
+		}
+
+		if err != nil {
+			etcdClient.options.log("sync to dir failed", "key", event.Key, "file", file, "err", err)
+		}
+	})
+}
+
+// SyncFromDir sets a key in the directory for every file in localDir,
+// recursively, with the file's contents as its value. Keys in the directory
+// that have no file are deleted
+func (etcdClient *SimpleEtcdClient) SyncFromDir(localDir, directory string) error {
+	kvs := make(map[string]string)
+	err := filepath.Walk(localDir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		relative, err := filepath.Rel(localDir, file)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		kvs[path.Join(directory, filepath.ToSlash(relative))] = string(data)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	existing, err := etcdClient.valuesUnder(directory)
+	if err != nil {
+		return err
+	}
+
+	if err := etcdClient.SetMulti(kvs); err != nil {
+		return err
+	}
+
+	for relative := range existing {
+		key := path.Join(directory, relative)
+		if _, ok := kvs[key]; ok {
+			continue
+		}
+		if err := etcdClient.Del(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSyncedFile replaces the file at the relative path in
+// localDir with the value, so readers never see a partial file
+func writeSyncedFile(localDir, relative, value string) error {
+	file := filepath.Join(localDir, filepath.FromSlash(relative))
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	staged, err := ioutil.TempFile(filepath.Dir(file), "."+filepath.Base(file))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+
+	_, err = staged.WriteString(value)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(staged.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(staged.Name(), file)
+}
@@ -0,0 +1,88 @@
+package etcdclient_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// readFile returns the contents of the file, or "" if it does not exist
+func readFile(t *testing.T, file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatalf("ReadFile returned %v", err)
+	}
+	return string(data)
+}
+
+func TestSyncToDir(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/synced/a", "/synced/dir/b")
+	localDir := t.TempDir()
+	stale := filepath.Join(localDir, "stale")
+	if err := ioutil.WriteFile(stale, []byte("stale"), 0644); err != nil {
+		t.Fatalf("WriteFile returned %v", err)
+	}
+
+	if err := etcdClient.SyncToDir("/synced", localDir); err != nil {
+		t.Fatalf("SyncToDir returned %v", err)
+	}
+	if readFile(t, filepath.Join(localDir, "a")) != "value" || readFile(t, filepath.Join(localDir, "dir", "b")) != "value" {
+		t.Error("SyncToDir did not write a file for every key")
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Error("SyncToDir kept a file that has no key")
+	}
+}
+
+func TestWatchToDir(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/synced/removed")
+	localDir := t.TempDir()
+
+	go etcdClient.WatchToDir("/synced", localDir)
+	waitForFile := func(name, expected string) {
+		deadline := time.Now().Add(5 * time.Second)
+		for readFile(t, filepath.Join(localDir, name)) != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("%v holds %q, expected %q", name, readFile(t, filepath.Join(localDir, name)), expected)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForFile("removed", "value")
+
+	if err := etcdClient.Set("/synced/dir/added", "added"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := etcdClient.Del("/synced/removed"); err != nil {
+		t.Fatalf("Del returned %v", err)
+	}
+	waitForFile("dir/added", "added")
+	waitForFile("removed", "")
+}
+
+func TestSyncFromDir(t *testing.T) {
+	etcdClient := dial(t)
+	setKeys(t, etcdClient, "/synced/stale")
+	localDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(localDir, "dir"), 0755); err != nil {
+		t.Fatalf("MkdirAll returned %v", err)
+	}
+	for name, value := range map[string]string{"a": "1", "dir/b": "2"} {
+		if err := ioutil.WriteFile(filepath.Join(localDir, name), []byte(value), 0644); err != nil {
+			t.Fatalf("WriteFile returned %v", err)
+		}
+	}
+
+	if err := etcdClient.SyncFromDir(localDir, "/synced"); err != nil {
+		t.Fatalf("SyncFromDir returned %v", err)
+	}
+	values, err := etcdClient.GetMulti([]string{"/synced/a", "/synced/dir/b", "/synced/stale"})
+	if expected := map[string]string{"/synced/a": "1", "/synced/dir/b": "2", "/synced/stale": ""}; err != nil || !reflect.DeepEqual(values, expected) {
+		t.Errorf("After SyncFromDir the keys are %v, %v, expected %v", values, err, expected)
+	}
+}
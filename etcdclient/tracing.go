@@ -0,0 +1,47 @@
+package etcdclient
+
+import (
+	"strings"
+
+	"golang.org/x/net/context"
+)
+
+// Tracer starts a span for every request the client makes.
+// Implement it to export spans to a tracing system such as
+// OpenTelemetry, then pass it to WithTracer
+type Tracer interface {
+	// StartSpan starts a span for the operation as a child of any
+	// span in ctx. The attributes describe the request, they include
+	// "etcd.key" and "etcd.endpoints"
+	StartSpan(ctx context.Context, op string, attributes map[string]string) (context.Context, Span)
+}
+
+// Span is a single traced request
+type Span interface {
+	// End finishes the span with the result of the request
+	End(err error)
+}
+
+// WithTracer traces every request with tracer. Use WithContext to
+// make requests part of the trace in the caller's context
+func WithTracer(tracer Tracer) Option {
+	return func(opts *options) {
+		opts.tracer = tracer
+	}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+func (etcdClient *SimpleEtcdClient) startSpan(ctx context.Context, op, key string) (context.Context, Span) {
+	tracer := etcdClient.options.tracer
+	if tracer == nil {
+		return ctx, noopSpan{}
+	}
+
+	return tracer.StartSpan(ctx, op, map[string]string{
+		"etcd.key":       key,
+		"etcd.endpoints": strings.Join(etcdClient.etcd.Endpoints(), ","),
+	})
+}
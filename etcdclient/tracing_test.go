@@ -0,0 +1,89 @@
+package etcdclient_test
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+type traceKey struct{}
+
+// recordedSpan is a span started by recordedTracer
+type recordedSpan struct {
+	tracer     *recordedTracer
+	op         string
+	parent     interface{}
+	attributes map[string]string
+	ended      bool
+	err        error
+}
+
+func (span *recordedSpan) End(err error) {
+	span.tracer.mutex.Lock()
+	defer span.tracer.mutex.Unlock()
+	span.ended = true
+	span.err = err
+}
+
+// recordedTracer keeps every span it starts
+type recordedTracer struct {
+	mutex sync.Mutex
+	spans []*recordedSpan
+}
+
+func (tracer *recordedTracer) StartSpan(ctx context.Context, op string, attributes map[string]string) (context.Context, etcdclient.Span) {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	span := &recordedSpan{tracer: tracer, op: op, parent: ctx.Value(traceKey{}), attributes: attributes}
+	tracer.spans = append(tracer.spans, span)
+	return ctx, span
+}
+
+// find returns the first span of op on key
+func (tracer *recordedTracer) find(op, key string) *recordedSpan {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	for _, span := range tracer.spans {
+		if span.op == op && span.attributes["etcd.key"] == key {
+			return span
+		}
+	}
+	return nil
+}
+
+func TestWithTracerStartsASpanPerRequest(t *testing.T) {
+	tracer := &recordedTracer{}
+	etcdClient := dial(t, etcdclient.WithTracer(tracer))
+	traced := etcdClient.WithContext(context.WithValue(context.Background(), traceKey{}, "caller"))
+
+	if err := traced.Set("/traced/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	traced.Get("/traced/missing")
+
+	set := tracer.find("set", "/traced/key")
+	if set == nil {
+		t.Fatal("No span was started for the Set")
+	}
+	if !set.ended || set.err != nil {
+		t.Errorf("The Set span ended %v with %v, expected it to end without an error", set.ended, set.err)
+	}
+	if set.parent != "caller" {
+		t.Errorf("The Set span was started in %v, expected the caller's context", set.parent)
+	}
+	endpoints := strings.Join(etcdClient.Endpoints(), ",")
+	if set.attributes["etcd.endpoints"] != endpoints {
+		t.Errorf("The Set span has the endpoints %q, expected %q", set.attributes["etcd.endpoints"], endpoints)
+	}
+
+	get := tracer.find("get", "/traced/missing")
+	if get == nil {
+		t.Fatal("No span was started for the Get")
+	}
+	if !get.ended || get.err == nil {
+		t.Errorf("The Get span of a missing key ended %v with %v, expected the error", get.ended, get.err)
+	}
+}
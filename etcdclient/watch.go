@@ -0,0 +1,139 @@
+package etcdclient
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"golang.org/x/net/context"
+)
+
+// Event describes a single change to a key or directory
+type Event struct {
+	// Action is the etcd action that caused the change, such as
+	// "set", "update", "create", "delete", "expire" or "compareAndSwap"
+	Action string `json:"action"`
+
+	Key       string `json:"key"`
+	Value     string `json:"value,omitempty"`
+	PrevValue string `json:"prevValue,omitempty"`
+	Dir       bool   `json:"dir,omitempty"`
+
+	// Index is the etcd index of the change
+	Index uint64 `json:"index"`
+}
+
+// OnEventCallback is used for passing callbacks to
+// WatchEvents
+type OnEventCallback func(event Event)
+
+// Removed returns true if the key or directory no longer exists
+// after the event
+func (event Event) Removed() bool {
+	return isRemoval(event.Action)
+}
+
+func newEvent(response *client.Response) Event {
+	event := Event{
+		Action: response.Action,
+		Key:    response.Node.Key,
+		Value:  response.Node.Value,
+		Dir:    response.Node.Dir,
+		Index:  response.Node.ModifiedIndex,
+	}
+	if response.PrevNode != nil {
+		event.PrevValue = response.PrevNode.Value
+	}
+	return event
+}
+
+// WatchPrefixes watches every prefix and calls the callback everytime something
+// changes in any of them. The callback is never called concurrently. If any of
+// the watches fails, the others are stopped and the error is returned once
+// they have, so the callback is not called after this method returns.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchPrefixes(prefixes []string, onChange OnChangeCallback) error {
+	if len(prefixes) == 0 {
+		return errors.New("WatchPrefixes requires at least one prefix")
+	}
+
+	ctx, cancel := context.WithCancel(etcdClient.ctx)
+	defer cancel()
+
+	watchClient := etcdClient.WithContext(ctx)
+	errs := make(chan error, len(prefixes))
+	var mutex sync.Mutex
+	var watches sync.WaitGroup
+
+	for _, prefix := range prefixes {
+		watches.Add(1)
+		go func(prefix string) {
+			defer watches.Done()
+			errs <- watchClient.WatchRecursive(prefix, func(key, newValue string) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				onChange(key, newValue)
+			})
+		}(prefix)
+	}
+
+	err := <-errs
+	cancel()
+	watches.Wait()
+	return err
+}
+
+// WatchRecursiveDebounced watches a directory and calls the callback once for
+// every burst of changes. The callback is called window after the first change
+// of a burst, any changes within that window are coalesced into the same call.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchRecursiveDebounced(directory string, window time.Duration, onChange func()) error {
+	return etcdClient.WatchRecursiveDebouncedFrom(directory, 0, window, onChange)
+}
+
+// WatchRecursiveDebouncedFrom is WatchRecursiveDebounced for the changes after
+// the given index, so nothing is missed between reading the directory and
+// watching it. If etcd has cleared the index from its history, the callback
+// is called, since something may have changed, and the watch carries on.
+// This method only returns if there is an error
+func (etcdClient *SimpleEtcdClient) WatchRecursiveDebouncedFrom(directory string, afterIndex uint64, window time.Duration, onChange func()) error {
+	changes := make(chan struct{}, 1)
+	errs := make(chan error, 1)
+	changed := func(event Event) {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	go func() {
+		for {
+			err := etcdClient.watchRecursive(directory, afterIndex, false, changed)
+			index, cleared := eventIndexCleared(err)
+			if !cleared {
+				errs <- err
+				return
+			}
+
+			etcdClient.options.log("watch events were cleared, treating them as a change", "directory", directory, "afterIndex", afterIndex, "index", index)
+			afterIndex = index
+			changed(Event{})
+		}
+	}()
+
+	var timer <-chan time.Time
+	for {
+		select {
+		case err := <-errs:
+			return err
+		case <-changes:
+			if timer == nil {
+				timer = time.After(window)
+			}
+		case <-timer:
+			timer = nil
+			onChange()
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package etcdclient_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+)
+
+func TestWatchRetriesWithThePolicy(t *testing.T) {
+	server := etcdtest.StartMemory()
+	var errors int64
+	etcdClient, err := server.Client(
+		etcdclient.WithWatchRetry(etcdclient.RetryPolicy{MaxRetries: 2, InitialBackoff: time.Millisecond}),
+		etcdclient.WithWatchErrorHandler(func(err error) {
+			atomic.AddInt64(&errors, 1)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Client returned %v", err)
+	}
+	defer etcdClient.Close()
+	server.Stop()
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- etcdClient.WatchRecursive("/", func(key, newValue string) {})
+	}()
+
+	select {
+	case err := <-watched:
+		if err == nil {
+			t.Error("WatchRecursive returned no error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchRecursive did not give up")
+	}
+	if errors != 3 {
+		t.Errorf("The error handler was called %v times, expected 3", errors)
+	}
+}
+
+func TestWatchPrefixesReturnsOnceEveryWatchHasStopped(t *testing.T) {
+	etcdClient := dial(t)
+
+	called := make(chan struct{})
+	release := make(chan struct{})
+	watched := make(chan error, 1)
+	go func() {
+		watched <- etcdClient.WatchPrefixes([]string{"/a", "/b"}, func(key, newValue string) {
+			close(called)
+			<-release
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	if err := etcdClient.Set("/a/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	<-called
+
+	// the watch of /b fails, the watch of /a is still in its callback
+	etcdClient.Close()
+	select {
+	case <-watched:
+		t.Fatal("WatchPrefixes returned while a callback was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("WatchPrefixes did not return")
+	}
+}
+
+func TestWatchRecursiveDebouncedCoalescesABurst(t *testing.T) {
+	etcdClient := dial(t)
+
+	var calls int64
+	go etcdClient.WatchRecursiveDebounced("/debounced", 200*time.Millisecond, func() {
+		atomic.AddInt64(&calls, 1)
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	for i := 0; i < 5; i++ {
+		if err := etcdClient.Set("/debounced/key", "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+	time.Sleep(500 * time.Millisecond)
+	if calls := atomic.LoadInt64(&calls); calls != 1 {
+		t.Errorf("The callback was called %v times for one burst, expected once", calls)
+	}
+
+	if err := etcdClient.Set("/debounced/key", "again"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	time.Sleep(500 * time.Millisecond)
+	if calls := atomic.LoadInt64(&calls); calls != 2 {
+		t.Errorf("The callback was called %v times for two bursts, expected twice", calls)
+	}
+}
+
+func TestWatchRecursiveDebouncedFromAClearedIndex(t *testing.T) {
+	etcdClient := dial(t)
+	if err := etcdClient.Set("/debounced/key", "0"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	index, err := etcdClient.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+	for i := 0; i <= etcdtest.MemoryHistory; i++ {
+		if err := etcdClient.Set("/elsewhere", "1"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	called := make(chan struct{}, 1)
+	go etcdClient.WatchRecursiveDebouncedFrom("/debounced", index, 10*time.Millisecond, func() {
+		called <- struct{}{}
+	})
+	select {
+	case <-called:
+	case <-time.After(5 * time.Second):
+		t.Error("The callback was not called after the index was cleared")
+	}
+}
+
+func TestWithWatchWatchdogReconnectsQuietWatches(t *testing.T) {
+	metrics := newRecordedMetrics()
+	logger := &recordedLogger{}
+	etcdClient := dial(t, etcdclient.WithWatchWatchdog(50*time.Millisecond), etcdclient.WithMetrics(metrics), etcdclient.WithLogger(logger))
+	// unlike in etcd, the index of a new etcdtest server is 0,
+	// which a watch cannot resume after
+	if err := etcdClient.Set("/warmup", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	values := make(chan string, 10)
+	go etcdClient.WatchRecursive("/watchdog", func(key, newValue string) {
+		values <- newValue
+	})
+	time.Sleep(300 * time.Millisecond)
+
+	metrics.mutex.Lock()
+	reconnects := metrics.reconnects["/watchdog"]
+	metrics.mutex.Unlock()
+	if reconnects < 2 {
+		t.Errorf("Observed %v reconnects of the quiet watch, expected one every period", reconnects)
+	}
+	logger.mutex.Lock()
+	if len(logger.messages) == 0 || logger.messages[0] != "watch made no progress, reconnecting" {
+		t.Errorf("Logged %v, expected the reconnects", logger.messages)
+	}
+	logger.mutex.Unlock()
+
+	if err := etcdClient.Set("/watchdog/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value := receive(t, values); value != "value" {
+		t.Errorf("The watch got %v after reconnecting, expected the change", value)
+	}
+	etcdClient.Close()
+}
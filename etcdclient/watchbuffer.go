@@ -0,0 +1,179 @@
+package etcdclient
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// defaultWorkerBuffer is the buffer size of each worker started by
+// WithWatchWorkers when WithWatchBuffer is not used
+const defaultWorkerBuffer = 64
+
+// BufferPolicy decides what a watch does with a new event
+// when its buffer is full, see WithWatchBuffer
+type BufferPolicy int
+
+const (
+	// BufferBlock stops reading events until the callback catches up
+	BufferBlock BufferPolicy = iota
+
+	// BufferDropOldest drops the oldest buffered event to make room
+	BufferDropOldest
+
+	// BufferCoalesce keeps only the latest event of every key, so a
+	// burst of changes to a key is delivered as its last change. When
+	// the buffer is full of different keys it blocks like BufferBlock
+	BufferCoalesce
+)
+
+// WithWatchBuffer makes watches read events into a buffer of size events
+// and call the callback from a separate goroutine, so a slow callback does
+// not stall the watch. policy decides what happens when the buffer is full.
+// Events still buffered when a watch returns are discarded
+func WithWatchBuffer(size int, policy BufferPolicy) Option {
+	return func(opts *options) {
+		opts.watchBuffer = size
+		opts.bufferPolicy = policy
+	}
+}
+
+// WithWatchWorkers makes watches call their callback from a pool of
+// workers goroutines, so a slow callback for one key does not delay the
+// others. Every key is always handled by the same goroutine, so the events
+// of a key are delivered in order, but events of different keys may be
+// delivered out of order and the callback must be safe to call
+// concurrently. Each goroutine buffers events as configured by
+// WithWatchBuffer
+func WithWatchWorkers(workers int) Option {
+	return func(opts *options) {
+		opts.watchWorkers = workers
+	}
+}
+
+// dispatch returns the callback a watch should call for every event, and
+// a function to call once the watch returns, which waits for the callbacks
+// to finish. Without buffers or workers, the callback is called directly
+func (opts *options) dispatch(directory string, onEvent OnEventCallback) (OnEventCallback, func()) {
+	if opts.watchBuffer <= 0 && opts.watchWorkers <= 1 {
+		return onEvent, func() {}
+	}
+
+	size := opts.watchBuffer
+	if size <= 0 {
+		size = defaultWorkerBuffer
+	}
+	workers := opts.watchWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	buffers := make([]*eventBuffer, workers)
+	var wait sync.WaitGroup
+	for i := range buffers {
+		buffer := newEventBuffer(size, opts.bufferPolicy)
+		buffers[i] = buffer
+
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for {
+				event, ok := buffer.pop()
+				if !ok {
+					return
+				}
+				onEvent(event)
+			}
+		}()
+	}
+
+	push := func(event Event) {
+		hash := fnv.New32a()
+		hash.Write([]byte(event.Key))
+		buffer := buffers[hash.Sum32()%uint32(workers)]
+
+		if dropped, ok := buffer.push(event); ok {
+			opts.log("dropped watch event", "directory", directory, "key", dropped.Key, "index", dropped.Index)
+		}
+	}
+	stop := func() {
+		for _, buffer := range buffers {
+			buffer.close()
+		}
+		wait.Wait()
+	}
+	return push, stop
+}
+
+type eventBuffer struct {
+	size   int
+	policy BufferPolicy
+
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	events []Event
+	closed bool
+}
+
+func newEventBuffer(size int, policy BufferPolicy) *eventBuffer {
+	buffer := &eventBuffer{size: size, policy: policy}
+	buffer.cond = sync.NewCond(&buffer.mutex)
+	return buffer
+}
+
+// push adds the event to the buffer, waiting for room if the policy
+// requires it. It returns the event that was dropped, if any
+func (buffer *eventBuffer) push(event Event) (Event, bool) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	defer buffer.cond.Broadcast()
+
+	if buffer.policy == BufferCoalesce {
+		for i, buffered := range buffer.events {
+			if buffered.Key == event.Key {
+				buffer.events[i] = event
+				return Event{}, false
+			}
+		}
+	}
+
+	if buffer.policy == BufferDropOldest && len(buffer.events) >= buffer.size {
+		dropped := buffer.events[0]
+		buffer.events = append(buffer.events[1:], event)
+		return dropped, true
+	}
+
+	for len(buffer.events) >= buffer.size && !buffer.closed {
+		buffer.cond.Wait()
+	}
+	if !buffer.closed {
+		buffer.events = append(buffer.events, event)
+	}
+	return Event{}, false
+}
+
+// pop waits for an event and removes it from the buffer. It
+// returns false once the buffer is closed
+func (buffer *eventBuffer) pop() (Event, bool) {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+	defer buffer.cond.Broadcast()
+
+	for len(buffer.events) == 0 && !buffer.closed {
+		buffer.cond.Wait()
+	}
+	if buffer.closed {
+		return Event{}, false
+	}
+
+	event := buffer.events[0]
+	buffer.events = buffer.events[1:]
+	return event, true
+}
+
+func (buffer *eventBuffer) close() {
+	buffer.mutex.Lock()
+	defer buffer.mutex.Unlock()
+
+	buffer.closed = true
+	buffer.cond.Broadcast()
+}
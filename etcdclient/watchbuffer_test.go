@@ -0,0 +1,184 @@
+package etcdclient
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// popKeys pops count events and returns them as key=value
+func popKeys(t *testing.T, buffer *eventBuffer, count int) []string {
+	var popped []string
+	for i := 0; i < count; i++ {
+		event, ok := buffer.pop()
+		if !ok {
+			t.Fatalf("pop returned false after %v", popped)
+		}
+		popped = append(popped, event.Key+"="+event.Value)
+	}
+	return popped
+}
+
+func TestEventBufferBlock(t *testing.T) {
+	buffer := newEventBuffer(1, BufferBlock)
+	buffer.push(Event{Key: "/a", Value: "1"})
+
+	pushed := make(chan struct{})
+	go func() {
+		buffer.push(Event{Key: "/b", Value: "1"})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("push into a full buffer returned before there was room")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if popped := popKeys(t, buffer, 1); popped[0] != "/a=1" {
+		t.Errorf("pop returned %v, expected /a=1", popped)
+	}
+	<-pushed
+	if popped := popKeys(t, buffer, 1); popped[0] != "/b=1" {
+		t.Errorf("pop returned %v, expected the blocked /b=1", popped)
+	}
+}
+
+func TestEventBufferDropOldest(t *testing.T) {
+	buffer := newEventBuffer(2, BufferDropOldest)
+	buffer.push(Event{Key: "/a", Value: "1"})
+	buffer.push(Event{Key: "/b", Value: "1"})
+
+	dropped, ok := buffer.push(Event{Key: "/c", Value: "1"})
+	if !ok || dropped.Key != "/a" {
+		t.Errorf("push into a full buffer dropped %v, %v, expected the oldest event", dropped, ok)
+	}
+	if popped := popKeys(t, buffer, 2); popped[0] != "/b=1" || popped[1] != "/c=1" {
+		t.Errorf("pop returned %v, expected the 2 newest events", popped)
+	}
+}
+
+func TestEventBufferCoalesce(t *testing.T) {
+	buffer := newEventBuffer(2, BufferCoalesce)
+	buffer.push(Event{Key: "/a", Value: "1"})
+	buffer.push(Event{Key: "/b", Value: "1"})
+
+	if _, ok := buffer.push(Event{Key: "/a", Value: "2"}); ok {
+		t.Error("push of a buffered key dropped an event")
+	}
+	if popped := popKeys(t, buffer, 2); popped[0] != "/a=2" || popped[1] != "/b=1" {
+		t.Errorf("pop returned %v, expected the latest change of /a in its place", popped)
+	}
+}
+
+func TestEventBufferCloseUnblocks(t *testing.T) {
+	buffer := newEventBuffer(1, BufferBlock)
+	buffer.push(Event{Key: "/a"})
+
+	pushed := make(chan struct{})
+	go func() {
+		buffer.push(Event{Key: "/b"})
+		close(pushed)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	buffer.close()
+
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("push did not return after close")
+	}
+	if _, ok := buffer.pop(); ok {
+		t.Error("pop of a closed buffer returned an event")
+	}
+}
+
+func TestDispatchKeepsTheOrderOfEveryKey(t *testing.T) {
+	opts := &options{watchWorkers: 4}
+	var mutex sync.Mutex
+	delivered := make(map[string][]string)
+	push, stop := opts.dispatch("/dir", func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		delivered[event.Key] = append(delivered[event.Key], event.Value)
+	})
+
+	for i := 0; i < 20; i++ {
+		for _, key := range []string{"/a", "/b", "/c"} {
+			push(Event{Key: key, Value: fmt.Sprint(i)})
+		}
+	}
+	// stop discards buffered events, wait for them to be delivered
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mutex.Lock()
+		done := len(delivered["/a"]) == 20 && len(delivered["/b"]) == 20 && len(delivered["/c"]) == 20
+		mutex.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the events to be delivered")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	stop()
+
+	for key, values := range delivered {
+		for i, value := range values {
+			if value != fmt.Sprint(i) {
+				t.Fatalf("The events of %v were delivered as %v, expected them in order", key, values)
+			}
+		}
+	}
+}
+
+func TestDispatchDoesNotDelayOtherKeys(t *testing.T) {
+	// find two keys that are handled by different workers
+	worker := func(key string) uint32 {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		return hash.Sum32() % 2
+	}
+	slow, fast := "/slow", ""
+	for i := 0; fast == ""; i++ {
+		if key := fmt.Sprintf("/fast-%v", i); worker(key) != worker(slow) {
+			fast = key
+		}
+	}
+
+	opts := &options{watchWorkers: 2}
+	release := make(chan struct{})
+	delivered := make(chan string, 10)
+	push, stop := opts.dispatch("/dir", func(event Event) {
+		if event.Key == slow {
+			<-release
+		}
+		delivered <- event.Key
+	})
+	defer stop()
+	defer close(release)
+
+	push(Event{Key: slow})
+	push(Event{Key: fast})
+	select {
+	case key := <-delivered:
+		if key != fast {
+			t.Errorf("%v was delivered first, expected %v", key, fast)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("A slow callback for one key delayed the others")
+	}
+}
+
+func TestDispatchWithoutBuffersOrWorkersCallsTheCallback(t *testing.T) {
+	called := false
+	push, stop := (&options{}).dispatch("/dir", func(event Event) { called = true })
+	push(Event{Key: "/a"})
+	stop()
+
+	if !called {
+		t.Error("The callback was not called directly")
+	}
+}
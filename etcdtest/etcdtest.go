@@ -0,0 +1,171 @@
+// Package etcdtest starts a real etcd for integration tests
+package etcdtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// Image is the docker image used when there is no etcd binary on the PATH
+var Image = "quay.io/coreos/etcd:v2.3.8"
+
+// StartTimeout is how long Start waits for etcd to accept requests
+var StartTimeout = 30 * time.Second
+
+// TB is the part of testing.TB New uses
+type TB interface {
+	Fatalf(format string, args ...interface{})
+}
+
+// Server is an etcd started by Start
+type Server struct {
+	// URI is the client uri of the server
+	URI string
+
+	cmd       *exec.Cmd
+	container string
+	dataDir   string
+}
+
+// New starts etcd and returns a client connected to it, failing the test if
+// either could not be done. Call stop, usually with defer, to close the
+// client, stop etcd and remove its data
+func New(t TB, opts ...etcdclient.Option) (etcd etcdclient.EtcdClient, stop func()) {
+	server, err := Start()
+	if err != nil {
+		t.Fatalf("etcdtest: %v", err)
+	}
+
+	etcd, err = server.Client(opts...)
+	if err != nil {
+		server.Stop()
+		t.Fatalf("etcdtest: %v", err)
+	}
+
+	return etcd, func() {
+		etcd.Close()
+		server.Stop()
+	}
+}
+
+// Start starts etcd listening on random ports, using the etcd binary on the
+// PATH or, if there is none, the docker Image. It returns once etcd accepts
+// requests
+func Start() (*Server, error) {
+	clientPort, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	server := &Server{URI: fmt.Sprintf("http://127.0.0.1:%v", clientPort)}
+	if _, err := exec.LookPath("etcd"); err == nil {
+		err = server.startBinary()
+	} else {
+		err = server.startDocker(clientPort)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := server.waitUntilReady(); err != nil {
+		server.Stop()
+		return nil, err
+	}
+	return server, nil
+}
+
+// Client dials the server
+func (server *Server) Client(opts ...etcdclient.Option) (etcdclient.EtcdClient, error) {
+	return etcdclient.Dial(server.URI, opts...)
+}
+
+// Stop stops etcd and removes its data
+func (server *Server) Stop() error {
+	var err error
+	if server.container != "" {
+		err = exec.Command("docker", "rm", "-f", server.container).Run()
+	}
+
+	if server.cmd != nil && server.cmd.Process != nil {
+		server.cmd.Process.Kill()
+		server.cmd.Wait()
+	}
+
+	if server.dataDir != "" {
+		os.RemoveAll(server.dataDir)
+	}
+	return err
+}
+
+func (server *Server) startBinary() error {
+	peerPort, err := freePort()
+	if err != nil {
+		return err
+	}
+
+	dataDir, err := ioutil.TempDir("", "etcdtest")
+	if err != nil {
+		return err
+	}
+	server.dataDir = dataDir
+
+	peerURI := fmt.Sprintf("http://127.0.0.1:%v", peerPort)
+	server.cmd = exec.Command("etcd",
+		"--name", "etcdtest",
+		"--data-dir", dataDir,
+		"--listen-client-urls", server.URI,
+		"--advertise-client-urls", server.URI,
+		"--listen-peer-urls", peerURI,
+		"--initial-advertise-peer-urls", peerURI,
+		"--initial-cluster", "etcdtest="+peerURI,
+	)
+	return server.cmd.Start()
+}
+
+func (server *Server) startDocker(clientPort int) error {
+	server.container = fmt.Sprintf("etcdtest-%v-%v", os.Getpid(), clientPort)
+	server.cmd = exec.Command("docker", "run", "--rm",
+		"--name", server.container,
+		"--publish", fmt.Sprintf("127.0.0.1:%v:2379", clientPort),
+		Image,
+		"--listen-client-urls", "http://0.0.0.0:2379",
+		"--advertise-client-urls", server.URI,
+	)
+	return server.cmd.Start()
+}
+
+func (server *Server) waitUntilReady() error {
+	etcd, err := server.Client()
+	if err != nil {
+		return err
+	}
+	defer etcd.Close()
+
+	deadline := time.Now().Add(StartTimeout)
+	for {
+		err := etcd.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("etcd did not start within %v: %v", StartTimeout, err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// freePort returns a port nothing is currently listening on
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
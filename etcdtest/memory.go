@@ -0,0 +1,529 @@
+package etcdtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/coreos/etcd/client"
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// MemoryHistory is how many events a MemoryServer remembers for
+// watches, like etcd it forgets the older ones
+var MemoryHistory = 1000
+
+// MemoryServer is an etcd kept in memory that answers the requests of the
+// v2 keys api the etcdclient package makes, for tests that cannot start a
+// real etcd. It also answers the members list and the health check. Keys
+// expire like in etcd, within 100ms of their ttl
+type MemoryServer struct {
+	// URI is the client uri of the server
+	URI string
+
+	server   *httptest.Server
+	stop     chan struct{}
+	stopOnce sync.Once
+
+	mutex   sync.Mutex
+	index   uint64
+	root    *memoryNode
+	events  []client.Response
+	changed chan struct{}
+}
+
+type memoryNode struct {
+	key        string
+	value      string
+	dir        bool
+	expiration time.Time
+	created    uint64
+	modified   uint64
+	children   map[string]*memoryNode
+}
+
+// NewMemory starts a MemoryServer and returns a client connected to it,
+// failing the test if the client could not be created. Call stop, usually
+// with defer, to close the client and the server
+func NewMemory(t TB, opts ...etcdclient.Option) (etcd etcdclient.EtcdClient, stop func()) {
+	server := StartMemory()
+
+	etcd, err := server.Client(opts...)
+	if err != nil {
+		server.Stop()
+		t.Fatalf("etcdtest: %v", err)
+	}
+
+	return etcd, func() {
+		etcd.Close()
+		server.Stop()
+	}
+}
+
+// StartMemory starts a MemoryServer listening on a random port
+func StartMemory() *MemoryServer {
+	server := &MemoryServer{
+		stop:    make(chan struct{}),
+		root:    &memoryNode{key: "/", dir: true, children: make(map[string]*memoryNode)},
+		changed: make(chan struct{}),
+	}
+	server.server = httptest.NewServer(http.HandlerFunc(server.serveHTTP))
+	server.URI = server.server.URL
+
+	go server.expireLoop()
+	return server
+}
+
+// Client dials the server
+func (server *MemoryServer) Client(opts ...etcdclient.Option) (etcdclient.EtcdClient, error) {
+	return etcdclient.Dial(server.URI, opts...)
+}
+
+// Stop stops the server and drops its keys, it
+// can be called again once the server is stopped
+func (server *MemoryServer) Stop() error {
+	server.stopOnce.Do(func() {
+		close(server.stop)
+		server.server.CloseClientConnections()
+		server.server.Close()
+	})
+	return nil
+}
+
+// DropConnections closes the connections of every client, like a
+// network failure, so watches fail and requests are retried
+func (server *MemoryServer) DropConnections() {
+	server.server.CloseClientConnections()
+}
+
+// Index returns the index of the last change
+func (server *MemoryServer) Index() uint64 {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.index
+}
+
+func (server *MemoryServer) serveHTTP(writer http.ResponseWriter, request *http.Request) {
+	switch {
+	case request.URL.Path == "/health":
+		writer.Write([]byte(`{"health": "true"}`))
+		return
+	case strings.HasPrefix(request.URL.Path, "/v2/members"):
+		server.members(writer, request.URL.Path == "/v2/members/leader")
+		return
+	case !strings.HasPrefix(request.URL.Path, "/v2/keys"):
+		http.NotFound(writer, request)
+		return
+	}
+
+	key := path.Clean("/" + strings.TrimPrefix(request.URL.Path, "/v2/keys"))
+	query := request.URL.Query()
+	if request.Method == http.MethodGet && query.Get("wait") == "true" {
+		server.watch(writer, request, key)
+		return
+	}
+
+	request.ParseForm()
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	var status int
+	var response *client.Response
+	var err *client.Error
+	switch request.Method {
+	case http.MethodGet:
+		status, response, err = server.get(key, query.Get("recursive") == "true", query.Get("sorted") == "true")
+	case http.MethodPut:
+		status, response, err = server.put(key, request)
+	case http.MethodPost:
+		status, response, err = server.post(key, request)
+	case http.MethodDelete:
+		status, response, err = server.delete(key, query)
+	default:
+		http.Error(writer, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	server.write(writer, status, response, err)
+}
+
+// members answers the members list, or the leader, with the
+// server as the only member
+func (server *MemoryServer) members(writer http.ResponseWriter, leader bool) {
+	member := map[string]interface{}{
+		"id":         "memory",
+		"name":       "memory",
+		"peerURLs":   []string{server.URI},
+		"clientURLs": []string{server.URI},
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	if leader {
+		json.NewEncoder(writer).Encode(member)
+		return
+	}
+	json.NewEncoder(writer).Encode(map[string]interface{}{"members": []interface{}{member}})
+}
+
+// write sends the response, or the error, the mutex must be held
+func (server *MemoryServer) write(writer http.ResponseWriter, status int, response *client.Response, err *client.Error) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("X-Etcd-Index", strconv.FormatUint(server.index, 10))
+	if err != nil {
+		err.Index = server.index
+		writer.WriteHeader(errorStatus(err.Code))
+		json.NewEncoder(writer).Encode(err)
+		return
+	}
+	writer.WriteHeader(status)
+	json.NewEncoder(writer).Encode(response)
+}
+
+func errorStatus(code int) int {
+	switch code {
+	case client.ErrorCodeKeyNotFound:
+		return http.StatusNotFound
+	case client.ErrorCodeTestFailed, client.ErrorCodeNodeExist:
+		return http.StatusPreconditionFailed
+	case client.ErrorCodeEventIndexCleared:
+		return http.StatusBadRequest
+	default:
+		return http.StatusForbidden
+	}
+}
+
+func newError(code int, message, cause string) *client.Error {
+	return &client.Error{Code: code, Message: message, Cause: cause}
+}
+
+func (server *MemoryServer) get(key string, recursive, sorted bool) (int, *client.Response, *client.Error) {
+	node := server.lookup(key)
+	if node == nil {
+		return 0, nil, newError(client.ErrorCodeKeyNotFound, "Key not found", key)
+	}
+	return http.StatusOK, &client.Response{Action: "get", Node: node.snapshot(recursive, sorted, 0)}, nil
+}
+
+func (server *MemoryServer) put(key string, request *http.Request) (int, *client.Response, *client.Error) {
+	query := request.URL.Query()
+	dir := query.Get("dir") == "true"
+	prevExist := query.Get("prevExist")
+	ttl, err := parseTTL(request.PostForm.Get("ttl"))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	existing := server.lookup(key)
+	if prevExist == "true" && existing == nil {
+		return 0, nil, newError(client.ErrorCodeKeyNotFound, "Key not found", key)
+	}
+	if prevExist == "false" && existing != nil {
+		return 0, nil, newError(client.ErrorCodeNodeExist, "Key already exists", key)
+	}
+	if err := compare(existing, key, query); err != nil {
+		return 0, nil, err
+	}
+
+	if request.PostForm.Get("refresh") == "true" {
+		if existing == nil {
+			return 0, nil, newError(client.ErrorCodeKeyNotFound, "Key not found", key)
+		}
+		// refreshes do not notify watches
+		existing.expiration = expiration(ttl)
+		return http.StatusOK, &client.Response{Action: "update", Node: existing.snapshot(false, false, 0)}, nil
+	}
+
+	if existing != nil && existing.dir != dir || existing != nil && dir && prevExist != "true" {
+		return 0, nil, newError(client.ErrorCodeNotFile, "Not a file", key)
+	}
+
+	action := "set"
+	switch {
+	case query.Get("prevValue") != "" || query.Get("prevIndex") != "":
+		action = "compareAndSwap"
+	case prevExist == "true":
+		action = "update"
+	case prevExist == "false":
+		action = "create"
+	}
+	return server.store(key, request.PostForm.Get("value"), dir, ttl, action)
+}
+
+func (server *MemoryServer) post(key string, request *http.Request) (int, *client.Response, *client.Error) {
+	ttl, err := parseTTL(request.PostForm.Get("ttl"))
+	if err != nil {
+		return 0, nil, err
+	}
+	return server.store(path.Join(key, fmt.Sprintf("%020d", server.index+1)), request.PostForm.Get("value"), false, ttl, "create")
+}
+
+func (server *MemoryServer) delete(key string, query url.Values) (int, *client.Response, *client.Error) {
+	node := server.lookup(key)
+	if node == nil {
+		return 0, nil, newError(client.ErrorCodeKeyNotFound, "Key not found", key)
+	}
+	if node == server.root {
+		return 0, nil, newError(client.ErrorCodeRootROnly, "Root is read only", key)
+	}
+	if err := compare(node, key, query); err != nil {
+		return 0, nil, err
+	}
+	if node.dir && query.Get("dir") != "true" && query.Get("recursive") != "true" {
+		return 0, nil, newError(client.ErrorCodeNotFile, "Not a file", key)
+	}
+	if node.dir && len(node.children) > 0 && query.Get("recursive") != "true" {
+		return 0, nil, newError(client.ErrorCodeDirNotEmpty, "Directory not empty", key)
+	}
+
+	action := "delete"
+	if query.Get("prevValue") != "" || query.Get("prevIndex") != "" {
+		action = "compareAndDelete"
+	}
+	return http.StatusOK, server.remove(node, action), nil
+}
+
+// remove deletes the node and records the event, the mutex must be held
+func (server *MemoryServer) remove(node *memoryNode, action string) *client.Response {
+	server.index++
+	delete(server.lookup(path.Dir(node.key)).children, path.Base(node.key))
+	removed := &client.Node{Key: node.key, Dir: node.dir, CreatedIndex: node.created, ModifiedIndex: server.index}
+	response := &client.Response{Action: action, Node: removed, PrevNode: node.snapshot(false, false, 0)}
+	server.notify(response)
+	return response
+}
+
+// compare checks the prevValue and prevIndex conditions of the request
+func compare(node *memoryNode, key string, query url.Values) *client.Error {
+	prevValue, prevIndex := query.Get("prevValue"), query.Get("prevIndex")
+	if prevValue == "" && prevIndex == "" {
+		return nil
+	}
+	if node == nil {
+		return newError(client.ErrorCodeKeyNotFound, "Key not found", key)
+	}
+	if prevValue != "" && prevValue != node.value {
+		return newError(client.ErrorCodeTestFailed, "Compare failed", fmt.Sprintf("[%v != %v]", prevValue, node.value))
+	}
+	if prevIndex != "" && prevIndex != strconv.FormatUint(node.modified, 10) {
+		return newError(client.ErrorCodeTestFailed, "Compare failed", fmt.Sprintf("[%v != %v]", prevIndex, node.modified))
+	}
+	return nil
+}
+
+func parseTTL(value string) (time.Duration, *client.Error) {
+	if value == "" {
+		return 0, nil
+	}
+	seconds, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, newError(client.ErrorCodeTTLNaN, "The given TTL in POST form is not a number", "Update")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// expiration returns when a node with the ttl expires, or zero if it does not
+func expiration(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(ttl)
+}
+
+// store sets the node of the key, creating the directories above it,
+// the mutex must be held
+func (server *MemoryServer) store(key, value string, dir bool, ttl time.Duration, action string) (int, *client.Response, *client.Error) {
+	parent := server.root
+	parts := strings.Split(strings.Trim(key, "/"), "/")
+	for i, name := range parts[:len(parts)-1] {
+		child := parent.children[name]
+		if child == nil {
+			child = &memoryNode{key: "/" + strings.Join(parts[:i+1], "/"), dir: true, children: make(map[string]*memoryNode)}
+			parent.children[name] = child
+		}
+		if !child.dir {
+			return 0, nil, newError(client.ErrorCodeNotDir, "Not a directory", child.key)
+		}
+		parent = child
+	}
+
+	server.index++
+	name := parts[len(parts)-1]
+	previous := parent.children[name]
+	node := &memoryNode{key: key, value: value, dir: dir, expiration: expiration(ttl), created: server.index, modified: server.index}
+	if dir {
+		node.children = make(map[string]*memoryNode)
+	}
+	if previous != nil {
+		node.created = previous.created
+		node.children = previous.children
+	}
+	parent.children[name] = node
+
+	status := http.StatusCreated
+	response := &client.Response{Action: action, Node: node.snapshot(false, false, 0)}
+	if previous != nil {
+		status = http.StatusOK
+		response.PrevNode = previous.snapshot(false, false, 0)
+	}
+	server.notify(response)
+	return status, response, nil
+}
+
+// notify records the event and wakes the watches, the mutex must be held
+func (server *MemoryServer) notify(response *client.Response) {
+	server.events = append(server.events, *response)
+	if len(server.events) > MemoryHistory {
+		server.events = server.events[len(server.events)-MemoryHistory:]
+	}
+	close(server.changed)
+	server.changed = make(chan struct{})
+}
+
+// watch answers with the first event at or after waitIndex for the key,
+// or for the keys under it if recursive, once there is one. Like etcd,
+// changes to hidden keys, whose name starts with _, only wake watches
+// of the hidden key or of the keys under it
+func (server *MemoryServer) watch(writer http.ResponseWriter, request *http.Request, key string) {
+	query := request.URL.Query()
+	recursive := query.Get("recursive") == "true"
+	waitIndex, _ := strconv.ParseUint(query.Get("waitIndex"), 10, 64)
+
+	server.mutex.Lock()
+	if waitIndex == 0 {
+		waitIndex = server.index + 1
+	}
+	for {
+		if len(server.events) > 0 && waitIndex < server.events[0].Node.ModifiedIndex {
+			err := newError(client.ErrorCodeEventIndexCleared, "The event in requested index is outdated and cleared",
+				fmt.Sprintf("the requested history has been cleared [%v/%v]", server.events[0].Node.ModifiedIndex, waitIndex))
+			server.write(writer, 0, nil, err)
+			server.mutex.Unlock()
+			return
+		}
+		for _, event := range server.events {
+			if event.Node.ModifiedIndex < waitIndex {
+				continue
+			}
+			if event.Node.Key == key || recursive && isAncestor(key, event.Node.Key) && !hidden(key, event.Node.Key) {
+				server.write(writer, http.StatusOK, &event, nil)
+				server.mutex.Unlock()
+				return
+			}
+		}
+
+		// like an etcd watcher, a waiting watch sees every new event
+		// even if the history it started from is cleared meanwhile
+		waitIndex = server.index + 1
+		changed := server.changed
+		server.mutex.Unlock()
+		select {
+		case <-changed:
+		case <-request.Context().Done():
+			return
+		case <-server.stop:
+			return
+		}
+		server.mutex.Lock()
+	}
+}
+
+// expireLoop removes the nodes whose ttl ran out until the server stops
+func (server *MemoryServer) expireLoop() {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-server.stop:
+			return
+		case now := <-ticker.C:
+			server.mutex.Lock()
+			server.expire(server.root, now)
+			server.mutex.Unlock()
+		}
+	}
+}
+
+// expire removes the expired nodes under node, the mutex must be held
+func (server *MemoryServer) expire(node *memoryNode, now time.Time) {
+	names := make([]string, 0, len(node.children))
+	for name := range node.children {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		child := node.children[name]
+		if !child.expiration.IsZero() && !now.Before(child.expiration) {
+			server.remove(child, "expire")
+			continue
+		}
+		if child.dir {
+			server.expire(child, now)
+		}
+	}
+}
+
+func (server *MemoryServer) lookup(key string) *memoryNode {
+	node := server.root
+	for _, name := range strings.Split(strings.Trim(key, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		if !node.dir {
+			return nil
+		}
+		node = node.children[name]
+		if node == nil {
+			return nil
+		}
+	}
+	return node
+}
+
+// snapshot returns the node as etcd sends it, with its children if it is
+// the node read or if recursive, and their children if recursive. Hidden
+// children, whose name starts with _, are left out like etcd does
+func (node *memoryNode) snapshot(recursive, sorted bool, depth int) *client.Node {
+	snapshot := &client.Node{Key: node.key, Value: node.value, Dir: node.dir, CreatedIndex: node.created, ModifiedIndex: node.modified}
+	if !node.expiration.IsZero() {
+		expiration := node.expiration
+		snapshot.Expiration = &expiration
+		snapshot.TTL = int64(time.Until(expiration)/time.Second) + 1
+	}
+	if !node.dir || depth > 0 && !recursive {
+		return snapshot
+	}
+
+	for name, child := range node.children {
+		if strings.HasPrefix(name, "_") {
+			continue
+		}
+		snapshot.Nodes = append(snapshot.Nodes, child.snapshot(recursive, sorted, depth+1))
+	}
+	if sorted {
+		sort.Slice(snapshot.Nodes, func(i, j int) bool {
+			return snapshot.Nodes[i].Key < snapshot.Nodes[j].Key
+		})
+	}
+	return snapshot
+}
+
+func isAncestor(directory, key string) bool {
+	return strings.HasPrefix(key, strings.TrimSuffix(directory, "/")+"/")
+}
+
+// hidden returns true if a part of the key below directory starts with _
+func hidden(directory, key string) bool {
+	for _, name := range strings.Split(strings.TrimPrefix(key, strings.TrimSuffix(directory, "/")), "/") {
+		if strings.HasPrefix(name, "_") {
+			return true
+		}
+	}
+	return false
+}
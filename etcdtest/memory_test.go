@@ -0,0 +1,71 @@
+package etcdtest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+func TestMemorySetGetLs(t *testing.T) {
+	etcd, stop := NewMemory(t)
+	defer stop()
+
+	for key, value := range map[string]string{"/a/b": "1", "/a/c/d": "2", "/a/_hidden": "3"} {
+		if err := etcd.Set(key, value); err != nil {
+			t.Fatalf("Set(%v) returned %v", key, err)
+		}
+	}
+
+	value, err := etcd.Get("/a/c/d")
+	if err != nil || value != "2" {
+		t.Errorf("Get returned %q, %v, expected \"2\"", value, err)
+	}
+	value, err = etcd.Get("/a/_hidden")
+	if err != nil || value != "3" {
+		t.Errorf("Get of a hidden key returned %q, %v, expected \"3\"", value, err)
+	}
+
+	keys, err := etcd.LsRecursive("/a")
+	if err != nil {
+		t.Fatalf("LsRecursive returned %v", err)
+	}
+	if expected := []string{"/a/b", "/a/c", "/a/c/d"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("LsRecursive returned %v, expected %v", keys, expected)
+	}
+
+	if err := etcd.Del("/a/b"); err != nil {
+		t.Fatalf("Del returned %v", err)
+	}
+	if value, _ := etcd.Get("/a/b"); value != "" {
+		t.Errorf("Get after Del returned %q", value)
+	}
+}
+
+func TestMemoryWatchAndExpire(t *testing.T) {
+	etcd, stop := NewMemory(t)
+	defer stop()
+
+	if err := etcd.(*etcdclient.SimpleEtcdClient).EnsureDirWithTTL("/expiring", time.Second); err != nil {
+		t.Fatalf("EnsureDirWithTTL returned %v", err)
+	}
+	index, err := etcd.Index()
+	if err != nil {
+		t.Fatalf("Index returned %v", err)
+	}
+
+	actions := make(chan string, 1)
+	go etcd.WatchRecursiveFrom("/", index, func(key, newValue string, index uint64) {
+		actions <- key
+	})
+
+	select {
+	case key := <-actions:
+		if key != "/expiring" {
+			t.Errorf("Watch saw %v, expected /expiring", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The key did not expire")
+	}
+}
@@ -0,0 +1,215 @@
+// Package flags stores feature flags in etcd. Every flag is a key in a
+// directory holding the percentage of calls for which it is enabled, from
+// 0 to 100. Flags are cached locally and kept fresh by a watch, so checking
+// a flag never makes a request to etcd. If the watch fails, the flags are
+// read again and the watch restarted, Err reports the failure meanwhile
+package flags
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// Flags is a locally cached set of feature flags stored in an etcd directory
+type Flags struct {
+	etcd      *etcdclient.SimpleEtcdClient
+	directory string
+	cancel    context.CancelFunc
+
+	mutex       sync.RWMutex
+	percentages map[string]int
+	err         error
+}
+
+// RetryBackoff is how long Flags waits before reading the flags again
+// after its watch failed, it doubles on every consecutive failure
+var RetryBackoff = 100 * time.Millisecond
+
+// MaxRetryBackoff caps the wait between retries
+var MaxRetryBackoff = 10 * time.Second
+
+// New loads the flags in the directory and starts watching it
+// for changes. Call Close to stop watching
+func New(etcd *etcdclient.SimpleEtcdClient, directory string) (*Flags, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	flags := &Flags{
+		etcd:        etcd,
+		directory:   directory,
+		cancel:      cancel,
+		percentages: make(map[string]int),
+	}
+
+	index, err := flags.load()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	etcd.Go(func(closed context.Context) {
+		flags.watch(ctx, closed, index)
+	})
+	return flags, nil
+}
+
+// load reads every flag, replacing the cached ones, and
+// returns the index to watch for changes after
+func (flags *Flags) load() (uint64, error) {
+	index, err := flags.etcd.Index()
+	if err != nil {
+		return 0, err
+	}
+
+	names, err := flags.etcd.LsKeys(flags.directory)
+	if err != nil {
+		return 0, err
+	}
+
+	keys := make([]string, len(names))
+	for i, name := range names {
+		keys[i] = path.Join(flags.directory, name)
+	}
+
+	values, err := flags.etcd.GetMulti(keys)
+	if err != nil {
+		return 0, err
+	}
+
+	percentages := make(map[string]int)
+	for key, value := range values {
+		flags.cache(percentages, key, value)
+	}
+
+	flags.mutex.Lock()
+	defer flags.mutex.Unlock()
+	flags.percentages = percentages
+	return index, nil
+}
+
+// watch keeps the flags fresh until Close is called or the client is
+// closed. When the watch fails, for instance because the index was
+// cleared, the flags are read again and the watch starts over
+func (flags *Flags) watch(ctx, closed context.Context, index uint64) {
+	etcd := flags.etcd.WithContext(ctx)
+	stopped := func() bool {
+		return ctx.Err() != nil || closed.Err() != nil
+	}
+
+	backoff := RetryBackoff
+	for {
+		err := etcd.WatchRecursiveFrom(flags.directory, index, func(key, newValue string, index uint64) {
+			flags.update(key, newValue)
+		})
+
+		for !stopped() {
+			flags.setErr(err)
+			select {
+			case <-ctx.Done():
+			case <-closed.Done():
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > MaxRetryBackoff {
+				backoff = MaxRetryBackoff
+			}
+
+			index, err = flags.load()
+			if err == nil {
+				break
+			}
+		}
+		if stopped() {
+			return
+		}
+
+		flags.setErr(nil)
+		backoff = RetryBackoff
+	}
+}
+
+// Err returns the error that stopped the watch, while the flags are
+// being read again, or nil if the cached flags are being kept fresh
+func (flags *Flags) Err() error {
+	flags.mutex.RLock()
+	defer flags.mutex.RUnlock()
+	return flags.err
+}
+
+func (flags *Flags) setErr(err error) {
+	flags.mutex.Lock()
+	defer flags.mutex.Unlock()
+	flags.err = err
+}
+
+// IsEnabled returns true if the flag is enabled. A flag enabled for a
+// percentage is enabled for that percentage of calls, picked at random
+func (flags *Flags) IsEnabled(flag string) bool {
+	percentage := flags.Percentage(flag)
+	return percentage >= 100 || rand.Intn(100) < percentage
+}
+
+// IsEnabledFor returns true if the flag is enabled for the id. A flag
+// enabled for a percentage is always enabled for the same ids
+func (flags *Flags) IsEnabledFor(flag, id string) bool {
+	hash := fnv.New32a()
+	hash.Write([]byte(flag + "/" + id))
+	return int(hash.Sum32()%100) < flags.Percentage(flag)
+}
+
+// Percentage returns the percentage the flag is enabled for,
+// 0 if the flag does not exist
+func (flags *Flags) Percentage(flag string) int {
+	flags.mutex.RLock()
+	defer flags.mutex.RUnlock()
+	return flags.percentages[flag]
+}
+
+// Enable enables the flag for everything
+func (flags *Flags) Enable(flag string) error {
+	return flags.EnablePercentage(flag, 100)
+}
+
+// Disable disables the flag for everything
+func (flags *Flags) Disable(flag string) error {
+	return flags.EnablePercentage(flag, 0)
+}
+
+// EnablePercentage enables the flag for a percentage of calls or ids
+func (flags *Flags) EnablePercentage(flag string, percentage int) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("Percentage must be between 0 and 100, got %v", percentage)
+	}
+	return flags.etcd.Set(path.Join(flags.directory, flag), strconv.Itoa(percentage))
+}
+
+// Close stops watching for changes, the cached flags
+// can still be read
+func (flags *Flags) Close() {
+	flags.cancel()
+}
+
+// update caches the value of the flag stored at key. Values
+// that are not a percentage disable the flag
+func (flags *Flags) update(key, value string) {
+	flags.mutex.Lock()
+	defer flags.mutex.Unlock()
+	flags.cache(flags.percentages, key, value)
+}
+
+// cache stores the value of the flag at key in percentages
+func (flags *Flags) cache(percentages map[string]int, key, value string) {
+	name := strings.TrimPrefix(strings.TrimPrefix(key, "/"+strings.Trim(flags.directory, "/")), "/")
+	percentage, err := strconv.Atoi(value)
+	if err != nil || percentage <= 0 {
+		delete(percentages, name)
+		return
+	}
+	percentages[name] = percentage
+}
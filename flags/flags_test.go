@@ -0,0 +1,113 @@
+package flags_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"github.com/octoblu/go-simple-etcd-client/flags"
+)
+
+// newFlags returns the flags in /flags of a new etcdtest.MemoryServer,
+// they are closed and the server stopped when the test ends
+func newFlags(t *testing.T) (*flags.Flags, *etcdclient.SimpleEtcdClient) {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	etcdClient := etcd.(*etcdclient.SimpleEtcdClient)
+
+	featureFlags, err := flags.New(etcdClient, "/flags")
+	if err != nil {
+		t.Fatalf("New returned %v", err)
+	}
+	t.Cleanup(featureFlags.Close)
+	return featureFlags, etcdClient
+}
+
+// waitForPercentage waits until the cached flag has the percentage
+func waitForPercentage(t *testing.T, featureFlags *flags.Flags, flag string, percentage int) {
+	deadline := time.Now().Add(5 * time.Second)
+	for featureFlags.Percentage(flag) != percentage {
+		if time.Now().After(deadline) {
+			t.Fatalf("%v is enabled for %v%%, expected %v%%", flag, featureFlags.Percentage(flag), percentage)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestFlagsLoadsExistingFlags(t *testing.T) {
+	etcd, stop := etcdtest.NewMemory(t)
+	defer stop()
+	if err := etcd.Set("/flags/existing", "100"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	featureFlags, err := flags.New(etcd.(*etcdclient.SimpleEtcdClient), "/flags")
+	if err != nil {
+		t.Fatalf("New returned %v", err)
+	}
+	defer featureFlags.Close()
+	if !featureFlags.IsEnabled("existing") {
+		t.Error("An existing flag is not enabled")
+	}
+	if featureFlags.IsEnabled("missing") {
+		t.Error("A missing flag is enabled")
+	}
+}
+
+func TestFlagsFollowChanges(t *testing.T) {
+	featureFlags, etcd := newFlags(t)
+
+	if err := featureFlags.Enable("feature"); err != nil {
+		t.Fatalf("Enable returned %v", err)
+	}
+	waitForPercentage(t, featureFlags, "feature", 100)
+
+	if err := featureFlags.Disable("feature"); err != nil {
+		t.Fatalf("Disable returned %v", err)
+	}
+	waitForPercentage(t, featureFlags, "feature", 0)
+
+	if err := etcd.Set("/flags/feature", "not a percentage"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if err := featureFlags.Enable("other"); err != nil {
+		t.Fatalf("Enable returned %v", err)
+	}
+	waitForPercentage(t, featureFlags, "other", 100)
+	if featureFlags.IsEnabled("feature") {
+		t.Error("A flag that is not a percentage is enabled")
+	}
+	if err := featureFlags.Err(); err != nil {
+		t.Errorf("Err returned %v while watching", err)
+	}
+}
+
+func TestFlagsEnabledForAPercentageOfIds(t *testing.T) {
+	featureFlags, _ := newFlags(t)
+	if err := featureFlags.EnablePercentage("feature", 30); err != nil {
+		t.Fatalf("EnablePercentage returned %v", err)
+	}
+	waitForPercentage(t, featureFlags, "feature", 30)
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprint(i)
+		if featureFlags.IsEnabledFor("feature", id) {
+			enabled++
+		}
+		if featureFlags.IsEnabledFor("feature", id) != featureFlags.IsEnabledFor("feature", id) {
+			t.Fatalf("IsEnabledFor changed its answer for %v", id)
+		}
+	}
+	if enabled < 200 || enabled > 400 {
+		t.Errorf("The flag is enabled for %v of 1000 ids, expected about 300", enabled)
+	}
+
+	for _, percentage := range []int{-1, 101} {
+		if err := featureFlags.EnablePercentage("feature", percentage); err == nil {
+			t.Errorf("EnablePercentage(%v) returned no error", percentage)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Package publish republishes the changes made under an etcd directory to
+// a message broker, so workers can consume them from their existing queues.
+// Brokers plug in through the small Publisher interface, a NATS connection
+// or an AMQP channel only needs a few lines to satisfy it. Every message
+// body is an etcdclient.Event as JSON
+package publish
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// Publisher sends a message to a broker
+type Publisher interface {
+	Publish(subject string, body []byte) error
+}
+
+// PublisherFunc lets a function be used as a Publisher
+type PublisherFunc func(subject string, body []byte) error
+
+// Publish calls the function
+func (fn PublisherFunc) Publish(subject string, body []byte) error {
+	return fn(subject, body)
+}
+
+// Message is a message sent by a ChannelPublisher
+type Message struct {
+	Subject string
+	Body    []byte
+}
+
+// ChannelPublisher sends every message on the channel,
+// for consumers in the same process and for tests
+type ChannelPublisher chan<- Message
+
+// Publish sends the message on the channel, blocking until it is received
+func (channel ChannelPublisher) Publish(subject string, body []byte) error {
+	channel <- Message{Subject: subject, Body: body}
+	return nil
+}
+
+// SubjectFunc returns the subject, or routing key, an event is published to
+type SubjectFunc func(event etcdclient.Event) string
+
+// DefaultSubject publishes an event to its key with the slashes replaced
+// by dots and "etcd" in front, so a change to /config/app/db is published
+// to "etcd.config.app.db", which NATS and AMQP topic exchanges can match
+// with wildcards
+func DefaultSubject(event etcdclient.Event) string {
+	return "etcd." + strings.Replace(strings.Trim(event.Key, "/"), "/", ".", -1)
+}
+
+// OnErrorCallback is used for passing error callbacks to Watch
+type OnErrorCallback func(err error)
+
+// Watch watches the prefix and publishes every change, in the order
+// they happened, to the subject returned by subject, or DefaultSubject
+// if it is nil. If publishing fails, the event is dropped and the error
+// is passed to onError, or, if onError is nil, the watch stops and the
+// error is returned.
+// This method only returns if there is an error
+func Watch(etcd etcdclient.EtcdClient, prefix string, publisher Publisher, subject SubjectFunc, onError OnErrorCallback) error {
+	if subject == nil {
+		subject = DefaultSubject
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// events may be delivered concurrently, see etcdclient.WithWatchWorkers
+	var mutex sync.Mutex
+	var publishErr error
+	err := etcd.WithContext(ctx).WatchEvents(prefix, 0, func(event etcdclient.Event) {
+		mutex.Lock()
+		failed := publishErr != nil
+		mutex.Unlock()
+		if failed {
+			return
+		}
+
+		err := publish(publisher, subject(event), event)
+		if err == nil {
+			return
+		}
+		if onError != nil {
+			onError(err)
+			return
+		}
+
+		mutex.Lock()
+		if publishErr == nil {
+			publishErr = err
+		}
+		mutex.Unlock()
+		cancel()
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if publishErr != nil {
+		return publishErr
+	}
+	return err
+}
+
+func publish(publisher Publisher, subject string, event etcdclient.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := publisher.Publish(subject, body); err != nil {
+		return fmt.Errorf("Failed to publish %v of %v to %v: %v", event.Action, event.Key, subject, err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+package publish_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"github.com/octoblu/go-simple-etcd-client/publish"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T) etcdclient.EtcdClient {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	return etcd
+}
+
+func TestDefaultSubject(t *testing.T) {
+	if subject := publish.DefaultSubject(etcdclient.Event{Key: "/config/app/db"}); subject != "etcd.config.app.db" {
+		t.Errorf("DefaultSubject returned %v, expected etcd.config.app.db", subject)
+	}
+}
+
+func TestWatchPublishesEveryChange(t *testing.T) {
+	etcd := dial(t)
+	messages := make(chan publish.Message, 10)
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- publish.Watch(etcd, "/published", publish.ChannelPublisher(messages), nil, nil)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/published/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	select {
+	case message := <-messages:
+		var event etcdclient.Event
+		if err := json.Unmarshal(message.Body, &event); err != nil || event.Key != "/published/key" || event.Value != "value" {
+			t.Errorf("Published %s, %v, expected the event of the change", message.Body, err)
+		}
+		if message.Subject != "etcd.published.key" {
+			t.Errorf("Published to %v, expected etcd.published.key", message.Subject)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The change was not published")
+	}
+
+	etcd.Close()
+	select {
+	case <-watched:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return after Close")
+	}
+}
+
+func TestWatchWithASubjectFunc(t *testing.T) {
+	etcd := dial(t)
+	subjects := make(chan string, 10)
+	publisher := publish.PublisherFunc(func(subject string, body []byte) error {
+		subjects <- subject
+		return nil
+	})
+	subject := func(event etcdclient.Event) string { return "changes" }
+
+	go publish.Watch(etcd, "/published", publisher, subject, nil)
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/published/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	select {
+	case published := <-subjects:
+		if published != "changes" {
+			t.Errorf("Published to %v, expected the subject of the SubjectFunc", published)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The change was not published")
+	}
+	etcd.Close()
+}
+
+func TestWatchFailures(t *testing.T) {
+	failed := errors.New("broker down")
+	publisher := publish.PublisherFunc(func(subject string, body []byte) error { return failed })
+
+	etcd := dial(t)
+	failures := make(chan error, 10)
+	go publish.Watch(etcd, "/published", publisher, nil, func(err error) { failures <- err })
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/published/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	select {
+	case err := <-failures:
+		if err == nil {
+			t.Error("onError got a nil error")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("onError was not called for a failed publish")
+	}
+	etcd.Close()
+
+	etcd = dial(t)
+	watched := make(chan error, 1)
+	go func() {
+		watched <- publish.Watch(etcd, "/published", publisher, nil, nil)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/published/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	select {
+	case err := <-watched:
+		if err == nil {
+			t.Error("Watch without onError returned no error for a failed publish")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch without onError did not return after a failed publish")
+	}
+}
@@ -0,0 +1,233 @@
+// Package render renders Go templates with the keys of an etcd directory
+// into files, and renders them again whenever the directory changes, like
+// confd. Templates read keys relative to the directory with these functions:
+//
+//	getv "/db/host"            the value of a key, or "" if it does not exist
+//	getv "/db/port" "5432"     the value of a key, or a default
+//	exists "/db/host"          true if the key exists
+//	ls "/db"                   the names of the keys in a directory
+//	lsdir "/"                  the names of the subdirectories of a directory
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+)
+
+// DebounceWindow is how long Watch waits after a change before rendering,
+// so a burst of changes renders the template once
+var DebounceWindow = 500 * time.Millisecond
+
+// Template describes a template to render
+type Template struct {
+	// Src is the path of the Go template
+	Src string
+
+	// Dest is the file the template is rendered to
+	Dest string
+
+	// Prefix is the etcd directory the template reads keys from
+	Prefix string
+
+	// Mode is the mode of Dest, 0644 if it is not set
+	Mode os.FileMode
+
+	// CheckCmd, if set, is a shell command run on the rendered file before
+	// Dest is replaced, with "{{.src}}" replaced by the rendered file's path.
+	// Dest is left as it is if the command fails
+	CheckCmd string
+
+	// ReloadCmd, if set, is a shell command run after Dest is replaced
+	ReloadCmd string
+}
+
+// OnErrorCallback is used for passing error callbacks to Watch
+type OnErrorCallback func(err error)
+
+// Render renders the template once. Dest is only replaced, and the
+// commands only run, if the rendered file is different from Dest
+func Render(etcd etcdclient.EtcdClient, tmpl Template) error {
+	values, err := readValues(etcd, tmpl.Prefix)
+	if err != nil {
+		return err
+	}
+
+	rendered, err := values.execute(tmpl.Src)
+	if err != nil {
+		return err
+	}
+
+	current, err := ioutil.ReadFile(tmpl.Dest)
+	if err == nil && bytes.Equal(current, rendered) {
+		return nil
+	}
+	return tmpl.replaceDest(rendered)
+}
+
+// Watch renders the template, then renders it again every time something
+// changes in the prefix. Errors rendering the template after the first
+// render are passed to onError, which may be nil.
+// This method only returns if the first render or the watch fails
+func Watch(etcd *etcdclient.SimpleEtcdClient, tmpl Template, onError OnErrorCallback) error {
+	// changes made after the index and before the first render
+	// are rendered again by the watch, none are missed
+	index, err := etcd.Index()
+	if err != nil {
+		return err
+	}
+	if err := Render(etcd, tmpl); err != nil {
+		return err
+	}
+
+	return etcd.WatchRecursiveDebouncedFrom(tmpl.Prefix, index, DebounceWindow, func() {
+		err := Render(etcd, tmpl)
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
+
+// replaceDest stages the rendered file next to Dest, checks it
+// and renames it over Dest, then runs the reload command
+func (tmpl Template) replaceDest(rendered []byte) error {
+	mode := tmpl.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	staged, err := ioutil.TempFile(filepath.Dir(tmpl.Dest), "."+filepath.Base(tmpl.Dest))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(staged.Name())
+
+	_, err = staged.Write(rendered)
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(staged.Name(), mode); err != nil {
+		return err
+	}
+
+	if tmpl.CheckCmd != "" {
+		check := strings.Replace(tmpl.CheckCmd, "{{.src}}", staged.Name(), -1)
+		if err := run(check); err != nil {
+			return fmt.Errorf("Check command failed for %v: %v", tmpl.Dest, err)
+		}
+	}
+
+	if err := os.Rename(staged.Name(), tmpl.Dest); err != nil {
+		return err
+	}
+
+	if tmpl.ReloadCmd != "" {
+		if err := run(tmpl.ReloadCmd); err != nil {
+			return fmt.Errorf("Reload command failed for %v: %v", tmpl.Dest, err)
+		}
+	}
+	return nil
+}
+
+// values holds the keys and directories of a prefix,
+// relative to the prefix
+type values struct {
+	keys map[string]string
+	dirs map[string]bool
+}
+
+func readValues(etcd etcdclient.EtcdClient, prefix string) (*values, error) {
+	result := &values{keys: make(map[string]string), dirs: map[string]bool{"/": true}}
+	root := etcdclient.Join(prefix)
+
+	err := etcd.WalkRecursive(root, func(key, value string, dir bool) error {
+		relative := etcdclient.Join(strings.TrimPrefix(key, root))
+		if dir {
+			result.dirs[relative] = true
+		} else {
+			result.keys[relative] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (values *values) execute(src string) ([]byte, error) {
+	tmpl, err := template.New(filepath.Base(src)).Funcs(template.FuncMap{
+		"getv":   values.getv,
+		"exists": values.exists,
+		"ls":     values.ls,
+		"lsdir":  values.lsdir,
+	}).ParseFiles(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, nil); err != nil {
+		return nil, err
+	}
+	return rendered.Bytes(), nil
+}
+
+func (values *values) getv(key string, defaultValue ...string) string {
+	value, ok := values.keys[etcdclient.Join(key)]
+	if !ok && len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return value
+}
+
+func (values *values) exists(key string) bool {
+	_, ok := values.keys[etcdclient.Join(key)]
+	return ok
+}
+
+func (values *values) ls(directory string) []string {
+	return children(values.keys, directory)
+}
+
+func (values *values) lsdir(directory string) []string {
+	dirs := make(map[string]string, len(values.dirs))
+	for dir := range values.dirs {
+		dirs[dir] = ""
+	}
+	return children(dirs, directory)
+}
+
+// children returns the sorted names of the entries
+// directly in the directory
+func children(entries map[string]string, directory string) []string {
+	directory = etcdclient.Join(directory)
+	names := make([]string, 0)
+	for key := range entries {
+		if key != directory && path.Dir(key) == directory {
+			names = append(names, path.Base(key))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func run(command string) error {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,139 @@
+package render_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"github.com/octoblu/go-simple-etcd-client/render"
+)
+
+const template = `host={{getv "/db/host"}}
+port={{getv "/db/port" "5432"}}
+{{if exists "/db/user"}}user={{getv "/db/user"}}{{end}}
+keys={{range ls "/db"}}{{.}},{{end}}
+dirs={{range lsdir "/"}}{{.}},{{end}}
+`
+
+// newTemplate writes the template to a temporary directory and
+// returns a Template rendering it from /app
+func newTemplate(t *testing.T) render.Template {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "app.conf.tmpl")
+	if err := ioutil.WriteFile(src, []byte(template), 0644); err != nil {
+		t.Fatalf("WriteFile returned %v", err)
+	}
+	return render.Template{Src: src, Dest: filepath.Join(dir, "app.conf"), Prefix: "/app"}
+}
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T) *etcdclient.SimpleEtcdClient {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	return etcd.(*etcdclient.SimpleEtcdClient)
+}
+
+func set(t *testing.T, etcd etcdclient.EtcdClient, key, value string) {
+	if err := etcd.Set(key, value); err != nil {
+		t.Fatalf("Set(%v) returned %v", key, err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	etcd := dial(t)
+	set(t, etcd, "/app/db/host", "db.local")
+	set(t, etcd, "/app/db/user", "admin")
+	set(t, etcd, "/app/cache/host", "cache.local")
+	tmpl := newTemplate(t)
+	tmpl.Mode = 0600
+
+	if err := render.Render(etcd, tmpl); err != nil {
+		t.Fatalf("Render returned %v", err)
+	}
+	rendered, err := ioutil.ReadFile(tmpl.Dest)
+	if err != nil {
+		t.Fatalf("ReadFile returned %v", err)
+	}
+	expected := "host=db.local\nport=5432\nuser=admin\nkeys=host,user,\ndirs=cache,db,\n"
+	if string(rendered) != expected {
+		t.Errorf("Render wrote %q, expected %q", rendered, expected)
+	}
+	if info, err := os.Stat(tmpl.Dest); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("The rendered file has the mode %v, %v, expected 0600", info.Mode(), err)
+	}
+}
+
+func TestRenderRunsTheCommandsOnlyOnChanges(t *testing.T) {
+	etcd := dial(t)
+	set(t, etcd, "/app/db/host", "db.local")
+	tmpl := newTemplate(t)
+	reloads := filepath.Join(filepath.Dir(tmpl.Dest), "reloads")
+	tmpl.ReloadCmd = "echo reloaded >> " + reloads
+
+	for i := 0; i < 2; i++ {
+		if err := render.Render(etcd, tmpl); err != nil {
+			t.Fatalf("Render returned %v", err)
+		}
+	}
+	if data, _ := ioutil.ReadFile(reloads); string(data) != "reloaded\n" {
+		t.Errorf("The reload command ran %q, expected once for the only change", data)
+	}
+}
+
+func TestRenderKeepsDestWhenTheCheckFails(t *testing.T) {
+	etcd := dial(t)
+	set(t, etcd, "/app/db/host", "db.local")
+	tmpl := newTemplate(t)
+	if err := ioutil.WriteFile(tmpl.Dest, []byte("previous"), 0644); err != nil {
+		t.Fatalf("WriteFile returned %v", err)
+	}
+	tmpl.CheckCmd = "grep -q never {{.src}}"
+
+	if err := render.Render(etcd, tmpl); err == nil {
+		t.Error("Render with a failing check returned no error")
+	}
+	if data, _ := ioutil.ReadFile(tmpl.Dest); string(data) != "previous" {
+		t.Errorf("Dest is %q after the check failed, expected it unchanged", data)
+	}
+}
+
+func TestWatchRendersChanges(t *testing.T) {
+	defer func(window time.Duration) { render.DebounceWindow = window }(render.DebounceWindow)
+	render.DebounceWindow = 10 * time.Millisecond
+	etcd := dial(t)
+	set(t, etcd, "/app/db/host", "first")
+	tmpl := newTemplate(t)
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- render.Watch(etcd, tmpl, func(err error) {
+			t.Errorf("Watch failed to render: %v", err)
+		})
+	}()
+
+	waitForRender := func(host string) {
+		expected := "host=" + host + "\nport=5432\n\nkeys=host,\ndirs=db,\n"
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			data, _ := ioutil.ReadFile(tmpl.Dest)
+			if string(data) == expected {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Dest is %q, expected %q", data, expected)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitForRender("first")
+	set(t, etcd, "/app/db/host", "second")
+	waitForRender("second")
+
+	etcd.Close()
+	<-watched
+}
@@ -0,0 +1,146 @@
+// Package typed reads, writes and watches etcd keys as Go values instead
+// of strings, so mistakes in the type of a key are caught by the compiler.
+// Values are converted with the client's codec for the key, see
+// etcdclient.WithCodec, unless a Key is given its own Codec
+package typed
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+)
+
+// Key is an etcd key holding values of type T
+type Key[T any] struct {
+	Etcd etcdclient.EtcdClient
+	Name string
+
+	// Codec converts the values, the client's codec
+	// for the key if it is nil
+	Codec etcdclient.Codec
+}
+
+// codecs is implemented by clients with per-prefix codecs,
+// like etcdclient.SimpleEtcdClient
+type codecs interface {
+	CodecFor(key string) etcdclient.Codec
+}
+
+// Get returns the value of the key as a T. A missing key is returned
+// as the zero value, like etcdclient.EtcdClient.Get returns ""
+func Get[T any](etcd etcdclient.EtcdClient, key string) (T, error) {
+	return Key[T]{Etcd: etcd, Name: key}.Get()
+}
+
+// Set stores the value in the key
+func Set[T any](etcd etcdclient.EtcdClient, key string, value T) error {
+	return Key[T]{Etcd: etcd, Name: key}.Set(value)
+}
+
+// Watch calls onChange with the value of the key as a T every time it
+// changes, with the zero value if the key is deleted. If a value cannot
+// be decoded the watch stops and the error is returned.
+// This method only returns if there is an error
+func Watch[T any](etcd etcdclient.EtcdClient, key string, onChange func(value T)) error {
+	return Key[T]{Etcd: etcd, Name: key}.Watch(onChange)
+}
+
+// WatchRecursive calls onChange with the key and the value as a T every
+// time something changes in the directory, with the zero value for keys
+// that are deleted. Directories are skipped. If a value cannot be decoded
+// the watch stops and the error is returned.
+// This method only returns if there is an error
+func WatchRecursive[T any](etcd etcdclient.EtcdClient, directory string, onChange func(key string, value T)) error {
+	return Key[T]{Etcd: etcd, Name: directory}.watch(func(event etcdclient.Event, value T) {
+		onChange(event.Key, value)
+	})
+}
+
+// Get returns the value of the key as a T, or the zero value if it is missing
+func (key Key[T]) Get() (T, error) {
+	value, err := key.Etcd.Get(key.Name)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return key.decode(key.Name, value)
+}
+
+// Set stores the value in the key
+func (key Key[T]) Set(value T) error {
+	data, err := key.codec(key.Name).Marshal(value)
+	if err != nil {
+		return fmt.Errorf("Failed to encode %v: %v", key.Name, err)
+	}
+	return key.Etcd.Set(key.Name, string(data))
+}
+
+// Watch calls onChange with the value as a T every time the key changes.
+// This method only returns if there is an error
+func (key Key[T]) Watch(onChange func(value T)) error {
+	return key.watch(func(event etcdclient.Event, value T) {
+		if etcdclient.Join(event.Key) == etcdclient.Join(key.Name) {
+			onChange(value)
+		}
+	})
+}
+
+// watch calls onEvent with every change under the key and its decoded value
+func (key Key[T]) watch(onEvent func(event etcdclient.Event, value T)) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mutex sync.Mutex
+	var decodeErr error
+
+	err := key.Etcd.WithContext(ctx).WatchEvents(key.Name, 0, func(event etcdclient.Event) {
+		if event.Dir {
+			return
+		}
+
+		var value T
+		if !event.Removed() {
+			decoded, err := key.decode(event.Key, event.Value)
+			if err != nil {
+				mutex.Lock()
+				decodeErr = err
+				mutex.Unlock()
+				cancel()
+				return
+			}
+			value = decoded
+		}
+		onEvent(event, value)
+	})
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if decodeErr != nil {
+		return decodeErr
+	}
+	return err
+}
+
+func (key Key[T]) decode(name, value string) (T, error) {
+	var decoded T
+	if value == "" {
+		return decoded, nil
+	}
+
+	if err := key.codec(name).Unmarshal([]byte(value), &decoded); err != nil {
+		return decoded, fmt.Errorf("Failed to decode %v: %v", name, err)
+	}
+	return decoded, nil
+}
+
+func (key Key[T]) codec(name string) etcdclient.Codec {
+	if key.Codec != nil {
+		return key.Codec
+	}
+	if codecs, ok := key.Etcd.(codecs); ok {
+		return codecs.CodecFor(name)
+	}
+	return etcdclient.JSONCodec
+}
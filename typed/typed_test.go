@@ -0,0 +1,118 @@
+package typed_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"github.com/octoblu/go-simple-etcd-client/typed"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T, opts ...etcdclient.Option) etcdclient.EtcdClient {
+	etcd, stop := etcdtest.NewMemory(t, opts...)
+	t.Cleanup(stop)
+	return etcd
+}
+
+type config struct {
+	Host string
+	Port int
+}
+
+func TestGetAndSet(t *testing.T) {
+	etcd := dial(t)
+
+	if value, err := typed.Get[config](etcd, "/typed/config"); err != nil || value != (config{}) {
+		t.Errorf("Get of a missing key returned %+v, %v, expected the zero value", value, err)
+	}
+	if err := typed.Set(etcd, "/typed/config", config{"localhost", 80}); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if raw, _ := etcd.Get("/typed/config"); raw != `{"Host":"localhost","Port":80}` {
+		t.Errorf("Set stored %v, expected the JSON of the value", raw)
+	}
+	if value, err := typed.Get[config](etcd, "/typed/config"); err != nil || value != (config{"localhost", 80}) {
+		t.Errorf("Get returned %+v, %v", value, err)
+	}
+
+	etcd.Set("/typed/invalid", "not json")
+	if _, err := typed.Get[config](etcd, "/typed/invalid"); err == nil {
+		t.Error("Get of a value that is not a config returned no error")
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	etcd := dial(t, etcdclient.WithPrefixCodec("/gob", etcdclient.GobCodec))
+
+	if err := typed.Set(etcd, "/gob/port", 80); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+	if value, err := typed.Get[int](etcd, "/gob/port"); err != nil || value != 80 {
+		t.Errorf("Get with the client's codec returned %v, %v", value, err)
+	}
+	if _, err := (typed.Key[int]{Etcd: etcd, Name: "/gob/port", Codec: etcdclient.JSONCodec}).Get(); err == nil {
+		t.Error("Get with the Key's own codec decoded a gob value as JSON")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	etcd := dial(t)
+	values := make(chan int, 10)
+
+	watched := make(chan error, 1)
+	go func() {
+		watched <- typed.Watch(etcd, "/typed/port", func(value int) { values <- value })
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	etcd.Set("/typed/other", "1")
+	etcd.Set("/typed/port", "80")
+	etcd.Del("/typed/port")
+	for _, expected := range []int{80, 0} {
+		select {
+		case value := <-values:
+			if value != expected {
+				t.Errorf("Watch got %v, expected %v", value, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Watch did not get %v", expected)
+		}
+	}
+
+	etcd.Set("/typed/port", "not a number")
+	select {
+	case err := <-watched:
+		if err == nil {
+			t.Error("Watch returned no error for a value it could not decode")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watch did not return for a value it could not decode")
+	}
+}
+
+func TestWatchRecursive(t *testing.T) {
+	etcd := dial(t)
+	changes := make(chan string, 10)
+
+	go typed.WatchRecursive(etcd, "/ports", func(key string, value int) {
+		changes <- key
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	etcd.Set("/ports/http", "80")
+	etcd.Set("/ports/https", "443")
+	for _, expected := range []string{"/ports/http", "/ports/https"} {
+		select {
+		case key := <-changes:
+			if key != expected {
+				t.Errorf("WatchRecursive got %v, expected %v", key, expected)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("WatchRecursive did not get %v", expected)
+		}
+	}
+	etcd.Close()
+}
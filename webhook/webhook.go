@@ -0,0 +1,226 @@
+// Package webhook posts the changes made under an etcd directory to an
+// HTTP endpoint, so systems that do not speak etcd can react to them.
+// Every request body is JSON: a single etcdclient.Event, or an array of
+// events in batch mode. If the hook has a secret, the body is signed with HMAC-SHA256
+// and the hex signature is sent in the X-Etcd-Signature header as
+// "sha256=<signature>"
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// SignatureHeader is the header holding the signature of the body
+const SignatureHeader = "X-Etcd-Signature"
+
+// DefaultBatchWindow is how long a batch waits for more events
+// before it is posted, unless Hook.BatchWindow is set
+var DefaultBatchWindow = time.Second
+
+// DefaultRetryBackoff is how long to wait before the first retry,
+// unless Hook.RetryBackoff is set. The wait doubles on every retry
+var DefaultRetryBackoff = time.Second
+
+// Hook describes where and how changes are delivered
+type Hook struct {
+	// URL is the endpoint every change is posted to
+	URL string
+
+	// Prefix is the etcd directory to watch
+	Prefix string
+
+	// Secret, if set, is the key the body is signed with
+	Secret string
+
+	// BatchSize, if greater than 1, posts arrays of up to BatchSize events
+	// instead of one request per event. A batch is posted when it is full,
+	// or BatchWindow after its first event
+	BatchSize int
+
+	// BatchWindow is how long a batch waits for more events,
+	// DefaultBatchWindow if it is not set
+	BatchWindow time.Duration
+
+	// Retries is how many times a request that failed with a network error,
+	// a 429 or a 5xx status is retried before the delivery fails
+	Retries int
+
+	// RetryBackoff is how long to wait before the first retry,
+	// DefaultRetryBackoff if it is not set
+	RetryBackoff time.Duration
+
+	// Client makes the requests, http.DefaultClient if it is nil
+	Client *http.Client
+}
+
+// OnErrorCallback is used for passing error callbacks to Forward
+type OnErrorCallback func(err error)
+
+// Forward watches the hook's prefix and posts every change to its URL, in
+// the order they happened. If a delivery still fails after its retries, the
+// events are dropped and the error is passed to onError, or, if onError is
+// nil, the watch stops and the error is returned.
+// This method only returns if there is an error
+func Forward(etcd etcdclient.EtcdClient, hook Hook, onError OnErrorCallback) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan etcdclient.Event)
+	delivered := make(chan error, 1)
+	go func() {
+		delivered <- hook.deliver(ctx, events, onError)
+		cancel()
+	}()
+
+	err := etcd.WithContext(ctx).WatchEvents(hook.Prefix, 0, func(event etcdclient.Event) {
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+	})
+	close(events)
+
+	if deliverErr := <-delivered; deliverErr != nil {
+		return deliverErr
+	}
+	return err
+}
+
+// deliver posts the events in batches until events
+// is closed, or a delivery fails without onError
+func (hook Hook) deliver(ctx context.Context, events <-chan etcdclient.Event, onError OnErrorCallback) error {
+	var batch []etcdclient.Event
+	var flush <-chan time.Time
+
+	send := func() error {
+		err := hook.post(ctx, batch)
+		batch = nil
+		flush = nil
+		if err != nil && onError != nil {
+			onError(err)
+			return nil
+		}
+		return err
+	}
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				if len(batch) == 0 {
+					return nil
+				}
+				return send()
+			}
+
+			batch = append(batch, event)
+			if hook.BatchSize <= 1 || len(batch) >= hook.BatchSize {
+				if err := send(); err != nil {
+					return err
+				}
+				continue
+			}
+			if flush == nil {
+				flush = time.After(hook.batchWindow())
+			}
+		case <-flush:
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// post sends the events, retrying failures that may be temporary
+func (hook Hook) post(ctx context.Context, batch []etcdclient.Event) error {
+	var body []byte
+	var err error
+	if hook.BatchSize <= 1 {
+		body, err = json.Marshal(batch[0])
+	} else {
+		body, err = json.Marshal(batch)
+	}
+	if err != nil {
+		return err
+	}
+
+	backoff := hook.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+
+	for attempt := 0; ; attempt++ {
+		retry, err := hook.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= hook.Retries {
+			return fmt.Errorf("Failed to post %v events to %v: %v", len(batch), hook.URL, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// send makes a single request, retry is true if it may succeed later
+func (hook Hook) send(ctx context.Context, body []byte) (bool, error) {
+	request, err := http.NewRequest("POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if hook.Secret != "" {
+		request.Header.Set(SignatureHeader, "sha256="+Sign(hook.Secret, body))
+	}
+
+	client := hook.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := ctxhttp.Do(ctx, client, request)
+	if err != nil {
+		return ctx.Err() == nil, err
+	}
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("Unexpected status %v", response.Status)
+}
+
+func (hook Hook) batchWindow() time.Duration {
+	if hook.BatchWindow <= 0 {
+		return DefaultBatchWindow
+	}
+	return hook.BatchWindow
+}
+
+// Sign returns the hex HMAC-SHA256 of the body with the secret, the way
+// it is sent in the SignatureHeader, so receivers can verify requests
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
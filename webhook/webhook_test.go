@@ -0,0 +1,157 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/octoblu/go-simple-etcd-client/etcdclient"
+	"github.com/octoblu/go-simple-etcd-client/etcdtest"
+	"github.com/octoblu/go-simple-etcd-client/webhook"
+)
+
+// dial returns a client of a new etcdtest.MemoryServer,
+// both are stopped when the test ends
+func dial(t *testing.T) etcdclient.EtcdClient {
+	etcd, stop := etcdtest.NewMemory(t)
+	t.Cleanup(stop)
+	return etcd
+}
+
+// delivery is a request a receiver accepted
+type delivery struct {
+	body      string
+	signature string
+}
+
+// receiver answers the first failures requests with status,
+// then passes every request it accepts to deliveries
+func receiver(t *testing.T, failures int64, status int, deliveries chan<- delivery) *httptest.Server {
+	var requests int64
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if atomic.AddInt64(&requests, 1) <= failures {
+			writer.WriteHeader(status)
+			return
+		}
+		body, _ := ioutil.ReadAll(request.Body)
+		deliveries <- delivery{body: string(body), signature: request.Header.Get(webhook.SignatureHeader)}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestForwardSignsAndRetries(t *testing.T) {
+	etcd := dial(t)
+	deliveries := make(chan delivery, 10)
+	server := receiver(t, 1, http.StatusServiceUnavailable, deliveries)
+	hook := webhook.Hook{URL: server.URL, Prefix: "/hooked", Secret: "secret", Retries: 1, RetryBackoff: time.Millisecond}
+
+	go webhook.Forward(etcd, hook, nil)
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/hooked/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	var posted delivery
+	select {
+	case posted = <-deliveries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("The change was not posted")
+	}
+	var event etcdclient.Event
+	if err := json.Unmarshal([]byte(posted.body), &event); err != nil || event.Key != "/hooked/key" || event.Value != "value" {
+		t.Errorf("Posted %v, %v, expected the event of the change", posted.body, err)
+	}
+	if posted.signature != "sha256="+webhook.Sign("secret", []byte(posted.body)) {
+		t.Errorf("The request was signed %q, expected the HMAC of its body", posted.signature)
+	}
+	etcd.Close()
+}
+
+func TestForwardInBatches(t *testing.T) {
+	etcd := dial(t)
+	deliveries := make(chan delivery, 10)
+	server := receiver(t, 0, 0, deliveries)
+	hook := webhook.Hook{URL: server.URL, Prefix: "/hooked", BatchSize: 2, BatchWindow: time.Hour}
+
+	go webhook.Forward(etcd, hook, nil)
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"/hooked/a", "/hooked/b"} {
+		if err := etcd.Set(key, "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	select {
+	case posted := <-deliveries:
+		var events []etcdclient.Event
+		if err := json.Unmarshal([]byte(posted.body), &events); err != nil || len(events) != 2 || events[0].Key != "/hooked/a" || events[1].Key != "/hooked/b" {
+			t.Errorf("Posted %v, %v, expected a batch of both changes in order", posted.body, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The batch was not posted once it was full")
+	}
+	etcd.Close()
+}
+
+func TestForwardReturnsDeliveryErrorsWithoutOnError(t *testing.T) {
+	etcd := dial(t)
+	deliveries := make(chan delivery, 10)
+	server := receiver(t, 1000, http.StatusBadRequest, deliveries)
+	hook := webhook.Hook{URL: server.URL, Prefix: "/hooked", Retries: 3, RetryBackoff: time.Millisecond}
+
+	forwarded := make(chan error, 1)
+	go func() {
+		forwarded <- webhook.Forward(etcd, hook, nil)
+	}()
+	time.Sleep(100 * time.Millisecond)
+	if err := etcd.Set("/hooked/key", "value"); err != nil {
+		t.Fatalf("Set returned %v", err)
+	}
+
+	select {
+	case err := <-forwarded:
+		if err == nil {
+			t.Error("Forward returned no error for a delivery that failed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Forward did not return after a delivery failed")
+	}
+}
+
+func TestForwardPassesDeliveryErrorsToOnError(t *testing.T) {
+	etcd := dial(t)
+	deliveries := make(chan delivery, 10)
+	server := receiver(t, 1, http.StatusBadRequest, deliveries)
+	hook := webhook.Hook{URL: server.URL, Prefix: "/hooked"}
+
+	failures := make(chan error, 10)
+	go webhook.Forward(etcd, hook, func(err error) { failures <- err })
+	time.Sleep(100 * time.Millisecond)
+	for _, key := range []string{"/hooked/dropped", "/hooked/delivered"} {
+		if err := etcd.Set(key, "value"); err != nil {
+			t.Fatalf("Set returned %v", err)
+		}
+	}
+
+	select {
+	case <-failures:
+	case <-time.After(5 * time.Second):
+		t.Fatal("onError was not called for a delivery that failed")
+	}
+	select {
+	case posted := <-deliveries:
+		var event etcdclient.Event
+		json.Unmarshal([]byte(posted.body), &event)
+		if event.Key != "/hooked/delivered" {
+			t.Errorf("Posted %v after the failure, expected /hooked/delivered", event.Key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Forward stopped delivering after a failure")
+	}
+	etcd.Close()
+}